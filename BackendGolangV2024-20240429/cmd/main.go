@@ -1,35 +1,187 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/events"
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
+	"github.com/SawitProRecruitment/UserService/logging"
+	"github.com/SawitProRecruitment/UserService/metrics"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/tracing"
+	"github.com/SawitProRecruitment/UserService/webhook"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 )
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
 
-	e := echo.New()
+func run() error {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		return err
+	}
 
-	var server generated.ServerInterface = newServer()
+	logger, err := logging.New(logging.Options{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+		Output: os.Stdout,
+	})
+	if err != nil {
+		return fmt.Errorf("configuring logger: %w", err)
+	}
+	slog.SetDefault(logger)
 
-	generated.RegisterHandlers(e, server)
-	e.Use(middleware.Logger())
-	e.Logger.Fatal(e.Start(":1323"))
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func newServer() *handler.Server {
-	dbDsn := os.Getenv("DATABASE_URL")
-	var repo repository.RepositoryInterface = repository.NewRepository(repository.NewRepositoryOptions{
-		Dsn: dbDsn,
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
 	})
+	if err != nil {
+		return fmt.Errorf("configuring tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	m := metrics.New()
+	repo, err := repository.NewRepository(repository.NewRepositoryOptions{
+		Dsn:             cfg.DatabaseURL,
+		Logger:          logger,
+		Metrics:         m,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+		MaxRetries:      cfg.Database.MaxRetries,
+	})
+	if err != nil {
+		return err
+	}
+	defer repo.Db.Close()
+
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	err = repo.Connect(connectCtx)
+	cancelConnect()
+	if err != nil {
+		return err
+	}
 
-	opts := handler.NewServerOptions{
+	broker := events.NewBroker()
+	go func() {
+		if err := broker.Listen(ctx, cfg.DatabaseURL, logger); err != nil {
+			logger.Error("listening for events", "error", err)
+		}
+	}()
+
+	server := handler.NewServer(handler.NewServerOptions{
 		Repository: repo,
+		Events:     broker,
+		Logger:     logger,
+		Metrics:    m,
+		Drone: handler.DroneOptions{
+			PlotSizeMeters:  float64(cfg.Drone.PlotSizeMeters),
+			ClearanceMeters: cfg.Drone.ClearanceMeters,
+		},
+	})
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Pre(handler.RestoreRoutes())
+
+	generated.RegisterHandlers(e, generated.NewStrictHandler(server, []generated.StrictMiddlewareFunc{
+		handler.TimeoutMiddleware(handler.RequestTimeouts{
+			Default:      cfg.Timeouts.Request,
+			PerOperation: cfg.Timeouts.Operations,
+		}),
+		m.OperationMiddleware(),
+	}))
+	e.GET("/metrics", echo.WrapHandler(m.Handler()))
+	e.Use(logging.RequestID())
+	e.Use(tracing.Middleware())
+	e.Use(m.HTTPMiddleware())
+	e.Use(logging.AccessLog(logger))
+	e.Use(handler.ActorMiddleware())
+	e.Use(handler.AdminMiddleware(cfg.Admin.Token))
+	e.Use(server.IdempotencyMiddleware(handler.IdempotencyOptions{
+		TTL:         cfg.Idempotency.TTL,
+		LockTimeout: cfg.Idempotency.LockTimeout,
+	}))
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "address", cfg.ListenAddress)
+		serverErr <- e.Start(cfg.ListenAddress)
+	}()
+	go waitUntilReady(ctx, logger, server)
+	go server.RunIdempotencyPurge(ctx)
+	go server.RunDeletedPurge(ctx, cfg.Deletion.Retention)
+	go webhook.NewDispatcher(webhook.Options{
+		Repository:     repo,
+		Logger:         logger,
+		PollInterval:   cfg.Webhooks.PollInterval,
+		Timeout:        cfg.Webhooks.Timeout,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+		EventRetention: cfg.Webhooks.EventRetention,
+	}).Run(ctx)
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first and keep serving for the shutdown delay, so load
+	// balancers see the 503 and stop sending new requests, then let the
+	// in-flight ones finish before the database pool is closed.
+	logger.Info("shutting down", "delay", cfg.Timeouts.ShutdownDelay, "timeout", cfg.Timeouts.Shutdown)
+	server.SetReady(false)
+	time.Sleep(cfg.Timeouts.ShutdownDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("draining requests: %w", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Info("server stopped")
+	return nil
+}
+
+// waitUntilReady polls the readiness checks after the listener is up and
+// flips /readyz to ready once all of them pass, so orchestrators only route
+// traffic to the instance after the database is reachable.
+func waitUntilReady(ctx context.Context, logger *slog.Logger, server *handler.Server) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !server.Healthy(ctx) {
+		logger.Info("waiting for dependencies before accepting traffic")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	return handler.NewServer(opts)
+	server.SetReady(true)
+	logger.Info("server is ready")
 }
@@ -0,0 +1,406 @@
+// This file contains the typed configuration of the service and how it is
+// loaded from defaults, an optional YAML file, the environment and flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	ListenAddress string            `yaml:"listen_address"`
+	DatabaseURL   string            `yaml:"database_url"`
+	Database      DatabaseConfig    `yaml:"database"`
+	Timeouts      TimeoutsConfig    `yaml:"timeouts"`
+	Drone         DroneConfig       `yaml:"drone"`
+	Idempotency   IdempotencyConfig `yaml:"idempotency"`
+	Deletion      DeletionConfig    `yaml:"deletion"`
+	Admin         AdminConfig       `yaml:"admin"`
+	Webhooks      WebhooksConfig    `yaml:"webhooks"`
+	Log           LogConfig         `yaml:"log"`
+	Tracing       TracingConfig     `yaml:"tracing"`
+}
+
+type DatabaseConfig struct {
+	// MaxOpenConns caps the connections to Postgres, 0 means unlimited.
+	MaxOpenConns int `yaml:"max_open_conns"`
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime recycle pooled connections.
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// ConnectTimeout is how long startup waits for Postgres to answer.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// MaxRetries is how often a statement failing with a transient error is
+	// retried, 0 disables retries.
+	MaxRetries int `yaml:"max_retries"`
+}
+
+type TimeoutsConfig struct {
+	// Request applies to every operation not listed in Operations.
+	Request time.Duration `yaml:"request"`
+	// Operations overrides Request per OpenAPI operation id.
+	Operations map[string]time.Duration `yaml:"operations"`
+	// ShutdownDelay is how long /readyz fails after SIGTERM before the
+	// listener closes, so load balancers notice and stop routing new requests.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// Shutdown is how long in-flight requests may drain after SIGTERM.
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+type DroneConfig struct {
+	// PlotSizeMeters is the distance flown between two neighbouring plots.
+	PlotSizeMeters int `yaml:"plot_size_meters"`
+	// ClearanceMeters is kept between the drone and the ground or canopy.
+	ClearanceMeters int `yaml:"clearance_meters"`
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long an Idempotency-Key replays its response.
+	TTL time.Duration `yaml:"ttl"`
+	// LockTimeout is how long a key whose request never finished blocks
+	// retries.
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+}
+
+type DeletionConfig struct {
+	// Retention is how long deleted estates and trees can be restored before
+	// they are purged.
+	Retention time.Duration `yaml:"retention"`
+}
+
+type AdminConfig struct {
+	// Token authenticates admins as "Authorization: Bearer <token>". Admins
+	// may restore and list deleted rows; empty disables admin access.
+	Token string `yaml:"token"`
+}
+
+type WebhooksConfig struct {
+	// PollInterval is how often due deliveries are looked for.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how often a delivery is tried before it is abandoned.
+	MaxAttempts int `yaml:"max_attempts"`
+	// InitialBackoff doubles after every failed attempt up to MaxBackoff.
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// EventRetention is how long events are kept for delivery.
+	EventRetention time.Duration `yaml:"event_retention"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		ListenAddress: ":1323",
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  time.Minute,
+			MaxRetries:      3,
+		},
+		Timeouts: TimeoutsConfig{
+			Request: 5 * time.Second,
+			Operations: map[string]time.Duration{
+				// The drone planners walk every plot of the estate.
+				"GetEstateIdDronePlan":                30 * time.Second,
+				"GetEstateIdDronePlanWithMaxDistance": 30 * time.Second,
+				"PostMission":                         30 * time.Second,
+				// Telemetry uploads and reports handle whole flight logs.
+				"PostMissionTelemetry": 30 * time.Second,
+				"GetMissionReport":     30 * time.Second,
+				// Elevation grids and point clouds run to millions of values,
+				// and imports add thousands of trees in one transaction.
+				"PutTerrain":          30 * time.Second,
+				"PostTreesPointCloud": 2 * time.Minute,
+				"PostTreesSurvey":     2 * time.Minute,
+				// Event streams stay open until the client goes away.
+				"GetEstateEvents": 0,
+			},
+			ShutdownDelay: 5 * time.Second,
+			Shutdown:      15 * time.Second,
+		},
+		Drone: DroneConfig{
+			PlotSizeMeters:  10,
+			ClearanceMeters: 1,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:         24 * time.Hour,
+			LockTimeout: time.Minute,
+		},
+		Deletion: DeletionConfig{
+			Retention: 30 * 24 * time.Hour,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval:   time.Second,
+			Timeout:        10 * time.Second,
+			MaxAttempts:    12,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     time.Hour,
+			EventRetention: 7 * 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load builds the configuration. Later sources override earlier ones:
+// defaults, the YAML file named by -config or CONFIG_FILE, environment
+// variables and finally command line flags. The result is validated.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("estate-service", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	listenAddress := fs.String("listen-address", "", "address the HTTP server listens on")
+	databaseURL := fs.String("database-url", "", "Postgres connection string")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "log format: json or text")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := loadEnv(getenv, &cfg); err != nil {
+		return Config{}, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen-address":
+			cfg.ListenAddress = *listenAddress
+		case "database-url":
+			cfg.DatabaseURL = *databaseURL
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(getenv func(string) string, cfg *Config) error {
+	var errs []error
+	str := func(name string, target *string) {
+		if value := getenv(name); value != "" {
+			*target = value
+		}
+	}
+	integer := func(name string, target *int) {
+		if value := getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", name, value))
+				return
+			}
+			*target = n
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value := getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+				return
+			}
+			*target = d
+		}
+	}
+
+	str("LISTEN_ADDRESS", &cfg.ListenAddress)
+	str("DATABASE_URL", &cfg.DatabaseURL)
+	integer("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	integer("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_TIME", &cfg.Database.ConnMaxIdleTime)
+	duration("DB_CONNECT_TIMEOUT", &cfg.Database.ConnectTimeout)
+	integer("DB_MAX_RETRIES", &cfg.Database.MaxRetries)
+	duration("SHUTDOWN_DELAY", &cfg.Timeouts.ShutdownDelay)
+	duration("SHUTDOWN_TIMEOUT", &cfg.Timeouts.Shutdown)
+	integer("DRONE_PLOT_SIZE_METERS", &cfg.Drone.PlotSizeMeters)
+	integer("DRONE_CLEARANCE_METERS", &cfg.Drone.ClearanceMeters)
+	duration("IDEMPOTENCY_TTL", &cfg.Idempotency.TTL)
+	duration("IDEMPOTENCY_LOCK_TIMEOUT", &cfg.Idempotency.LockTimeout)
+	duration("DELETION_RETENTION", &cfg.Deletion.Retention)
+	str("ADMIN_TOKEN", &cfg.Admin.Token)
+	duration("WEBHOOK_POLL_INTERVAL", &cfg.Webhooks.PollInterval)
+	duration("WEBHOOK_TIMEOUT", &cfg.Webhooks.Timeout)
+	integer("WEBHOOK_MAX_ATTEMPTS", &cfg.Webhooks.MaxAttempts)
+	duration("WEBHOOK_INITIAL_BACKOFF", &cfg.Webhooks.InitialBackoff)
+	duration("WEBHOOK_MAX_BACKOFF", &cfg.Webhooks.MaxBackoff)
+	duration("EVENT_RETENTION", &cfg.Webhooks.EventRetention)
+	str("LOG_LEVEL", &cfg.Log.Level)
+	str("LOG_FORMAT", &cfg.Log.Format)
+	str("OTEL_TRACES_EXPORTER", &cfg.Tracing.Exporter)
+	str("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+
+	if value := getenv("REQUEST_TIMEOUTS"); value != "" {
+		if err := parseRequestTimeouts(value, &cfg.Timeouts); err != nil {
+			errs = append(errs, fmt.Errorf("REQUEST_TIMEOUTS: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseRequestTimeouts reads a comma separated list such as
+// "default=5s,GetEstateIdDronePlan=30s" on top of the current timeouts.
+func parseRequestTimeouts(value string, timeouts *TimeoutsConfig) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid timeout %q, expected operation=duration", part)
+		}
+		name = strings.TrimSpace(name)
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid timeout for %s: %v", name, err)
+		}
+		if name == "default" {
+			timeouts.Request = d
+			continue
+		}
+		if timeouts.Operations == nil {
+			timeouts.Operations = map[string]time.Duration{}
+		}
+		timeouts.Operations[name] = d
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	if c.ListenAddress == "" {
+		errs = append(errs, errors.New("listen address is required"))
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("database url is required (DATABASE_URL)"))
+	}
+	if c.Database.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("database.max_open_conns can not be negative"))
+	}
+	if c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database.max_idle_conns can not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns can not exceed max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("database connection lifetimes can not be negative"))
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("database.connect_timeout must be positive"))
+	}
+	if c.Database.MaxRetries < 0 {
+		errs = append(errs, errors.New("database.max_retries can not be negative"))
+	}
+	if c.Timeouts.Request < 0 {
+		errs = append(errs, errors.New("timeouts.request can not be negative"))
+	}
+	for name, d := range c.Timeouts.Operations {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.operations.%s can not be negative", name))
+		}
+	}
+	if c.Timeouts.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("timeouts.shutdown_delay can not be negative"))
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		errs = append(errs, errors.New("timeouts.shutdown must be positive"))
+	}
+	if c.Drone.PlotSizeMeters <= 0 {
+		errs = append(errs, errors.New("drone.plot_size_meters must be positive"))
+	}
+	if c.Drone.ClearanceMeters < 0 {
+		errs = append(errs, errors.New("drone.clearance_meters can not be negative"))
+	}
+	if c.Idempotency.TTL <= 0 || c.Idempotency.LockTimeout <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl and idempotency.lock_timeout must be positive"))
+	}
+	if c.Deletion.Retention <= 0 {
+		errs = append(errs, errors.New("deletion.retention must be positive"))
+	}
+	webhooks := c.Webhooks
+	if webhooks.PollInterval <= 0 || webhooks.Timeout <= 0 || webhooks.InitialBackoff <= 0 ||
+		webhooks.MaxBackoff <= 0 || webhooks.EventRetention <= 0 {
+		errs = append(errs, errors.New("webhooks durations must be positive"))
+	}
+	if webhooks.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("webhooks.max_attempts must be positive"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", c.Log.Format))
+	}
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio <= 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be in (0, 1]"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(map[string]string{"DATABASE_URL": "postgres://db"}))
+	require.NoError(t, err)
+	require.Equal(t, ":1323", cfg.ListenAddress)
+	require.Equal(t, 30*time.Second, cfg.Timeouts.Operations["GetEstateIdDronePlan"])
+	require.Equal(t, 10, cfg.Drone.PlotSizeMeters)
+	require.Equal(t, 30*24*time.Hour, cfg.Deletion.Retention)
+	require.Empty(t, cfg.Admin.Token)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+listen_address: ":8000"
+database_url: postgres://file
+database:
+  max_open_conns: 10
+  max_idle_conns: 5
+timeouts:
+  operations:
+    GetStats: 2s
+log:
+  level: debug
+`), 0o600))
+
+	cfg, err := Load(
+		[]string{"-config", path, "-log-level", "warn"},
+		env(map[string]string{
+			"DATABASE_URL":     "postgres://env",
+			"REQUEST_TIMEOUTS": "default=3s,PostTree=1s",
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, ":8000", cfg.ListenAddress)
+	require.Equal(t, "postgres://env", cfg.DatabaseURL)
+	require.Equal(t, 10, cfg.Database.MaxOpenConns)
+	require.Equal(t, "warn", cfg.Log.Level)
+	require.Equal(t, 3*time.Second, cfg.Timeouts.Request)
+	require.Equal(t, 2*time.Second, cfg.Timeouts.Operations["GetStats"])
+	require.Equal(t, time.Second, cfg.Timeouts.Operations["PostTree"])
+	require.Equal(t, 30*time.Second, cfg.Timeouts.Operations["GetEstateIdDronePlan"])
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("listen_adress: \":8000\"\n"), 0o600))
+
+	_, err := Load([]string{"-config", path}, env(map[string]string{"DATABASE_URL": "postgres://db"}))
+	require.Error(t, err)
+}
+
+func TestLoadReportsAllInvalidSettings(t *testing.T) {
+	_, err := Load(nil, env(map[string]string{
+		"DB_MAX_OPEN_CONNS": "many",
+	}))
+	require.ErrorContains(t, err, "DB_MAX_OPEN_CONNS")
+
+	_, err = Load(nil, env(map[string]string{
+		"LOG_FORMAT":             "xml",
+		"DRONE_PLOT_SIZE_METERS": "0",
+		"DELETION_RETENTION":     "-1h",
+		"SHUTDOWN_DELAY":         "-1s",
+	}))
+	require.ErrorContains(t, err, "database url is required")
+	require.ErrorContains(t, err, "log.format")
+	require.ErrorContains(t, err, "drone.plot_size_meters")
+	require.ErrorContains(t, err, "deletion.retention")
+	require.ErrorContains(t, err, "timeouts.shutdown_delay")
+}
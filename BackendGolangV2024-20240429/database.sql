@@ -18,13 +18,249 @@ CREATE TABLE test (
 CREATE TABLE estate (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     length INT NOT NULL,  
-    width INT NOT NULL   
+    width INT NOT NULL,
+    -- Optional geo-reference: the centre of plot (1,1) in WGS84 degrees, the
+    -- bearing of the x axis clockwise from north and the plot size in meters.
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    bearing DOUBLE PRECISION,
+    plot_size_meters DOUBLE PRECISION,
+    CONSTRAINT estate_geo_reference CHECK (
+        num_nulls(latitude, longitude, bearing, plot_size_meters) IN (0, 4)
+    ),
+    -- Incremented on every update and served as the ETag.
+    version INT NOT NULL DEFAULT 1,
+    -- Set when the estate is deleted; the row is purged after the retention
+    -- period and can be restored until then.
+    deleted_at TIMESTAMPTZ
 );
 
+-- Species trees may be planted as, with the height they grow to at most.
+CREATE TABLE species (
+    name VARCHAR(100) PRIMARY KEY,
+    max_height INT NOT NULL CHECK (max_height > 0)
+);
+
+INSERT INTO species (name, max_height) VALUES ('Elaeis guineensis', 30);
+
 CREATE TABLE tree (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     estateId VARCHAR,
     x int,
     y int,
-	height int
+	height int,
+    -- Trees without a species may grow to the default maximum height.
+    species VARCHAR(100) REFERENCES species (name) ON UPDATE CASCADE,
+    variety VARCHAR(100),
+    planted_on DATE,
+    health VARCHAR(10) NOT NULL DEFAULT 'healthy'
+        CHECK (health IN ('healthy', 'diseased', 'dead')),
+    -- Free-form attributes as a JSON object.
+    attributes JSONB NOT NULL DEFAULT '{}',
+    version INT NOT NULL DEFAULT 1,
+    -- Trees deleted together with their estate share its deleted_at.
+    deleted_at TIMESTAMPTZ
+);
+
+-- A plot holds at most one standing tree. Concurrent inserts that both passed
+-- validation are settled here.
+CREATE UNIQUE INDEX tree_estate_plot ON tree (estateId, x, y) WHERE deleted_at IS NULL;
+
+-- Finds the rows the purge job hard-deletes.
+CREATE INDEX estate_deleted_at ON estate (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX tree_deleted_at ON tree (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Every change of an estate or tree, written in the transaction of the change.
+-- before and after hold the entity as JSON; estate_id groups tree changes with
+-- their estate.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    estate_id UUID NOT NULL,
+    entity VARCHAR(16) NOT NULL,
+    entity_id UUID NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255),
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_estate ON audit_log (estate_id, id);
+
+-- The audit log is append-only.
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- Domain events, written in the transaction of the change they describe and
+-- delivered to webhooks from there. Ids are handed out before the transaction
+-- commits, so event streams order events by the transaction that wrote them
+-- (xid) and then by id, and only read transactions older than every open one.
+CREATE TABLE outbox_event (
+    id BIGSERIAL PRIMARY KEY,
+    estate_id UUID NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    data JSONB NOT NULL,
+    xid XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX outbox_event_created_at ON outbox_event (created_at);
+CREATE INDEX outbox_event_estate ON outbox_event (estate_id, xid, id);
+
+-- Endpoints notified of events. Empty event_types and a NULL estate_id match
+-- every event.
+CREATE TABLE webhook_subscription (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    estate_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per event and matching subscription, created with the event.
+CREATE TABLE webhook_delivery (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES outbox_event (id) ON DELETE CASCADE,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    abandoned_at TIMESTAMPTZ,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_delivery_due ON webhook_delivery (next_attempt_at)
+    WHERE delivered_at IS NULL AND abandoned_at IS NULL;
+
+-- Drone flights planned for an estate. The plan is snapshotted when the
+-- mission is created, so later tree changes do not alter what was flown:
+-- path holds the waypoints as JSON and trees_hash the trees it was planned
+-- over. max_distance is NULL for missions covering the whole estate.
+CREATE TABLE mission (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    estate_id UUID NOT NULL REFERENCES estate (id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'planned'
+        CHECK (status IN ('planned', 'in_flight', 'completed', 'aborted')),
+    distance INT NOT NULL,
+    landing_x INT NOT NULL,
+    landing_y INT NOT NULL,
+    max_distance INT,
+    plot_size_meters DOUBLE PRECISION NOT NULL,
+    clearance_meters INT NOT NULL,
+    trees_hash CHAR(32) NOT NULL,
+    path JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    aborted_at TIMESTAMPTZ
 );
+
+CREATE INDEX mission_estate ON mission (estate_id, created_at DESC, id DESC);
+
+-- Positions logged by the drone during a mission, in plots and meters above
+-- ground. REAL keeps the rows small; a sample per time and mission lets
+-- uploads be retried.
+CREATE TABLE mission_telemetry (
+    mission_id UUID NOT NULL REFERENCES mission (id) ON DELETE CASCADE,
+    recorded_at TIMESTAMPTZ NOT NULL,
+    x REAL NOT NULL,
+    y REAL NOT NULL,
+    altitude REAL NOT NULL,
+    battery REAL,
+    PRIMARY KEY (mission_id, recorded_at)
+);
+
+-- Ground elevation of an estate as uploaded: a grid of ncols by nrows cells in
+-- estate-local meters, row by row from the top, NaN where the grid has no
+-- data. The planner samples it at the plot centres.
+CREATE TABLE estate_terrain (
+    estate_id UUID PRIMARY KEY REFERENCES estate (id) ON DELETE CASCADE,
+    ncols INT NOT NULL CHECK (ncols > 0),
+    nrows INT NOT NULL CHECK (nrows > 0),
+    x_lower_left DOUBLE PRECISION NOT NULL,
+    y_lower_left DOUBLE PRECISION NOT NULL,
+    cell_size DOUBLE PRECISION NOT NULL CHECK (cell_size > 0),
+    elevations REAL[] NOT NULL CHECK (cardinality(elevations) = ncols * nrows),
+    uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Divisions group the blocks of an estate under a manager.
+CREATE TABLE division (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    estate_id UUID NOT NULL REFERENCES estate (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (estate_id, name)
+);
+
+-- Blocks are the plots from (x_min, y_min) to (x_max, y_max) of an estate.
+-- mask, when set, holds a row of '#' (in the block) and '.' (not in it) per y
+-- from y_min, each x_max - x_min + 1 long. Blocks of an estate do not share
+-- plots.
+CREATE TABLE block (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    estate_id UUID NOT NULL REFERENCES estate (id) ON DELETE CASCADE,
+    division_id UUID REFERENCES division (id),
+    name VARCHAR(100) NOT NULL,
+    x_min INT NOT NULL CHECK (x_min >= 1),
+    y_min INT NOT NULL CHECK (y_min >= 1),
+    x_max INT NOT NULL,
+    y_max INT NOT NULL,
+    mask TEXT[],
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (estate_id, name),
+    CHECK (x_min <= x_max AND y_min <= y_max)
+);
+
+CREATE INDEX block_division ON block (division_id);
+
+-- Fresh fruit bunches cut from a tree. estate_id is the estate of the tree,
+-- kept to aggregate yield without joining every tree.
+CREATE TABLE harvest (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    estate_id UUID NOT NULL REFERENCES estate (id) ON DELETE CASCADE,
+    tree_id UUID NOT NULL REFERENCES tree (id) ON DELETE CASCADE,
+    harvested_on DATE NOT NULL,
+    bunches INT NOT NULL CHECK (bunches >= 0),
+    weight_kg DOUBLE PRECISION NOT NULL CHECK (weight_kg >= 0),
+    harvester VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX harvest_estate ON harvest (estate_id, harvested_on);
+CREATE INDEX harvest_tree ON harvest (tree_id, harvested_on);
+
+-- Responses of POST requests sent with an Idempotency-Key header. A row
+-- without status_code belongs to a request that is still running.
+CREATE TABLE idempotency_key (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT,
+    content_type VARCHAR,
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_key_expires_at ON idempotency_key (expires_at);
+
+-- Every schema change bumps the version below together with
+-- repository.SchemaVersion; /readyz fails while the database lags behind.
+CREATE TABLE schema_migrations (
+    version INT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version) VALUES (17);
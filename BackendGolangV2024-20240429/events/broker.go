@@ -0,0 +1,160 @@
+// Package events wakes up the readers of the event outbox. Postgres notifies
+// every app instance of new events with NOTIFY, so streams served by any
+// instance see changes made through all of them.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel the repository notifies with
+// the estate id of every event it publishes.
+const Channel = "outbox_event"
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// Broker fans notifications out to the subscribers of an estate.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[uuid.UUID]map[*Subscription]struct{}{}}
+}
+
+// Subscription signals on C when the estate may have new events. Signals are
+// coalesced, so readers fetch everything after the last event they saw. C is
+// closed when the broker shuts down.
+type Subscription struct {
+	C <-chan struct{}
+
+	c        chan struct{}
+	estateId uuid.UUID
+	broker   *Broker
+}
+
+// Subscribe starts watching the events of an estate. Call Close when done.
+func (b *Broker) Subscribe(estateId uuid.UUID) *Subscription {
+	c := make(chan struct{}, 1)
+	s := &Subscription{C: c, c: c, estateId: estateId, broker: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return s
+	}
+	if b.subscribers[estateId] == nil {
+		b.subscribers[estateId] = map[*Subscription]struct{}{}
+	}
+	b.subscribers[estateId][s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[s.estateId][s]; !ok {
+		return
+	}
+	delete(b.subscribers[s.estateId], s)
+	if len(b.subscribers[s.estateId]) == 0 {
+		delete(b.subscribers, s.estateId)
+	}
+	close(s.c)
+}
+
+// Notify wakes the subscribers of an estate.
+func (b *Broker) Notify(estateId uuid.UUID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers[estateId] {
+		s.signal()
+	}
+}
+
+// NotifyAll wakes every subscriber, e.g. after notifications may have been
+// missed.
+func (b *Broker) NotifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subscribers := range b.subscribers {
+		for s := range subscribers {
+			s.signal()
+		}
+	}
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
+
+// Close ends every subscription, so streams finish before shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, subscribers := range b.subscribers {
+		for s := range subscribers {
+			close(s.c)
+		}
+	}
+	b.subscribers = map[uuid.UUID]map[*Subscription]struct{}{}
+}
+
+// Listen forwards the notifications of Postgres to the subscribers until ctx
+// is done, then closes the broker. The listener reconnects on its own; since
+// notifications sent meanwhile are lost, everyone is woken up afterwards.
+func (b *Broker) Listen(ctx context.Context, dsn string, logger *slog.Logger) error {
+	defer b.Close()
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.WarnContext(ctx, "event listener disconnected", "error", err)
+		case pq.ListenerEventReconnected:
+			logger.InfoContext(ctx, "event listener reconnected")
+			b.NotifyAll()
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.WarnContext(ctx, "event listener can not connect", "error", err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				continue // reconnected, handled by the callback
+			}
+			estateId, err := uuid.Parse(n.Extra)
+			if err != nil {
+				logger.WarnContext(ctx, "invalid event notification", "payload", n.Extra)
+				continue
+			}
+			b.Notify(estateId)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerNotifiesSubscribersOfTheEstate(t *testing.T) {
+	b := NewBroker()
+	estate, other := uuid.New(), uuid.New()
+	s := b.Subscribe(estate)
+	defer s.Close()
+	o := b.Subscribe(other)
+	defer o.Close()
+
+	b.Notify(estate)
+	b.Notify(estate)
+	require.Len(t, s.C, 1, "notifications are coalesced")
+	require.Len(t, o.C, 0)
+
+	<-s.C
+	b.NotifyAll()
+	require.Len(t, s.C, 1)
+	require.Len(t, o.C, 1)
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker()
+	s := b.Subscribe(uuid.New())
+	b.Close()
+	_, ok := <-s.C
+	require.False(t, ok)
+	s.Close()
+
+	late := b.Subscribe(uuid.New())
+	_, ok = <-late.C
+	require.False(t, ok)
+}
@@ -0,0 +1,335 @@
+// Package flightplan writes drone paths in the formats ground control
+// software and mapping tools read. Altitudes are meters above the take-off
+// point at plot (1,1), except in KML, whose altitudes are above the ground
+// below each waypoint.
+package flightplan
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+)
+
+type Format string
+
+const (
+	// QGC is a QGroundControl .plan file.
+	QGC Format = "qgc"
+	// WPL is a MAVLink mission in the QGC WPL 110 text format.
+	WPL Format = "wpl"
+	KML Format = "kml"
+	GPX Format = "gpx"
+	CSV Format = "csv"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{QGC, WPL, KML, GPX, CSV}
+
+// ContentType returns the media type of files in format.
+func (f Format) ContentType() string {
+	switch f {
+	case QGC:
+		return "application/json"
+	case KML:
+		return "application/vnd.google-earth.kml+xml"
+	case GPX:
+		return "application/gpx+xml"
+	case CSV:
+		return "text/csv"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Extension returns the usual file name extension of format.
+func (f Format) Extension() string {
+	switch f {
+	case QGC:
+		return ".plan"
+	case WPL:
+		return ".waypoints"
+	}
+	return "." + string(f)
+}
+
+// Waypoint is a point of the path placed on the globe.
+type Waypoint struct {
+	repository.Waypoint
+	Latitude  float64
+	Longitude float64
+	// Ground is the elevation of the plot relative to the take-off point.
+	Ground int
+}
+
+// Place converts the plots of a path to coordinates over ground.
+func Place(path []repository.Waypoint, ref geo.Reference, ground terrain.Model) []Waypoint {
+	waypoints := make([]Waypoint, len(path))
+	for i, point := range path {
+		latitude, longitude := ref.ToWGS84(float64(point.X), float64(point.Y))
+		waypoints[i] = Waypoint{Waypoint: point, Latitude: latitude, Longitude: longitude, Ground: ground.Ground(point.X, point.Y)}
+	}
+	return waypoints
+}
+
+// Write writes the waypoints in format. The drone takes off at the first
+// waypoint and lands at the last; name titles the plan where the format has a
+// place for it.
+func Write(w io.Writer, format Format, name string, waypoints []Waypoint) error {
+	switch format {
+	case QGC:
+		return writeQGC(w, waypoints)
+	case WPL:
+		return writeWPL(w, waypoints)
+	case KML:
+		return writeKML(w, name, waypoints)
+	case GPX:
+		return writeGPX(w, name, waypoints)
+	case CSV:
+		return writeCSV(w, waypoints)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// MAVLink commands and frames used by the mission formats.
+const (
+	mavCmdNavWaypoint = 16
+	mavCmdNavLand     = 21
+	mavCmdNavTakeoff  = 22
+
+	mavFrameGlobal            = 0
+	mavFrameGlobalRelativeAlt = 3
+)
+
+// missionItem is a MAVLink mission item: take off at the first waypoint,
+// fly to the others and land at the last.
+type missionItem struct {
+	command  int
+	waypoint Waypoint
+}
+
+func missionItems(waypoints []Waypoint) []missionItem {
+	items := make([]missionItem, 0, len(waypoints)+1)
+	for i, waypoint := range waypoints {
+		if i == 0 {
+			items = append(items, missionItem{mavCmdNavTakeoff, waypoint})
+		}
+		items = append(items, missionItem{mavCmdNavWaypoint, waypoint})
+	}
+	if len(waypoints) > 0 {
+		items = append(items, missionItem{mavCmdNavLand, waypoints[len(waypoints)-1]})
+	}
+	return items
+}
+
+func writeQGC(w io.Writer, waypoints []Waypoint) error {
+	type item struct {
+		AutoContinue        bool     `json:"autoContinue"`
+		Command             int      `json:"command"`
+		DoJumpId            int      `json:"doJumpId"`
+		Frame               int      `json:"frame"`
+		Params              [7]any   `json:"params"`
+		Type                string   `json:"type"`
+		Altitude            int      `json:"Altitude"`
+		AltitudeMode        int      `json:"AltitudeMode"`
+		AMSLAltAboveTerrain *float64 `json:"AMSLAltAboveTerrain"`
+	}
+	items := []item{}
+	for i, mission := range missionItems(waypoints) {
+		point := mission.waypoint
+		altitude := point.Altitude
+		if mission.command == mavCmdNavLand {
+			altitude = 0
+		}
+		// Hold, acceptance radius, pass radius and yaw are left to the vehicle.
+		items = append(items, item{
+			AutoContinue: true,
+			Command:      mission.command,
+			DoJumpId:     i + 1,
+			Frame:        mavFrameGlobalRelativeAlt,
+			Params:       [7]any{0, 0, 0, nil, point.Latitude, point.Longitude, altitude},
+			Type:         "SimpleItem",
+			Altitude:     altitude,
+			AltitudeMode: 1, // relative to home
+		})
+	}
+	home := []float64{0, 0, 0}
+	if len(waypoints) > 0 {
+		home = []float64{waypoints[0].Latitude, waypoints[0].Longitude, 0}
+	}
+	plan := map[string]any{
+		"fileType":      "Plan",
+		"version":       1,
+		"groundStation": "QGroundControl",
+		"geoFence":      map[string]any{"circles": []any{}, "polygons": []any{}, "version": 2},
+		"rallyPoints":   map[string]any{"points": []any{}, "version": 2},
+		"mission": map[string]any{
+			"version":             2,
+			"firmwareType":        0, // generic MAVLink autopilot
+			"vehicleType":         2, // quadrotor
+			"cruiseSpeed":         15,
+			"hoverSpeed":          5,
+			"plannedHomePosition": home,
+			"items":               items,
+		},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(plan)
+}
+
+func writeWPL(w io.Writer, waypoints []Waypoint) error {
+	if _, err := io.WriteString(w, "QGC WPL 110\n"); err != nil {
+		return err
+	}
+	if len(waypoints) == 0 {
+		return nil
+	}
+	// Item 0 is the home position, the only one in absolute altitude.
+	home := waypoints[0]
+	_, err := fmt.Fprintf(w, "0\t1\t%d\t%d\t0\t0\t0\t0\t%s\t%s\t0\t1\n",
+		mavFrameGlobal, mavCmdNavWaypoint, coordinate(home.Latitude), coordinate(home.Longitude))
+	if err != nil {
+		return err
+	}
+	for i, mission := range missionItems(waypoints) {
+		point := mission.waypoint
+		altitude := point.Altitude
+		if mission.command == mavCmdNavLand {
+			altitude = 0
+		}
+		_, err := fmt.Fprintf(w, "%d\t0\t%d\t%d\t0\t0\t0\t0\t%s\t%s\t%d\t1\n",
+			i+1, mavFrameGlobalRelativeAlt, mission.command,
+			coordinate(point.Latitude), coordinate(point.Longitude), altitude)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeKML(w io.Writer, name string, waypoints []Waypoint) error {
+	type point struct {
+		AltitudeMode string `xml:"altitudeMode"`
+		Coordinates  string `xml:"coordinates"`
+	}
+	type placemark struct {
+		Name       string `xml:"name"`
+		LineString *point `xml:"LineString,omitempty"`
+		Point      *point `xml:"Point,omitempty"`
+	}
+	type document struct {
+		XMLName    xml.Name    `xml:"kml"`
+		Xmlns      string      `xml:"xmlns,attr"`
+		Name       string      `xml:"Document>name"`
+		Placemarks []placemark `xml:"Document>Placemark"`
+	}
+
+	coordinates := make([]string, len(waypoints))
+	for i, waypoint := range waypoints {
+		coordinates[i] = kmlCoordinates(waypoint)
+	}
+	path := strings.Join(coordinates, " ")
+	doc := document{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Name:  name,
+		Placemarks: []placemark{
+			{Name: "Flight path", LineString: &point{AltitudeMode: "relativeToGround", Coordinates: path}},
+		},
+	}
+	if len(waypoints) > 0 {
+		first, last := waypoints[0], waypoints[len(waypoints)-1]
+		doc.Placemarks = append(doc.Placemarks,
+			placemark{Name: "Take-off", Point: &point{AltitudeMode: "relativeToGround", Coordinates: kmlCoordinates(first)}},
+			placemark{Name: "Landing", Point: &point{AltitudeMode: "relativeToGround", Coordinates: kmlCoordinates(last)}},
+		)
+	}
+	return writeXML(w, doc)
+}
+
+// kmlCoordinates places a waypoint relative to the ground below it, as KML
+// has no altitude mode relative to the take-off point.
+func kmlCoordinates(waypoint Waypoint) string {
+	altitude := waypoint.Altitude - waypoint.Ground
+	return coordinate(waypoint.Longitude) + "," + coordinate(waypoint.Latitude) + "," + strconv.Itoa(altitude)
+}
+
+func writeGPX(w io.Writer, name string, waypoints []Waypoint) error {
+	type routePoint struct {
+		Latitude  string `xml:"lat,attr"`
+		Longitude string `xml:"lon,attr"`
+		// Elevation is above the take-off point rather than sea level.
+		Elevation int    `xml:"ele"`
+		Name      string `xml:"name"`
+	}
+	type document struct {
+		XMLName xml.Name     `xml:"gpx"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Version string       `xml:"version,attr"`
+		Creator string       `xml:"creator,attr"`
+		Name    string       `xml:"rte>name"`
+		Points  []routePoint `xml:"rte>rtept"`
+	}
+	doc := document{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "UserService",
+		Name:    name,
+		Points:  []routePoint{},
+	}
+	for _, waypoint := range waypoints {
+		doc.Points = append(doc.Points, routePoint{
+			Latitude:  coordinate(waypoint.Latitude),
+			Longitude: coordinate(waypoint.Longitude),
+			Elevation: waypoint.Altitude,
+			Name:      fmt.Sprintf("plot %d,%d", waypoint.X, waypoint.Y),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeCSV(w io.Writer, waypoints []Waypoint) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"seq", "x", "y", "latitude", "longitude", "altitude"}); err != nil {
+		return err
+	}
+	for i, waypoint := range waypoints {
+		err := writer.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(waypoint.X),
+			strconv.Itoa(waypoint.Y),
+			coordinate(waypoint.Latitude),
+			coordinate(waypoint.Longitude),
+			strconv.Itoa(waypoint.Altitude),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// coordinate formats degrees to 8 decimals, about a millimeter.
+func coordinate(degrees float64) string {
+	return strconv.FormatFloat(degrees, 'f', 8, 64)
+}
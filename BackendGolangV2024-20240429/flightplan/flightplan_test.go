@@ -0,0 +1,127 @@
+package flightplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+	"github.com/stretchr/testify/require"
+)
+
+var path = []repository.Waypoint{
+	{X: 1, Y: 1, Altitude: 1},
+	{X: 2, Y: 1, Altitude: 11},
+	{X: 3, Y: 1, Altitude: 1},
+}
+
+var ref = geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: geo.DefaultBearing, PlotSizeMeters: 10}
+
+func write(t *testing.T, format Format) string {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, format, "mission-1", Place(path, ref, terrain.Model{})))
+	return out.String()
+}
+
+func TestWriteWPL(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(write(t, WPL)), "\n")
+	require.Equal(t, "QGC WPL 110", lines[0])
+	require.Equal(t, "0\t1\t0\t16\t0\t0\t0\t0\t1.50000000\t101.25000000\t0\t1", lines[1])
+	// Take-off, three waypoints and landing.
+	require.Len(t, lines, 7)
+	require.True(t, strings.HasPrefix(lines[2], "1\t0\t3\t22\t"))
+	require.True(t, strings.HasSuffix(lines[4], "\t11\t1"))
+	require.True(t, strings.HasPrefix(lines[6], "5\t0\t3\t21\t"))
+}
+
+func TestWriteQGC(t *testing.T) {
+	var plan struct {
+		FileType string
+		Mission  struct {
+			PlannedHomePosition []float64
+			Items               []struct {
+				Command  int
+				Frame    int
+				Params   []*float64
+				Altitude int
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(write(t, QGC)), &plan))
+	require.Equal(t, "Plan", plan.FileType)
+	require.Equal(t, []float64{1.5, 101.25, 0}, plan.Mission.PlannedHomePosition)
+	require.Len(t, plan.Mission.Items, 5)
+	second := plan.Mission.Items[2]
+	require.Equal(t, 16, second.Command)
+	require.Equal(t, 3, second.Frame)
+	require.Equal(t, 11, second.Altitude)
+	require.Nil(t, second.Params[3])
+	require.InDelta(t, 101.25009, *second.Params[5], 1e-5)
+}
+
+func TestWriteKMLAndGPX(t *testing.T) {
+	var kml struct {
+		Placemarks []struct {
+			Name       string `xml:"name"`
+			LineString struct {
+				Coordinates string `xml:"coordinates"`
+			}
+		} `xml:"Document>Placemark"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(write(t, KML)), &kml))
+	require.Len(t, kml.Placemarks, 3)
+	require.Len(t, strings.Fields(kml.Placemarks[0].LineString.Coordinates), 3)
+	require.True(t, strings.HasPrefix(kml.Placemarks[0].LineString.Coordinates, "101.25000000,1.50000000,1 "))
+
+	var gpx struct {
+		Points []struct {
+			Latitude  float64 `xml:"lat,attr"`
+			Elevation int     `xml:"ele"`
+		} `xml:"rte>rtept"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(write(t, GPX)), &gpx))
+	require.Len(t, gpx.Points, 3)
+	require.Equal(t, 11, gpx.Points[1].Elevation)
+}
+
+func TestWriteKMLOverTerrain(t *testing.T) {
+	// Plot (2,1) lies on a rise 5 meters above the take-off point.
+	ground := terrain.NewModel(repository.Terrain{
+		Columns: 3, Rows: 1, XLowerLeft: -5, YLowerLeft: -5, CellSize: 10,
+		Elevations: []float64{100, 105, 100},
+	}, 10)
+	waypoints := Place(path, ref, ground)
+
+	var kml struct {
+		Placemarks []struct {
+			LineString struct {
+				AltitudeMode string `xml:"altitudeMode"`
+				Coordinates  string `xml:"coordinates"`
+			}
+		} `xml:"Document>Placemark"`
+	}
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, KML, "mission-1", waypoints))
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &kml))
+	line := kml.Placemarks[0].LineString
+	require.Equal(t, "relativeToGround", line.AltitudeMode)
+	altitudes := []string{}
+	for _, position := range strings.Fields(line.Coordinates) {
+		altitudes = append(altitudes, position[strings.LastIndex(position, ",")+1:])
+	}
+	require.Equal(t, []string{"1", "6", "1"}, altitudes)
+
+	out.Reset()
+	require.NoError(t, Write(&out, CSV, "mission-1", waypoints))
+	require.Contains(t, out.String(), ",11\n", "other formats stay relative to the take-off point")
+}
+
+func TestWriteCSV(t *testing.T) {
+	require.Equal(t, "seq,x,y,latitude,longitude,altitude\n"+
+		"1,1,1,1.50000000,101.25000000,1\n", strings.SplitAfterN(write(t, CSV), "\n", 3)[0]+
+		strings.SplitAfterN(write(t, CSV), "\n", 3)[1])
+}
@@ -0,0 +1,99 @@
+// Package geo places estate plots on the WGS84 ellipsoid. Estates span a few
+// kilometers at most, so a local tangent plane around plot (1,1), scaled by
+// the curvature of the ellipsoid there, is accurate to well under a meter and
+// converts exactly in both directions.
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// WGS84 semi-major axis in meters and first eccentricity squared.
+const (
+	semiMajorAxis = 6378137.0
+	eccentricity2 = 6.69437999014e-3
+)
+
+// DefaultBearing points the x axis of an estate east, so y points north.
+const DefaultBearing = 90.0
+
+// Reference ties the plot grid of an estate to the globe.
+type Reference struct {
+	// Latitude and Longitude are the centre of plot (1,1) in degrees.
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Bearing is the direction of the x axis in degrees clockwise from north;
+	// the y axis points 90 degrees counter-clockwise from it.
+	Bearing        float64 `json:"bearing"`
+	PlotSizeMeters float64 `json:"plot_size_meters"`
+}
+
+// Validate reports coordinates off the globe and non-positive plot sizes.
+func (r Reference) Validate() error {
+	errs := []error{CheckCoordinates(r.Latitude, r.Longitude)}
+	if math.IsNaN(r.Bearing) || r.Bearing < 0 || r.Bearing >= 360 {
+		errs = append(errs, errors.New("bearing must be at least 0 and below 360"))
+	}
+	if !(r.PlotSizeMeters > 0) {
+		errs = append(errs, errors.New("plot size must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// CheckCoordinates reports a latitude or longitude off the globe.
+func CheckCoordinates(latitude, longitude float64) error {
+	var errs []error
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		errs = append(errs, errors.New("latitude must be between -90 and 90"))
+	}
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		errs = append(errs, errors.New("longitude must be between -180 and 180"))
+	}
+	return errors.Join(errs...)
+}
+
+// ToWGS84 returns the latitude and longitude of a position in plots, where
+// whole numbers are plot centres.
+func (r Reference) ToWGS84(x, y float64) (latitude, longitude float64) {
+	north, east := r.toLocal(x, y)
+	meridian, parallel := r.radii()
+	return r.Latitude + degrees(north/meridian), r.Longitude + degrees(east/parallel)
+}
+
+// FromWGS84 is the inverse of ToWGS84.
+func (r Reference) FromWGS84(latitude, longitude float64) (x, y float64) {
+	meridian, parallel := r.radii()
+	north := radians(latitude-r.Latitude) * meridian
+	east := radians(longitude-r.Longitude) * parallel
+	// Project onto the x axis and the y axis 90 degrees counter-clockwise.
+	bearing := radians(r.Bearing)
+	alongX := north*math.Cos(bearing) + east*math.Sin(bearing)
+	alongY := north*math.Sin(bearing) - east*math.Cos(bearing)
+	return alongX/r.PlotSizeMeters + 1, alongY/r.PlotSizeMeters + 1
+}
+
+// radii returns how many meters a radian of latitude and of longitude span at
+// the reference latitude.
+func (r Reference) radii() (meridian, parallel float64) {
+	sin := math.Sin(radians(r.Latitude))
+	w := math.Sqrt(1 - eccentricity2*sin*sin)
+	meridian = semiMajorAxis * (1 - eccentricity2) / (w * w * w)
+	parallel = semiMajorAxis / w * math.Cos(radians(r.Latitude))
+	return meridian, parallel
+}
+
+// toLocal returns the meters north and east of plot (1,1).
+func (r Reference) toLocal(x, y float64) (north, east float64) {
+	alongX := (x - 1) * r.PlotSizeMeters
+	alongY := (y - 1) * r.PlotSizeMeters
+	bearing := radians(r.Bearing)
+	yBearing := bearing - math.Pi/2
+	north = alongX*math.Cos(bearing) + alongY*math.Cos(yBearing)
+	east = alongX*math.Sin(bearing) + alongY*math.Sin(yBearing)
+	return north, east
+}
+
+func radians(d float64) float64 { return d * math.Pi / 180 }
+
+func degrees(r float64) float64 { return r * 180 / math.Pi }
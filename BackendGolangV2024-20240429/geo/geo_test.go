@@ -0,0 +1,46 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToWGS84(t *testing.T) {
+	ref := Reference{Latitude: 0, Longitude: 100, Bearing: DefaultBearing, PlotSizeMeters: 10}
+
+	latitude, longitude := ref.ToWGS84(1, 1)
+	require.Equal(t, 0.0, latitude)
+	require.Equal(t, 100.0, longitude)
+
+	// 100 plots east along the equator is 1 km, about 0.008983 degrees.
+	latitude, longitude = ref.ToWGS84(101, 1)
+	require.InDelta(t, 0, latitude, 1e-12)
+	require.InDelta(t, 100.008983, longitude, 1e-6)
+
+	// With the x axis pointing north, y points west.
+	ref.Bearing = 0
+	latitude, longitude = ref.ToWGS84(1, 101)
+	require.InDelta(t, 0, latitude, 1e-12)
+	require.InDelta(t, 99.991017, longitude, 1e-6)
+}
+
+func TestFromWGS84RoundTrips(t *testing.T) {
+	ref := Reference{Latitude: 1.4701, Longitude: 101.4474, Bearing: 37.5, PlotSizeMeters: 9}
+	for _, plot := range [][2]float64{{1, 1}, {250, 1}, {17.5, 480}, {500, 500}} {
+		latitude, longitude := ref.ToWGS84(plot[0], plot[1])
+		x, y := ref.FromWGS84(latitude, longitude)
+		require.InDelta(t, plot[0], x, 1e-9)
+		require.InDelta(t, plot[1], y, 1e-9)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Reference{Latitude: -45, Longitude: 179.9, Bearing: 0, PlotSizeMeters: 10}.Validate())
+	err := Reference{Latitude: 91, Longitude: math.NaN(), Bearing: 360}.Validate()
+	require.ErrorContains(t, err, "latitude")
+	require.ErrorContains(t, err, "longitude")
+	require.ErrorContains(t, err, "bearing")
+	require.ErrorContains(t, err, "plot size")
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type adminKey struct{}
+
+// AdminMiddleware marks requests sending "Authorization: Bearer <token>" as
+// made by an admin. An empty token disables admin access.
+func AdminMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			bearer, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				req := c.Request()
+				c.SetRequest(req.WithContext(context.WithValue(req.Context(), adminKey{}, true)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// isAdmin reports whether AdminMiddleware authenticated the request of ctx.
+func isAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// withDeleted applies the include_deleted parameter to ctx. It reports false
+// when the parameter is set by someone who is not an admin.
+func withDeleted(ctx context.Context, includeDeleted *bool) (context.Context, bool) {
+	if includeDeleted == nil || !*includeDeleted {
+		return ctx, true
+	}
+	if !isAdmin(ctx) {
+		return ctx, false
+	}
+	return repository.WithDeleted(ctx), true
+}
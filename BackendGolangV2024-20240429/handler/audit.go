@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// ActorHeader names who makes a change; it is recorded in the audit log.
+	ActorHeader = "X-Actor"
+
+	maxActorLength       = 255
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// ActorMiddleware attributes the changes of a request to the actor named in
+// the X-Actor header. Requests without it are recorded as anonymous.
+func ActorMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor := strings.TrimSpace(c.Request().Header.Get(ActorHeader))
+			if len(actor) > maxActorLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "X-Actor is too long")
+			}
+			if actor != "" {
+				req := c.Request()
+				c.SetRequest(req.WithContext(repository.WithActor(req.Context(), actor)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// (GET /estate/{id}/audit)
+func (s *Server) GetEstateAudit(ctx context.Context, request generated.GetEstateAuditRequestObject) (generated.GetEstateAuditResponseObject, error) {
+	params := request.Params
+	query := repository.AuditQuery{
+		EstateId: request.Id,
+		From:     params.From,
+		To:       params.To,
+		Limit:    defaultAuditPageSize,
+	}
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxAuditPageSize {
+			return generated.GetEstateAudit400JSONResponse{Message: "limit must be between 1 and 500"}, nil
+		}
+		query.Limit = *params.Limit
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return generated.GetEstateAudit400JSONResponse{Message: "from must be before to"}, nil
+	}
+	if params.Cursor != nil {
+		beforeId, err := strconv.ParseInt(*params.Cursor, 10, 64)
+		if err != nil || beforeId <= 0 {
+			return generated.GetEstateAudit400JSONResponse{Message: "invalid cursor"}, nil
+		}
+		query.BeforeId = beforeId
+	}
+
+	// Ask for one more entry to learn whether there is a next page.
+	limit := query.Limit
+	query.Limit++
+	entries, err := s.Repository.ListAuditEntries(ctx, query)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstateAudit404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing audit entries", "estate_id", request.Id, "error", err)
+		return generated.GetEstateAudit500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	page := generated.GetEstateAudit200JSONResponse{Entries: []generated.AuditEntry{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		cursor := strconv.FormatInt(entries[limit-1].Id, 10)
+		page.NextCursor = &cursor
+	}
+	for _, entry := range entries {
+		item := generated.AuditEntry{
+			Id:        entry.Id,
+			EstateId:  entry.EstateId,
+			Entity:    generated.AuditEntryEntity(entry.Entity),
+			EntityId:  entry.EntityId,
+			Action:    generated.AuditEntryAction(entry.Action),
+			Actor:     entry.Actor,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.RequestId != "" {
+			item.RequestId = &entry.RequestId
+		}
+		if item.Before, err = auditObject(entry.Before); err != nil {
+			return nil, err
+		}
+		if item.After, err = auditObject(entry.After); err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, item)
+	}
+	return page, nil
+}
+
+// auditObject decodes a stored before or after snapshot; nil stays absent.
+func auditObject(raw json.RawMessage) (*map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActorMiddleware(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate", nil)
+	req.Header.Set(ActorHeader, " agronomist@example.com ")
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	var actor string
+	err := ActorMiddleware()(func(c echo.Context) error {
+		actor = repository.ActorFromContext(c.Request().Context())
+		return nil
+	})(c)
+	require.NoError(t, err)
+	require.Equal(t, "agronomist@example.com", actor)
+	require.Equal(t, repository.AnonymousActor, repository.ActorFromContext(context.Background()))
+}
+
+func TestGetEstateAuditPages(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.New()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := 2
+
+	repo.EXPECT().ListAuditEntries(gomock.Any(), repository.AuditQuery{
+		EstateId: estateId.String(),
+		From:     &from,
+		BeforeId: 10,
+		Limit:    3,
+	}).Return([]repository.AuditEntry{
+		{Id: 9, EstateId: estateId, Entity: "tree", Action: "update", Actor: "anonymous", Before: []byte(`{"height":5}`), After: []byte(`{"height":6}`)},
+		{Id: 7, EstateId: estateId, Entity: "tree", Action: "create", Actor: "anonymous", After: []byte(`{"height":5}`)},
+		{Id: 3, EstateId: estateId, Entity: "estate", Action: "create", Actor: "anonymous"},
+	}, nil)
+
+	cursor := "10"
+	resp, err := s.GetEstateAudit(context.Background(), generated.GetEstateAuditRequestObject{
+		Id:     estateId.String(),
+		Params: generated.GetEstateAuditParams{From: &from, Limit: &limit, Cursor: &cursor},
+	})
+	require.NoError(t, err)
+	page := resp.(generated.GetEstateAudit200JSONResponse)
+	require.Len(t, page.Entries, 2)
+	require.Equal(t, "7", *page.NextCursor)
+	require.Equal(t, map[string]interface{}{"height": float64(5)}, *page.Entries[0].Before)
+	require.Nil(t, page.Entries[1].Before)
+}
+
+func TestGetEstateAuditInvalidParams(t *testing.T) {
+	s, _ := newTestServer(t)
+	id := uuid.NewString()
+	limit := 0
+	cursor := "abc"
+	from := time.Now()
+	to := from.Add(-time.Hour)
+
+	for _, params := range []generated.GetEstateAuditParams{
+		{Limit: &limit},
+		{Cursor: &cursor},
+		{From: &from, To: &to},
+	} {
+		resp, err := s.GetEstateAudit(context.Background(), generated.GetEstateAuditRequestObject{Id: id, Params: params})
+		require.NoError(t, err)
+		require.IsType(t, generated.GetEstateAudit400JSONResponse{}, resp)
+	}
+}
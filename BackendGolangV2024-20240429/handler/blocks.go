@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+)
+
+// maxDivisionNameLength matches the name column.
+const maxDivisionNameLength = 100
+
+// (POST /estate/{id}/divisions)
+func (s *Server) PostDivision(ctx context.Context, request generated.PostDivisionRequestObject) (generated.PostDivisionResponseObject, error) {
+	if request.Body == nil {
+		return generated.PostDivision400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	name := request.Body.Name
+	if name == "" || len(name) > maxDivisionNameLength {
+		return generated.PostDivision400JSONResponse{
+			Message: fmt.Sprintf("name must have between 1 and %d characters", maxDivisionNameLength),
+		}, nil
+	}
+
+	division, err := s.Repository.InsertDivision(ctx, request.Id, name)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostDivision404JSONResponse{Message: "estate not found"}, nil
+	}
+	if errors.Is(err, repository.ErrDivisionExists) {
+		return generated.PostDivision400JSONResponse{Message: fmt.Sprintf("the estate already has a division named %q", name)}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "adding division", "estate_id", request.Id, "error", err)
+		return generated.PostDivision500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostDivision200JSONResponse(divisionBody(division)), nil
+}
+
+// (GET /estate/{id}/divisions)
+func (s *Server) ListDivisions(ctx context.Context, request generated.ListDivisionsRequestObject) (generated.ListDivisionsResponseObject, error) {
+	divisions, err := s.Repository.ListDivisions(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.ListDivisions404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing divisions", "estate_id", request.Id, "error", err)
+		return generated.ListDivisions500JSONResponse{Message: "internal server error"}, nil
+	}
+	response := generated.ListDivisions200JSONResponse{}
+	for _, division := range divisions {
+		response = append(response, divisionBody(division))
+	}
+	return response, nil
+}
+
+// (DELETE /estate/{id}/divisions/{division_id})
+func (s *Server) DeleteDivision(ctx context.Context, request generated.DeleteDivisionRequestObject) (generated.DeleteDivisionResponseObject, error) {
+	err := s.Repository.DeleteDivision(ctx, request.Id, request.DivisionId)
+	if errors.Is(err, repository.ErrDivisionNotFound) {
+		return generated.DeleteDivision404JSONResponse{Message: "division not found"}, nil
+	}
+	if errors.Is(err, repository.ErrDivisionNotEmpty) {
+		return generated.DeleteDivision409JSONResponse{Message: "the division still has blocks, move or delete them first"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "deleting division", "estate_id", request.Id, "division_id", request.DivisionId, "error", err)
+		return generated.DeleteDivision500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.DeleteDivision204Response{}, nil
+}
+
+func divisionBody(division repository.Division) generated.Division {
+	return generated.Division{
+		Id:        division.Id,
+		EstateId:  division.EstateId,
+		Name:      division.Name,
+		CreatedAt: division.CreatedAt,
+	}
+}
+
+// (POST /estate/{id}/blocks)
+func (s *Server) PostBlock(ctx context.Context, request generated.PostBlockRequestObject) (generated.PostBlockResponseObject, error) {
+	if request.Body == nil {
+		return generated.PostBlock400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	input := blockRequest(request.Id, "", *request.Body)
+
+	var block repository.Block
+	err := s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		// The estate lock serializes the overlap check with other block
+		// writes and with resizes of the estate.
+		if _, err := repo.LockEstate(ctx, request.Id); err != nil {
+			return err
+		}
+		if err := repo.ValidateBlockRequest(ctx, input); err != nil {
+			return asInvalidInput(err)
+		}
+		var err error
+		block, err = repo.InsertBlock(ctx, input)
+		return err
+	})
+	var invalid invalidInputError
+	if errors.As(err, &invalid) {
+		return generated.PostBlock400JSONResponse{Message: invalid.Error()}, nil
+	}
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostBlock404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "adding block", "estate_id", request.Id, "error", err)
+		return generated.PostBlock500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostBlock200JSONResponse{
+		Body:    blockBody(block),
+		Headers: generated.PostBlock200ResponseHeaders{ETag: versionETag(block.Version)},
+	}, nil
+}
+
+// (GET /estate/{id}/blocks)
+func (s *Server) ListBlocks(ctx context.Context, request generated.ListBlocksRequestObject) (generated.ListBlocksResponseObject, error) {
+	blocks, err := s.Repository.ListBlocks(ctx, request.Id, request.Params.DivisionId)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.ListBlocks404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing blocks", "estate_id", request.Id, "error", err)
+		return generated.ListBlocks500JSONResponse{Message: "internal server error"}, nil
+	}
+	response := generated.ListBlocks200JSONResponse{}
+	for _, block := range blocks {
+		response = append(response, blockBody(block))
+	}
+	return response, nil
+}
+
+// (GET /estate/{id}/blocks/{block_id})
+func (s *Server) GetBlock(ctx context.Context, request generated.GetBlockRequestObject) (generated.GetBlockResponseObject, error) {
+	block, err := s.Repository.GetBlock(ctx, request.Id, request.BlockId)
+	if errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.GetBlock404JSONResponse{Message: "block not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting block", "estate_id", request.Id, "block_id", request.BlockId, "error", err)
+		return generated.GetBlock500JSONResponse{Message: "internal server error"}, nil
+	}
+	etag := versionETag(block.Version)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetBlock304Response{Headers: generated.GetBlock304ResponseHeaders{ETag: etag}}, nil
+	}
+	return generated.GetBlock200JSONResponse{
+		Body:    blockBody(block),
+		Headers: generated.GetBlock200ResponseHeaders{ETag: etag},
+	}, nil
+}
+
+// (PUT /estate/{id}/blocks/{block_id})
+func (s *Server) PutBlock(ctx context.Context, request generated.PutBlockRequestObject) (generated.PutBlockResponseObject, error) {
+	if request.Body == nil {
+		return generated.PutBlock400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.PutBlock428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.PutBlock412JSONResponse{Message: err.Error()}, nil
+	}
+	input := blockRequest(request.Id, request.BlockId, *request.Body)
+
+	var block repository.Block
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		if _, err := repo.LockEstate(ctx, request.Id); err != nil {
+			return err
+		}
+		// Missing blocks are reported before the new definition is checked.
+		if _, err := repo.GetBlock(ctx, request.Id, request.BlockId); err != nil {
+			return err
+		}
+		if err := repo.ValidateBlockRequest(ctx, input); err != nil {
+			return asInvalidInput(err)
+		}
+		var err error
+		block, err = repo.UpdateBlock(ctx, input, version)
+		return err
+	})
+	var invalid invalidInputError
+	if errors.As(err, &invalid) {
+		return generated.PutBlock400JSONResponse{Message: invalid.Error()}, nil
+	}
+	if errors.Is(err, repository.ErrEstateNotFound) || errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.PutBlock404JSONResponse{Message: "block not found"}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.PutBlock412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "updating block", "estate_id", request.Id, "block_id", request.BlockId, "error", err)
+		return generated.PutBlock500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PutBlock200JSONResponse{
+		Body:    blockBody(block),
+		Headers: generated.PutBlock200ResponseHeaders{ETag: versionETag(block.Version)},
+	}, nil
+}
+
+// (DELETE /estate/{id}/blocks/{block_id})
+func (s *Server) DeleteBlock(ctx context.Context, request generated.DeleteBlockRequestObject) (generated.DeleteBlockResponseObject, error) {
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.DeleteBlock428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.DeleteBlock412JSONResponse{Message: err.Error()}, nil
+	}
+
+	err = s.Repository.DeleteBlock(ctx, request.Id, request.BlockId, version)
+	if errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.DeleteBlock404JSONResponse{Message: "block not found"}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.DeleteBlock412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "deleting block", "estate_id", request.Id, "block_id", request.BlockId, "error", err)
+		return generated.DeleteBlock500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.DeleteBlock204Response{}, nil
+}
+
+// (GET /estate/{id}/blocks/{block_id}/stats)
+func (s *Server) GetBlockStats(ctx context.Context, request generated.GetBlockStatsRequestObject) (generated.GetBlockStatsResponseObject, error) {
+	stats, err := s.Repository.GetBlockStats(ctx, request.Id, request.BlockId)
+	if errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.GetBlockStats404JSONResponse{Message: "block not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting block stats", "estate_id", request.Id, "block_id", request.BlockId, "error", err)
+		return generated.GetBlockStats500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.GetBlockStats200JSONResponse(statsBody(stats)), nil
+}
+
+// blockRequest maps the definition of block blockId, empty for new blocks.
+func blockRequest(estateId, blockId string, body generated.BlockRequest) repository.BlockRequest {
+	req := repository.BlockRequest{
+		EstateId: estateId,
+		Id:       blockId,
+		Name:     body.Name,
+		XMin:     body.XMin,
+		YMin:     body.YMin,
+		XMax:     body.XMax,
+		YMax:     body.YMax,
+	}
+	if body.DivisionId != nil {
+		divisionId := body.DivisionId.String()
+		req.DivisionId = &divisionId
+	}
+	if body.Mask != nil {
+		req.Mask = *body.Mask
+	}
+	return req
+}
+
+func blockBody(block repository.Block) generated.Block {
+	body := generated.Block{
+		Id:         block.Id,
+		EstateId:   block.EstateId,
+		DivisionId: block.DivisionId,
+		Name:       block.Name,
+		XMin:       block.XMin,
+		YMin:       block.YMin,
+		XMax:       block.XMax,
+		YMax:       block.YMax,
+		Plots:      block.Plots(),
+		Version:    block.Version,
+		CreatedAt:  block.CreatedAt,
+	}
+	if block.Mask != nil {
+		body.Mask = &block.Mask
+	}
+	return body
+}
+
+// blockPlan narrows a drone plan over an estate to the rectangle of plots
+// holding a block, renumbered from (1,1) at its corner where the drone takes
+// off. The drone crosses the plots the mask leaves out too, clearing their
+// trees, so the flight keeps its zigzag. Without a blockId the plan is left
+// as is and the block is nil.
+func (s *Server) blockPlan(ctx context.Context, estate repository.EstateData, trees []repository.Tree, ground terrain.Model, blockId *string) (repository.EstateData, []repository.Tree, terrain.Model, *repository.Block, error) {
+	if blockId == nil {
+		return estate, trees, ground, nil, nil
+	}
+	block, err := s.Repository.GetBlock(ctx, estate.Id.String(), *blockId)
+	if err != nil {
+		return estate, nil, ground, nil, err
+	}
+	estate.Length = block.XMax - block.XMin + 1
+	estate.Width = block.YMax - block.YMin + 1
+	within := []repository.Tree{}
+	for _, tree := range trees {
+		if tree.X >= block.XMin && tree.X <= block.XMax && tree.Y >= block.YMin && tree.Y <= block.YMax {
+			tree.X -= block.XMin - 1
+			tree.Y -= block.YMin - 1
+			within = append(within, tree)
+		}
+	}
+	return estate, within, ground.Within(block.XMin, block.YMin), &block, nil
+}
+
+// estateLanding maps a landing plot of a block plan back onto the estate.
+func estateLanding(block *repository.Block, landing generated.LandingPoint) generated.LandingPoint {
+	if block != nil {
+		landing.X += block.XMin - 1
+		landing.Y += block.YMin - 1
+	}
+	return landing
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostBlock(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId, id, divisionId := uuid.New(), uuid.New(), uuid.New()
+	division := divisionId.String()
+	input := repository.BlockRequest{
+		EstateId: estateId.String(), DivisionId: &division, Name: "A1",
+		XMin: 1, YMin: 1, XMax: 3, YMax: 2, Mask: []string{"##.", "###"},
+	}
+
+	gomock.InOrder(
+		repo.EXPECT().LockEstate(gomock.Any(), estateId.String()).Return(repository.EstateData{Id: estateId, Length: 10, Width: 10}, nil),
+		repo.EXPECT().ValidateBlockRequest(gomock.Any(), input).Return(nil),
+		repo.EXPECT().InsertBlock(gomock.Any(), input).Return(repository.Block{
+			Id: id, EstateId: estateId, DivisionId: &divisionId, Name: "A1",
+			XMin: 1, YMin: 1, XMax: 3, YMax: 2, Mask: []string{"##.", "###"}, Version: 1,
+		}, nil),
+	)
+
+	resp, err := s.PostBlock(context.Background(), generated.PostBlockRequestObject{
+		Id: estateId.String(),
+		Body: &generated.BlockRequest{
+			Name: "A1", DivisionId: &divisionId, XMin: 1, YMin: 1, XMax: 3, YMax: 2,
+			Mask: &[]string{"##.", "###"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostBlock200JSONResponse{
+		Body: generated.Block{
+			Id: id, EstateId: estateId, DivisionId: &divisionId, Name: "A1",
+			XMin: 1, YMin: 1, XMax: 3, YMax: 2, Mask: &[]string{"##.", "###"}, Plots: 5, Version: 1,
+		},
+		Headers: generated.PostBlock200ResponseHeaders{ETag: `"1"`},
+	}, resp)
+}
+
+func TestPostBlockInvalid(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+
+	repo.EXPECT().LockEstate(gomock.Any(), estateId).Return(repository.EstateData{}, nil)
+	repo.EXPECT().ValidateBlockRequest(gomock.Any(), gomock.Any()).Return(&repository.ValidationError{Err: errors.New(`the block overlaps block "A1"`)})
+
+	resp, err := s.PostBlock(context.Background(), generated.PostBlockRequestObject{
+		Id:   estateId,
+		Body: &generated.BlockRequest{Name: "A2", XMin: 1, YMin: 1, XMax: 2, YMax: 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostBlock400JSONResponse{Message: `the block overlaps block "A1"`}, resp)
+
+	repo.EXPECT().LockEstate(gomock.Any(), estateId).Return(repository.EstateData{}, repository.ErrEstateNotFound)
+	resp, err = s.PostBlock(context.Background(), generated.PostBlockRequestObject{
+		Id:   estateId,
+		Body: &generated.BlockRequest{Name: "A2", XMin: 1, YMin: 1, XMax: 2, YMax: 2},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostBlock404JSONResponse{}, resp)
+}
+
+func TestPostBlockDatabaseError(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+
+	repo.EXPECT().LockEstate(gomock.Any(), estateId).Return(repository.EstateData{}, nil)
+	repo.EXPECT().ValidateBlockRequest(gomock.Any(), gomock.Any()).Return(context.DeadlineExceeded)
+
+	resp, err := s.PostBlock(context.Background(), generated.PostBlockRequestObject{
+		Id:   estateId,
+		Body: &generated.BlockRequest{Name: "A2", XMin: 1, YMin: 1, XMax: 2, YMax: 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostBlock500JSONResponse{Message: "internal server error"}, resp)
+}
+
+func TestPutBlock(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId, id := uuid.New(), uuid.New()
+	body := &generated.BlockRequest{Name: "A1", XMin: 2, YMin: 2, XMax: 4, YMax: 4}
+
+	resp, err := s.PutBlock(context.Background(), generated.PutBlockRequestObject{Id: estateId.String(), BlockId: id.String(), Body: body})
+	require.NoError(t, err)
+	require.IsType(t, generated.PutBlock428JSONResponse{}, resp)
+
+	input := repository.BlockRequest{EstateId: estateId.String(), Id: id.String(), Name: "A1", XMin: 2, YMin: 2, XMax: 4, YMax: 4}
+	repo.EXPECT().LockEstate(gomock.Any(), estateId.String()).Return(repository.EstateData{}, nil).Times(2)
+	repo.EXPECT().GetBlock(gomock.Any(), estateId.String(), id.String()).Return(repository.Block{Version: 3}, nil).Times(2)
+	repo.EXPECT().ValidateBlockRequest(gomock.Any(), input).Return(nil).Times(2)
+	repo.EXPECT().UpdateBlock(gomock.Any(), input, 2).Return(repository.Block{}, repository.ErrVersionMismatch)
+	repo.EXPECT().UpdateBlock(gomock.Any(), input, 3).Return(repository.Block{
+		Id: id, EstateId: estateId, Name: "A1", XMin: 2, YMin: 2, XMax: 4, YMax: 4, Version: 4,
+	}, nil)
+
+	resp, err = s.PutBlock(context.Background(), generated.PutBlockRequestObject{
+		Id: estateId.String(), BlockId: id.String(), Params: generated.PutBlockParams{IfMatch: ifMatch(2)}, Body: body,
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PutBlock412JSONResponse{}, resp)
+
+	resp, err = s.PutBlock(context.Background(), generated.PutBlockRequestObject{
+		Id: estateId.String(), BlockId: id.String(), Params: generated.PutBlockParams{IfMatch: ifMatch(3)}, Body: body,
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PutBlock200JSONResponse{
+		Body:    generated.Block{Id: id, EstateId: estateId, Name: "A1", XMin: 2, YMin: 2, XMax: 4, YMax: 4, Plots: 9, Version: 4},
+		Headers: generated.PutBlock200ResponseHeaders{ETag: `"4"`},
+	}, resp)
+}
+
+func TestDeleteDivisionWithBlocks(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, divisionId := uuid.NewString(), uuid.NewString()
+	repo.EXPECT().DeleteDivision(gomock.Any(), estateId, divisionId).Return(repository.ErrDivisionNotEmpty)
+
+	resp, err := s.DeleteDivision(context.Background(), generated.DeleteDivisionRequestObject{Id: estateId, DivisionId: divisionId})
+	require.NoError(t, err)
+	require.IsType(t, generated.DeleteDivision409JSONResponse{}, resp)
+}
+
+func TestPostDivisionNameTaken(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	repo.EXPECT().InsertDivision(gomock.Any(), estateId, "North").Return(repository.Division{}, repository.ErrDivisionExists)
+
+	resp, err := s.PostDivision(context.Background(), generated.PostDivisionRequestObject{
+		Id: estateId, Body: &generated.DivisionRequest{Name: "North"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostDivision400JSONResponse{Message: `the estate already has a division named "North"`}, resp)
+
+	resp, err = s.PostDivision(context.Background(), generated.PostDivisionRequestObject{
+		Id: estateId, Body: &generated.DivisionRequest{Name: ""},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostDivision400JSONResponse{}, resp)
+}
+
+func TestDronePlanForBlock(t *testing.T) {
+	s, repo := newTestServer(t)
+	id, blockId := uuid.New(), uuid.NewString()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 5, Width: 1}, nil).AnyTimes()
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).DoAndReturn(func(context.Context, string) ([]repository.Tree, error) {
+		return []repository.Tree{{X: 1, Y: 1, Height: 30}, {X: 2, Y: 1, Height: 10}, {X: 3, Y: 1, Height: 20}, {X: 4, Y: 1, Height: 10}}, nil
+	}).AnyTimes()
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound).AnyTimes()
+	repo.EXPECT().GetBlock(gomock.Any(), id.String(), blockId).Return(repository.Block{XMin: 2, YMin: 1, XMax: 4, YMax: 1}, nil).AnyTimes()
+
+	// Three plots with trees of 10m, 20m and 10m: 20m across and 40m up and
+	// down, plus the clearance.
+	resp, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanParams{BlockId: &blockId},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 62}, resp.(generated.GetEstateIdDronePlan200JSONResponse).Body)
+
+	estate, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.NotEqual(t, estate.(generated.GetEstateIdDronePlan200JSONResponse).Headers.ETag,
+		resp.(generated.GetEstateIdDronePlan200JSONResponse).Headers.ETag)
+
+	// 11m up, 10m across and 10m up make 31m, so the drone lands on the
+	// third plot of the block, numbered on the estate.
+	landing, err := s.GetEstateIdDronePlanWithMaxDistance(context.Background(), generated.GetEstateIdDronePlanWithMaxDistanceRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanWithMaxDistanceParams{MaxDistance: 35, BlockId: &blockId},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.LandingPoint{X: 4, Y: 1},
+		landing.(generated.GetEstateIdDronePlanWithMaxDistance200JSONResponse).Body.LandingPoint)
+
+	missing := uuid.NewString()
+	repo.EXPECT().GetBlock(gomock.Any(), id.String(), missing).Return(repository.Block{}, repository.ErrBlockNotFound)
+	resp, err = s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanParams{BlockId: &missing},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetEstateIdDronePlan404JSONResponse{Message: "block not found"}, resp)
+}
+
+func TestDronePlanForBlockOverTerrain(t *testing.T) {
+	s, repo := newTestServer(t)
+	id, blockId := uuid.New(), uuid.NewString()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 3, Width: 1}, nil).AnyTimes()
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return(nil, nil).AnyTimes()
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(hill(), nil).AnyTimes()
+	repo.EXPECT().GetBlock(gomock.Any(), id.String(), blockId).Return(repository.Block{XMin: 2, YMin: 1, XMax: 3, YMax: 1}, nil).AnyTimes()
+
+	// The block lies on top of the hill, where the drone takes off: up 1m,
+	// across 10m and down 1m.
+	resp, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanParams{BlockId: &blockId},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 12}, resp.(generated.GetEstateIdDronePlan200JSONResponse).Body)
+
+	landing, err := s.GetEstateIdDronePlanWithMaxDistance(context.Background(), generated.GetEstateIdDronePlanWithMaxDistanceRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanWithMaxDistanceParams{MaxDistance: 11, BlockId: &blockId},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.LandingPoint{X: 3, Y: 1},
+		landing.(generated.GetEstateIdDronePlanWithMaxDistance200JSONResponse).Body.LandingPoint)
+}
+
+func TestDronePlanWithoutTrees(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 3, Width: 1}, nil)
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return([]repository.Tree{}, nil)
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound)
+
+	resp, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 22}, resp.(generated.GetEstateIdDronePlan200JSONResponse).Body)
+}
@@ -2,139 +2,668 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
-	"net/http"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+	"github.com/SawitProRecruitment/UserService/tracing"
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // This is just a test endpoint to get you started. Please delete this endpoint.
 // (GET /hello)
-func (s *Server) GetHello(ctx echo.Context, params generated.GetHelloParams) error {
-	var resp generated.HelloResponse
-	resp.Message = fmt.Sprintf("Hello User %d", params.Id)
-	return ctx.JSON(http.StatusOK, resp)
+func (s *Server) GetHello(ctx context.Context, request generated.GetHelloRequestObject) (generated.GetHelloResponseObject, error) {
+	var resp generated.GetHello200JSONResponse
+	resp.Message = fmt.Sprintf("Hello User %d", request.Params.Id)
+	return resp, nil
 }
 
-type EstateInput struct {
-	Length int32 `json:"length"`
-	Width  int32 `json:"width"`
+func (s *Server) PostEstate(ctx context.Context, request generated.PostEstateRequestObject) (generated.PostEstateResponseObject, error) {
+	if request.Body == nil {
+		return generated.PostEstate400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	input := repository.EstateRequest{
+		Length:       int(request.Body.Length),
+		Width:        int(request.Body.Width),
+		GeoReference: geoReference(request.Body.GeoReference, s.Drone),
+	}
+
+	if err := s.Repository.ValidateEstateRequest(ctx, input); err != nil {
+		return generated.PostEstate400JSONResponse{Message: err.Error()}, nil
+	}
+	id, err := s.Repository.InsertEstate(ctx, input)
+	if err != nil || id.Id == uuid.Nil {
+		return generated.PostEstate500JSONResponse{Message: "Failed to insert estate"}, nil
+	}
+	return generated.PostEstate200JSONResponse{Id: id.Id}, nil
 }
 
-func (s *Server) PostEstate(ctx echo.Context) error {
-	var input repository.EstateRequest
-	if ctx.Request().ContentLength == 0 {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Request body is missing"})
+func (s *Server) PostTree(ctx context.Context, request generated.PostTreeRequestObject) (generated.PostTreeResponseObject, error) {
+	estateId := request.Id
+	if _, err := uuid.Parse(estateId); err != nil {
+		return generated.PostTree400JSONResponse{Message: "Invalid estate ID"}, nil
 	}
-	if err := ctx.Bind(&input); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	if request.Body == nil {
+		return generated.PostTree400JSONResponse{Message: "Invalid request"}, nil
 	}
 
-	if err := s.Repository.ValidateEstateRequest(context.Background(), input); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	ref, err := s.treesReference(ctx, estateId, *request.Body)
+	var invalid invalidInputError
+	if errors.As(err, &invalid) {
+		return generated.PostTree400JSONResponse{Message: err.Error()}, nil
 	}
-	id, err := s.Repository.InsertEstate(ctx.Request().Context(), input)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to insert estate"})
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", estateId, "error", err)
+		return generated.PostTree500JSONResponse{Message: "Failed to add tree"}, nil
 	}
-	uuid := id.Id.String()
-	if uuid != "" {
-		return ctx.JSON(http.StatusOK, map[string]string{
-			"id": uuid,
-		})
-	} else {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to insert estate"})
+	x, y, err := treePlot(*request.Body, ref)
+	if err != nil {
+		return generated.PostTree400JSONResponse{Message: err.Error()}, nil
+	}
+	req := treeRequest(estateId, x, y, *request.Body)
+
+	s.Logger.DebugContext(ctx, "adding tree", "estate_id", estateId, "x", req.X, "y", req.Y)
+	// Validate and insert in one transaction so the estate can not be resized
+	// and the plot can not be planted in between.
+	var response repository.TreeResponse
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) (err error) {
+		response, err = insertTree(ctx, repo, req)
+		return err
+	})
+	if errors.As(err, &invalid) {
+		s.Logger.InfoContext(ctx, "rejected tree", "estate_id", estateId, "error", err)
+		return generated.PostTree400JSONResponse{Message: err.Error()}, nil
 	}
+	if err != nil || response.Id == uuid.Nil {
+		s.Logger.ErrorContext(ctx, "adding tree", "estate_id", estateId, "error", err)
+		return generated.PostTree500JSONResponse{Message: "Failed to add tree"}, nil
+	}
+	return generated.PostTree200JSONResponse{Id: response.Id}, nil
 }
 
-func (s *Server) PostTree(ctx echo.Context, estateId string) error {
+// (POST /estate/{id}/trees)
+func (s *Server) PostTrees(ctx context.Context, request generated.PostTreesRequestObject) (generated.PostTreesResponseObject, error) {
+	estateId := request.Id
 	if _, err := uuid.Parse(estateId); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid estate ID")
+		return generated.PostTrees400JSONResponse{Message: "Invalid estate ID"}, nil
+	}
+	if request.Body == nil || len(request.Body.Trees) == 0 {
+		return generated.PostTrees400JSONResponse{Message: "Invalid request"}, nil
+	}
+	if len(request.Body.Trees) > maxBulkTrees {
+		return generated.PostTrees400JSONResponse{Message: fmt.Sprintf("at most %d trees can be added at once", maxBulkTrees)}, nil
+	}
+
+	ref, err := s.treesReference(ctx, estateId, request.Body.Trees...)
+	var invalid invalidInputError
+	if errors.As(err, &invalid) {
+		return generated.PostTrees400JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", estateId, "error", err)
+		return generated.PostTrees500JSONResponse{Message: "Failed to add trees"}, nil
+	}
+	trees := make([]repository.TreeRequest, len(request.Body.Trees))
+	for i, tree := range request.Body.Trees {
+		x, y, err := treePlot(tree, ref)
+		if err != nil {
+			return generated.PostTrees400JSONResponse{Message: fmt.Sprintf("tree %d: %v", i, err)}, nil
+		}
+		trees[i] = treeRequest(estateId, x, y, tree)
+	}
+
+	ids := make([]uuid.UUID, 0, len(trees))
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		// The transaction may be retried from the start.
+		ids = ids[:0]
+		for i, tree := range trees {
+			response, err := insertTree(ctx, repo, tree)
+			if err != nil {
+				return fmt.Errorf("tree %d: %w", i, err)
+			}
+			ids = append(ids, response.Id)
+		}
+		return nil
+	})
+	if errors.As(err, &invalid) {
+		s.Logger.InfoContext(ctx, "rejected trees", "estate_id", estateId, "error", err)
+		return generated.PostTrees400JSONResponse{Message: err.Error()}, nil
 	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "adding trees", "estate_id", estateId, "error", err)
+		return generated.PostTrees500JSONResponse{Message: "Failed to add trees"}, nil
+	}
+	return generated.PostTrees200JSONResponse{Ids: ids}, nil
+}
+
+// maxBulkTrees caps a bulk import so a single transaction stays short.
+const maxBulkTrees = 1000
+
+// invalidInputError marks errors caused by the request rather than the
+// database, so handlers can tell a 400 from a 500 after a transaction.
+type invalidInputError struct {
+	error
+}
 
-	var req repository.TreeRequest
-	if err := ctx.Bind(&req); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+func (e invalidInputError) Unwrap() error {
+	return e.error
+}
+
+// asInvalidInput marks the validation errors of the repository as
+// invalidInputError and returns database errors as is, so they are answered
+// with 500 and retried by WithTx when transient.
+func asInvalidInput(err error) error {
+	var invalid *repository.ValidationError
+	if errors.As(err, &invalid) {
+		return invalidInputError{err}
 	}
+	return err
+}
 
-	// Debugging: Print incoming data
-	fmt.Println("Tree coordinates:", req.X, req.Y)
-	if err := s.Repository.ValidateTreeRequest(context.Background(), estateId, req); err != nil {
+// treeRequest maps a tree of a request, placed on plot (x, y).
+func treeRequest(estateId string, x, y int, tree generated.TreeRequest) repository.TreeRequest {
+	req := repository.TreeRequest{
+		EstateId:  estateId,
+		X:         x,
+		Y:         y,
+		Height:    tree.Height,
+		PlantedOn: dateTime(tree.PlantedOn),
+	}
+	if tree.Species != nil {
+		req.Species = *tree.Species
+	}
+	if tree.Variety != nil {
+		req.Variety = *tree.Variety
+	}
+	if tree.Health != nil {
+		req.Health = string(*tree.Health)
+	}
+	if tree.Attributes != nil {
+		req.Attributes = *tree.Attributes
+	}
+	return req
+}
+
+// dateTime returns a date as midnight UTC.
+func dateTime(date *openapi_types.Date) *time.Time {
+	if date == nil {
+		return nil
+	}
+	return &date.Time
+}
 
-		// Debugging: Print incoming data
-		fmt.Println("Gotchhaaaaaa shoulbe right")
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+// insertTree validates and inserts a single tree with repo, which is expected
+// to be bound to a transaction.
+func insertTree(ctx context.Context, repo repository.RepositoryInterface, req repository.TreeRequest) (repository.TreeResponse, error) {
+	err := repo.ValidateTreeRequest(ctx, req.EstateId, req)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return repository.TreeResponse{}, invalidInputError{err}
 	}
-	// Interact with the repository to insert the tree
-	response, err := s.Repository.InsertTree(context.Background(), repository.TreeRequest{
-		EstateId: estateId,
-		X:        req.X,
-		Y:        req.Y,
-		Height:   req.Height,
+	if err != nil {
+		return repository.TreeResponse{}, asInvalidInput(err)
+	}
+	response, err := repo.InsertTree(ctx, req)
+	if errors.Is(err, repository.ErrPlotOccupied) {
+		return repository.TreeResponse{}, invalidInputError{err}
+	}
+	return response, err
+}
+
+// (GET /estate/{id})
+func (s *Server) GetEstate(ctx context.Context, request generated.GetEstateRequestObject) (generated.GetEstateResponseObject, error) {
+	ctx, ok := withDeleted(ctx, request.Params.IncludeDeleted)
+	if !ok {
+		return generated.GetEstate403JSONResponse{Message: errAdminOnly}, nil
+	}
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstate404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.GetEstate500JSONResponse{Message: "internal server error"}, nil
+	}
+	etag := versionETag(estate.Version)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetEstate304Response{Headers: generated.GetEstate304ResponseHeaders{ETag: etag}}, nil
+	}
+	return generated.GetEstate200JSONResponse{
+		Body:    estateBody(estate),
+		Headers: generated.GetEstate200ResponseHeaders{ETag: etag},
+	}, nil
+}
+
+// (PATCH /estate/{id})
+func (s *Server) PatchEstate(ctx context.Context, request generated.PatchEstateRequestObject) (generated.PatchEstateResponseObject, error) {
+	if request.Body == nil {
+		return generated.PatchEstate400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.PatchEstate428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.PatchEstate412JSONResponse{Message: err.Error()}, nil
+	}
+	input := repository.EstateRequest{
+		Length:       int(request.Body.Length),
+		Width:        int(request.Body.Width),
+		GeoReference: geoReference(request.Body.GeoReference, s.Drone),
+	}
+	if err := s.Repository.ValidateEstateRequest(ctx, input); err != nil {
+		return generated.PatchEstate400JSONResponse{Message: err.Error()}, nil
+	}
+
+	var estate repository.EstateData
+	var outside, blocksOutside int
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		// The row lock waits for trees being inserted under the old bounds and
+		// blocks new ones until the resize commits.
+		current, err := repo.LockEstate(ctx, request.Id)
+		if err != nil {
+			return err
+		}
+		if version != repository.AnyVersion && current.Version != version {
+			return repository.ErrVersionMismatch
+		}
+		outside, err = repo.CountTreesOutside(ctx, request.Id, input.Length, input.Width)
+		if err != nil || outside > 0 {
+			return err
+		}
+		blocksOutside, err = repo.CountBlocksOutside(ctx, request.Id, input.Length, input.Width)
+		if err != nil || blocksOutside > 0 {
+			return err
+		}
+		estate, err = repo.UpdateEstate(ctx, request.Id, input, version)
+		return err
 	})
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PatchEstate404JSONResponse{Message: "estate not found"}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.PatchEstate412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "resizing estate", "estate_id", request.Id, "error", err)
+		return generated.PatchEstate500JSONResponse{Message: "internal server error"}, nil
+	}
+	if outside > 0 {
+		return generated.PatchEstate409JSONResponse{
+			Message: fmt.Sprintf("%d trees stand outside %dx%d", outside, input.Length, input.Width),
+		}, nil
+	}
+	if blocksOutside > 0 {
+		return generated.PatchEstate409JSONResponse{
+			Message: fmt.Sprintf("%d blocks extend outside %dx%d", blocksOutside, input.Length, input.Width),
+		}, nil
+	}
+	return generated.PatchEstate200JSONResponse{
+		Body:    estateBody(estate),
+		Headers: generated.PatchEstate200ResponseHeaders{ETag: versionETag(estate.Version)},
+	}, nil
+}
+
+// (DELETE /estate/{id})
+func (s *Server) DeleteEstate(ctx context.Context, request generated.DeleteEstateRequestObject) (generated.DeleteEstateResponseObject, error) {
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.DeleteEstate428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.DeleteEstate412JSONResponse{Message: err.Error()}, nil
+	}
+
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		return repo.DeleteEstate(ctx, request.Id, version)
+	})
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.DeleteEstate404JSONResponse{Message: "estate not found"}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.DeleteEstate412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "deleting estate", "estate_id", request.Id, "error", err)
+		return generated.DeleteEstate500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.DeleteEstate204Response{}, nil
+}
+
+func estateBody(estate repository.EstateData) generated.Estate {
+	return generated.Estate{
+		Id:           estate.Id,
+		Length:       estate.Length,
+		Width:        estate.Width,
+		Version:      estate.Version,
+		GeoReference: geoReferenceBody(estate.GeoReference),
+		DeletedAt:    estate.DeletedAt,
+	}
+}
+
+// (GET /estate/{id}/tree/{tree_id})
+func (s *Server) GetTree(ctx context.Context, request generated.GetTreeRequestObject) (generated.GetTreeResponseObject, error) {
+	ctx, ok := withDeleted(ctx, request.Params.IncludeDeleted)
+	if !ok {
+		return generated.GetTree403JSONResponse{Message: errAdminOnly}, nil
+	}
+	tree, err := s.Repository.GetTreeById(ctx, request.Id, request.TreeId)
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.GetTree404JSONResponse{Message: "tree not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting tree", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.GetTree500JSONResponse{Message: "internal server error"}, nil
+	}
+	etag := versionETag(tree.Version)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetTree304Response{Headers: generated.GetTree304ResponseHeaders{ETag: etag}}, nil
+	}
+	return generated.GetTree200JSONResponse{
+		Body:    treeBody(tree),
+		Headers: generated.GetTree200ResponseHeaders{ETag: etag},
+	}, nil
+}
+
+// (PATCH /estate/{id}/tree/{tree_id})
+func (s *Server) PatchTree(ctx context.Context, request generated.PatchTreeRequestObject) (generated.PatchTreeResponseObject, error) {
+	if request.Body == nil {
+		return generated.PatchTree400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.PatchTree428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.PatchTree412JSONResponse{Message: err.Error()}, nil
+	}
+	body := request.Body
+	if *body == (generated.TreeUpdateRequest{}) {
+		return generated.PatchTree400JSONResponse{Message: "no fields to update"}, nil
+	}
+	input := repository.TreeUpdate{
+		EstateId:  request.Id,
+		Id:        request.TreeId,
+		Height:    body.Height,
+		Species:   body.Species,
+		Variety:   body.Variety,
+		PlantedOn: dateTime(body.PlantedOn),
+		Version:   version,
+	}
+	if body.Health != nil {
+		health := string(*body.Health)
+		input.Health = &health
+	}
+	if body.Attributes != nil {
+		input.Attributes = *body.Attributes // {} clears them
+	}
+	var invalid *repository.ValidationError
+	err = s.Repository.ValidateTreeUpdate(ctx, input)
+	if errors.As(err, &invalid) {
+		return generated.PatchTree400JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "validating tree", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.PatchTree500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	tree, err := s.Repository.UpdateTree(ctx, input)
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.PatchTree404JSONResponse{Message: "tree not found"}, nil
+	}
+	if errors.As(err, &invalid) {
+		// Another update changed the tree since it was validated.
+		return generated.PatchTree400JSONResponse{Message: err.Error()}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.PatchTree412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "updating tree", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.PatchTree500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PatchTree200JSONResponse{
+		Body:    treeBody(tree),
+		Headers: generated.PatchTree200ResponseHeaders{ETag: versionETag(tree.Version)},
+	}, nil
+}
+
+// (DELETE /estate/{id}/tree/{tree_id})
+func (s *Server) DeleteTree(ctx context.Context, request generated.DeleteTreeRequestObject) (generated.DeleteTreeResponseObject, error) {
+	version, err := ifMatchVersion(request.Params.IfMatch)
+	if errors.Is(err, errPreconditionRequired) {
+		return generated.DeleteTree428JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return generated.DeleteTree412JSONResponse{Message: err.Error()}, nil
+	}
+
+	err = s.Repository.DeleteTree(ctx, request.Id, request.TreeId, version)
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.DeleteTree404JSONResponse{Message: "tree not found"}, nil
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return generated.DeleteTree412JSONResponse{Message: errPreconditionFailed.Error()}, nil
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to add tree"})
+		s.Logger.ErrorContext(ctx, "deleting tree", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.DeleteTree500JSONResponse{Message: "internal server error"}, nil
 	}
+	return generated.DeleteTree204Response{}, nil
+}
 
-	uuid := response.Id.String()
-	if uuid != "" {
-		return ctx.JSON(http.StatusOK, map[string]string{
-			"id": uuid,
-		})
-	} else {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to insert Tree"})
+func treeBody(tree repository.TreeData) generated.Tree {
+	estateId, _ := uuid.Parse(tree.EstateId)
+	body := generated.Tree{
+		Id:        tree.Id,
+		EstateId:  estateId,
+		X:         tree.X,
+		Y:         tree.Y,
+		Height:    tree.Height,
+		Health:    generated.TreeHealth(tree.Health),
+		Version:   tree.Version,
+		DeletedAt: tree.DeletedAt,
+	}
+	if tree.Species != "" {
+		body.Species = &tree.Species
 	}
+	if tree.Variety != "" {
+		body.Variety = &tree.Variety
+	}
+	if tree.PlantedOn != nil {
+		body.PlantedOn = &openapi_types.Date{Time: *tree.PlantedOn}
+	}
+	if tree.Attributes != nil {
+		body.Attributes = &tree.Attributes
+	}
+	return body
 }
 
-func (s *Server) GetStats(ctx echo.Context, id string) error {
-	stats, err := s.Repository.GetEstateStats(context.Background(), id)
+func (s *Server) GetStats(ctx context.Context, request generated.GetStatsRequestObject) (generated.GetStatsResponseObject, error) {
+	ctx, ok := withDeleted(ctx, request.Params.IncludeDeleted)
+	if !ok {
+		return generated.GetStats403JSONResponse{Message: errAdminOnly}, nil
+	}
+	var groupBy []string
+	if request.Params.GroupBy != nil {
+		for _, column := range *request.Params.GroupBy {
+			switch column {
+			case generated.GetStatsParamsGroupBySpecies, generated.GetStatsParamsGroupByHealth:
+			default:
+				return generated.GetStats400JSONResponse{Message: fmt.Sprintf("cannot group by %q", column)}, nil
+			}
+			if !slices.Contains(groupBy, string(column)) {
+				groupBy = append(groupBy, string(column))
+			}
+		}
+	}
+	stats, err := s.Repository.GetEstateStats(ctx, request.Id)
+	var groups []repository.EstateStatsGroup
+	if err == nil && len(groupBy) > 0 {
+		groups, err = s.Repository.GetEstateStatsGroups(ctx, request.Id, groupBy)
+	}
+	var yield repository.YieldStats
+	includeYield := request.Params.IncludeYield != nil && *request.Params.IncludeYield
+	if err == nil && includeYield {
+		yield, err = s.Repository.GetEstateYieldStats(ctx, request.Id)
+	}
 	if err != nil {
-		if err.Error() == "estate not found" {
-			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "estate not found"})
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return generated.GetStats404JSONResponse{Message: "estate not found"}, nil
 		}
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		s.Logger.ErrorContext(ctx, "getting estate stats", "estate_id", request.Id, "error", err)
+		return generated.GetStats500JSONResponse{Message: "internal server error"}, nil
+	}
+	body := statsBody(stats)
+	if len(groupBy) > 0 {
+		body.Groups = statsGroupsBody(groups, groupBy)
+	}
+	if includeYield {
+		body.Yield = yieldStatsBody(yield)
 	}
+	etag := contentETag(body)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetStats304Response{Headers: generated.GetStats304ResponseHeaders{ETag: etag}}, nil
+	}
+	return generated.GetStats200JSONResponse{
+		Body:    body,
+		Headers: generated.GetStats200ResponseHeaders{ETag: etag},
+	}, nil
+}
+
+func statsBody(stats repository.EstateStats) generated.EstateStatsResponse {
 	if math.IsNaN(stats.Median) {
 		stats.Median = 0.0 // Or set to NaN, depending on your preference
 	}
-
-	response := map[string]interface{}{
-		"count":  stats.Count,
-		"max":    stats.MaxHeight,
-		"min":    stats.MinHeight,
-		"median": stats.Median,
+	return generated.EstateStatsResponse{
+		Count:  stats.Count,
+		Max:    stats.MaxHeight,
+		Min:    stats.MinHeight,
+		Median: float32(stats.Median),
 	}
+}
 
-	return ctx.JSON(http.StatusOK, response)
+// statsGroupsBody returns the groups of the statistics, naming only the
+// columns they are grouped by.
+func statsGroupsBody(groups []repository.EstateStatsGroup, groupBy []string) *[]generated.EstateStatsGroup {
+	body := make([]generated.EstateStatsGroup, len(groups))
+	for i, group := range groups {
+		stats := statsBody(group.EstateStats)
+		body[i] = generated.EstateStatsGroup{Count: stats.Count, Max: stats.Max, Min: stats.Min, Median: stats.Median}
+		if slices.Contains(groupBy, repository.StatsGroupSpecies) {
+			body[i].Species = &group.Species
+		}
+		if slices.Contains(groupBy, repository.StatsGroupHealth) {
+			health := generated.TreeHealth(group.Health)
+			body[i].Health = &health
+		}
+	}
+	return &body
 }
 
-func (s *Server) GetEstateIdDronePlan(ctx echo.Context, id string) error {
-	estate, err := s.Repository.GetEstateById(context.Background(), id)
+func (s *Server) GetEstateIdDronePlan(ctx context.Context, request generated.GetEstateIdDronePlanRequestObject) (generated.GetEstateIdDronePlanResponseObject, error) {
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstateIdDronePlan404JSONResponse{Message: "estate not found"}, nil
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "estate not found"})
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlan500JSONResponse{Message: "internal server error"}, nil
 	}
 
-	trees, err := s.Repository.GetTreesByEstateId(context.Background(), id)
+	trees, err := s.Repository.GetTreesByEstateId(ctx, request.Id)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting trees", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlan500JSONResponse{Message: "internal server error"}, nil
+	}
+	ground, err := s.terrainModel(ctx, estate)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		s.Logger.ErrorContext(ctx, "getting terrain", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlan500JSONResponse{Message: "internal server error"}, nil
 	}
+	estate, trees, ground, block, err := s.blockPlan(ctx, estate, trees, ground, request.Params.BlockId)
+	if errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.GetEstateIdDronePlan404JSONResponse{Message: "block not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting block", "estate_id", request.Id, "block_id", *request.Params.BlockId, "error", err)
+		return generated.GetEstateIdDronePlan500JSONResponse{Message: "internal server error"}, nil
+	}
+	drone := s.droneOptions(estate)
+	etag := planETag(estate, trees, ground, block, drone, 0)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetEstateIdDronePlan304Response{Headers: generated.GetEstateIdDronePlan304ResponseHeaders{ETag: etag}}, nil
+	}
+
+	start := time.Now()
+	estatePlots := estate.Length * estate.Width
+	if !ground.Flat() {
+		// Over terrain every plot has its own altitude, so the whole flight
+		// is walked.
+		plan, err := s.walkFlight(ctx, estate, trees, ground, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.Metrics.ObservePlanner("GetEstateIdDronePlan", time.Since(start), plan.plotsTraversed, estatePlots)
+		return generated.GetEstateIdDronePlan200JSONResponse{
+			Body:    generated.DropPlanResponse{Distance: float32(plan.totalDistance(ground))},
+			Headers: generated.GetEstateIdDronePlan200ResponseHeaders{ETag: etag},
+		}, nil
+	}
+
+	_, span := tracer.Start(ctx, "planner.calculateTotalElevation", trace.WithAttributes(
+		attribute.Int("estate.plots", estate.Length*estate.Width),
+		attribute.Int("estate.trees", len(trees)),
+	))
 	totalElevation := calculateTotalElevation(trees)
+	span.End()
 
-	totalHorizontal := ((estate.Length * estate.Width) - 1) * 10
-	totalDistance := totalHorizontal + totalElevation + 2
+	totalHorizontal := horizontalDistance(drone, estate.Length, estate.Width)
+	totalDistance := totalHorizontal + totalElevation + 2*drone.ClearanceMeters
 
-	response := map[string]interface{}{
-		"distance": totalDistance,
-	}
+	s.Metrics.ObservePlanner("GetEstateIdDronePlan", time.Since(start), estatePlots, estatePlots)
 
-	return ctx.JSON(http.StatusOK, response)
+	return generated.GetEstateIdDronePlan200JSONResponse{
+		Body:    generated.DropPlanResponse{Distance: float32(totalDistance)},
+		Headers: generated.GetEstateIdDronePlan200ResponseHeaders{ETag: etag},
+	}, nil
 }
 
+// planETag hashes everything a drone plan depends on, so an unchanged estate
+// is answered with 304 before the planner runs. It sorts trees.
+func planETag(estate repository.EstateData, trees []repository.Tree, ground terrain.Model, block *repository.Block, drone DroneOptions, maxDistance int) string {
+	sortTrees(trees)
+	var origin *[2]int
+	if block != nil {
+		origin = &[2]int{block.XMin, block.YMin}
+	}
+	return contentETag(struct {
+		Length      int
+		Width       int
+		Trees       []repository.Tree
+		Drone       DroneOptions
+		MaxDistance int
+		// Flat estates keep the ETags they had before terrain existed.
+		Terrain *time.Time `json:",omitempty"`
+		// So do plans over the whole estate since blocks exist.
+		Origin *[2]int `json:",omitempty"`
+	}{estate.Length, estate.Width, trees, drone, maxDistance, ground.UploadedAt(), origin})
+}
+
+var tracer = tracing.Tracer("handler")
+
+// cancellationCheckInterval is how many plots the planner visits between
+// checks of the request context.
+const cancellationCheckInterval = 1024
+
 // Sort trees in a zigzag pattern
 func sortTrees(trees []repository.Tree) {
 	sort.SliceStable(trees, func(i, j int) bool {
@@ -150,6 +679,9 @@ func sortTrees(trees []repository.Tree) {
 
 func calculateTotalElevation(trees []repository.Tree) int {
 	totalElevation := 0
+	if len(trees) == 0 {
+		return totalElevation // Blocks, and estates, may have no trees yet
+	}
 
 	// Sort trees in zigzag order
 	sortTrees(trees)
@@ -179,103 +711,47 @@ func calculateTotalElevation(trees []repository.Tree) int {
 	return totalElevation
 }
 
-func (s *Server) GetEstateIdDronePlanWithMaxDistance(ctx echo.Context, id string, params generated.GetEstateIdDronePlanWithMaxDistanceParams) error {
-	estate, err := s.Repository.GetEstateById(context.Background(), id)
+func (s *Server) GetEstateIdDronePlanWithMaxDistance(ctx context.Context, request generated.GetEstateIdDronePlanWithMaxDistanceRequestObject) (generated.GetEstateIdDronePlanWithMaxDistanceResponseObject, error) {
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstateIdDronePlanWithMaxDistance404JSONResponse{Message: "estate not found"}, nil
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "estate not found"})
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlanWithMaxDistance500JSONResponse{Message: "internal server error"}, nil
 	}
 
-	trees, err := s.Repository.GetTreesByEstateId(context.Background(), id)
+	trees, err := s.Repository.GetTreesByEstateId(ctx, request.Id)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		s.Logger.ErrorContext(ctx, "getting trees", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlanWithMaxDistance500JSONResponse{Message: "internal server error"}, nil
 	}
-
-	// Calculate total elevation and horizontal distance
-	totalElevation := calculateTotalElevation(trees)
-	// Ensure each horizontal movement is multiplied by 10 meters
-	totalHorizontal := ((estate.Length * estate.Width) - 1) * 10
-	totalDistance := totalHorizontal + totalElevation + 2
-
-	maxDistance := params.MaxDistance
-	if maxDistance > totalDistance {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid max_distance"})
+	ground, err := s.terrainModel(ctx, estate)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting terrain", "estate_id", request.Id, "error", err)
+		return generated.GetEstateIdDronePlanWithMaxDistance500JSONResponse{Message: "internal server error"}, nil
 	}
-
-	// If max_distance is provided and is less than totalDistance
-	if maxDistance > 0 && totalDistance > maxDistance {
-		landingPoint := calculateLandingPlot(trees, maxDistance, totalHorizontal, estate.Width)
-		response := map[string]interface{}{
-			"distance": maxDistance,
-			"rest":     landingPoint,
-		}
-		return ctx.JSON(http.StatusOK, response)
-	} else {
-		// Otherwise, return the last plot coordinates
-		landingPoint := map[string]int{
-			"x": estate.Length,
-			"y": estate.Width,
-		}
-
-		response := map[string]interface{}{
-			"distance":      maxDistance,
-			"landing_point": landingPoint,
-		}
-		return ctx.JSON(http.StatusOK, response)
+	estate, trees, ground, block, err := s.blockPlan(ctx, estate, trees, ground, request.Params.BlockId)
+	if errors.Is(err, repository.ErrBlockNotFound) {
+		return generated.GetEstateIdDronePlanWithMaxDistance404JSONResponse{Message: "block not found"}, nil
 	}
-}
-func calculateLandingPlot(trees []repository.Tree, maxDistance, estateLength, estateWidth int) map[string]int {
-	travelDistance := 1   // The drone starts with an initial elevation of 1m
-	currentElevation := 1 // Start the drone at an elevation of 1 meter
-	landingPoint := map[string]int{}
-
-	// Start from plot (1,1)
-	for y := 1; y <= estateWidth; y++ {
-		// Move horizontally across the row (zigzag pattern)
-		for x := 1; x <= estateLength; x++ {
-			// Find the tree in this plot (or no tree if there's none)
-			tree := findTreeAtPlot(trees, x, y)
-
-			// If there's a tree, adjust the elevation accordingly
-			if tree != nil {
-				heightDifference := int(math.Abs(float64(tree.Height - currentElevation)))
-
-				// Update the travel distance with the elevation difference
-				travelDistance += heightDifference
-
-				// Update the current elevation to match the tree's height + 1
-				currentElevation = tree.Height + 1
-			}
-
-			// After each plot move, add 10 meters to the travel distance
-			travelDistance += 10
-
-			// Check if the drone has exceeded or reached the max distance
-			if travelDistance >= maxDistance {
-				// Found the landing point where the drone stops
-				landingPoint = map[string]int{"x": x, "y": y}
-				return landingPoint
-			}
-		}
-
-		// After reaching the end of a row, zigzag back to the previous row
-		estateLength, estateWidth = estateWidth, estateLength
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting block", "estate_id", request.Id, "block_id", *request.Params.BlockId, "error", err)
+		return generated.GetEstateIdDronePlanWithMaxDistance500JSONResponse{Message: "internal server error"}, nil
+	}
+	drone := s.droneOptions(estate)
+	etag := planETag(estate, trees, ground, block, drone, request.Params.MaxDistance)
+	if noneMatch(request.Params.IfNoneMatch, etag) {
+		return generated.GetEstateIdDronePlanWithMaxDistance304Response{
+			Headers: generated.GetEstateIdDronePlanWithMaxDistance304ResponseHeaders{ETag: etag},
+		}, nil
 	}
 
-	// If we exit the loop and the max distance wasn't reached, return the last plot
-	totalPlots := estateLength * estateWidth
-	lastPlotX := (totalPlots - 1) % estateLength
-	lastPlotY := (totalPlots - 1) / estateLength
-	landingPoint = map[string]int{"x": lastPlotX + 1, "y": lastPlotY + 1}
-
-	return landingPoint
-}
-
-// Helper function to find the tree at a specific plot coordinate
-func findTreeAtPlot(trees []repository.Tree, x, y int) *repository.Tree {
-	for _, tree := range trees {
-		if tree.X == x && tree.Y == y {
-			return &tree
-		}
+	start := time.Now()
+	// Flat estates are checked against the distance of GetEstateIdDronePlan,
+	// estates with terrain once the flight is walked.
+	if ground.Flat() && request.Params.MaxDistance > planDistance(drone, trees, estate.Length, estate.Width) {
+		return generated.GetEstateIdDronePlanWithMaxDistance400JSONResponse{Message: "invalid max_distance"}, nil
 	}
-	return nil // No tree at this plot
+	return s.landingPoint(ctx, estate, trees, ground, block, request.Params.MaxDistance, etag, start)
 }
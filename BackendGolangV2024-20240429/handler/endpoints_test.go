@@ -1,7 +1,354 @@
 package handler
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*Server, *repository.MockRepositoryInterface) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	return NewServer(NewServerOptions{Repository: repo}), repo
+}
 
 func TestGetHello(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	resp, err := s.GetHello(context.Background(), generated.GetHelloRequestObject{
+		Params: generated.GetHelloParams{Id: 123},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetHello200JSONResponse{Message: "Hello User 123"}, resp)
+}
+
+func TestPostEstate(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	input := repository.EstateRequest{Length: 10, Width: 20}
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), input).Return(nil)
+	repo.EXPECT().InsertEstate(gomock.Any(), input).Return(repository.EstateResponse{Id: id}, nil)
+
+	resp, err := s.PostEstate(context.Background(), generated.PostEstateRequestObject{
+		Body: &generated.EstateRequest{Length: 10, Width: 20},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostEstate200JSONResponse{Id: id}, resp)
+}
+
+func TestPostEstateInvalid(t *testing.T) {
+	s, repo := newTestServer(t)
+	input := repository.EstateRequest{Length: -1, Width: -5}
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), input).Return(errors.New("length (-1) can not less than 0 "))
+
+	resp, err := s.PostEstate(context.Background(), generated.PostEstateRequestObject{
+		Body: &generated.EstateRequest{Length: -1, Width: -5},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostEstate400JSONResponse{}, resp)
+}
+
+func TestPostTreeInvalidEstateId(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{
+		Id:   "not-a-uuid",
+		Body: ptr(plotTree(1, 1, 10)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTree400JSONResponse{Message: "Invalid estate ID"}, resp)
+}
+
+// expectTx lets WithTx run its callback against the same mock.
+func expectTx(repo *repository.MockRepositoryInterface) {
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+}
+
+func TestPostTree(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	id := uuid.New()
+	input := repository.TreeRequest{EstateId: estateId, X: 1, Y: 2, Height: 10}
+
+	gomock.InOrder(
+		repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, input).Return(nil),
+		repo.EXPECT().InsertTree(gomock.Any(), input).Return(repository.TreeResponse{Id: id}, nil),
+	)
+
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{
+		Id:   estateId,
+		Body: ptr(plotTree(1, 2, 10)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTree200JSONResponse{Id: id}, resp)
+}
+
+func TestPostTreeLostRace(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), gomock.Any()).Return(repository.TreeResponse{}, repository.ErrPlotOccupied)
+
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{
+		Id:   estateId,
+		Body: ptr(plotTree(1, 2, 10)),
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostTree400JSONResponse{}, resp)
+}
+
+func TestPostTreesRejectsWholeBatch(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	rejected := &repository.ValidationError{Err: errors.New("x (11) exceeds estate length (10)")}
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), gomock.Any()).Return(repository.TreeResponse{Id: uuid.New()}, nil)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(rejected)
+
+	resp, err := s.PostTrees(context.Background(), generated.PostTreesRequestObject{
+		Id: estateId,
+		Body: &generated.BulkTreeRequest{Trees: []generated.TreeRequest{
+			plotTree(1, 1, 10),
+			plotTree(11, 1, 10),
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTrees400JSONResponse{Message: "tree 1: " + rejected.Error()}, resp)
+}
+
+func TestPostTreeDatabaseError(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(&pq.Error{Code: "40P01"})
+
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{Id: estateId, Body: ptr(plotTree(1, 1, 10))})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTree500JSONResponse{Message: "Failed to add tree"}, resp)
+}
+
+func TestPatchTreeDatabaseError(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.NewString(), uuid.NewString()
+	input := repository.TreeUpdate{EstateId: estateId, Id: treeId, Height: ptr(12), Version: 1}
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), input).Return(fmt.Errorf("checking tree: %w", &pq.Error{Code: "40P01"}))
+
+	resp, err := s.PatchTree(context.Background(), generated.PatchTreeRequestObject{
+		Id:     estateId,
+		TreeId: treeId,
+		Params: generated.PatchTreeParams{IfMatch: ifMatch(1)},
+		Body:   &generated.TreeUpdateRequest{Height: ptr(12)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PatchTree500JSONResponse{Message: "internal server error"}, resp)
+}
+
+func plotTree(x, y, height int) generated.TreeRequest {
+	return generated.TreeRequest{X: &x, Y: &y, Height: height}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func ifMatch(version int) *string {
+	etag := versionETag(version)
+	return &etag
+}
+
+func TestPatchEstate(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	id := uuid.New()
+	input := repository.EstateRequest{Length: 5, Width: 5}
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), input).Return(nil)
+	gomock.InOrder(
+		repo.EXPECT().LockEstate(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 10, Width: 10, Version: 3}, nil),
+		repo.EXPECT().CountTreesOutside(gomock.Any(), id.String(), 5, 5).Return(0, nil),
+		repo.EXPECT().CountBlocksOutside(gomock.Any(), id.String(), 5, 5).Return(0, nil),
+		repo.EXPECT().UpdateEstate(gomock.Any(), id.String(), input, 3).Return(repository.EstateData{Id: id, Length: 5, Width: 5, Version: 4}, nil),
+	)
+
+	resp, err := s.PatchEstate(context.Background(), generated.PatchEstateRequestObject{
+		Id:     id.String(),
+		Params: generated.PatchEstateParams{IfMatch: ifMatch(3)},
+		Body:   &generated.EstateRequest{Length: 5, Width: 5},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PatchEstate200JSONResponse{
+		Body:    generated.Estate{Id: id, Length: 5, Width: 5, Version: 4},
+		Headers: generated.PatchEstate200ResponseHeaders{ETag: `"4"`},
+	}, resp)
+}
+
+func TestPatchEstateTreesOutside(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	id := uuid.NewString()
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), gomock.Any()).Return(nil)
+	repo.EXPECT().LockEstate(gomock.Any(), id).Return(repository.EstateData{Length: 10, Width: 10, Version: 1}, nil)
+	repo.EXPECT().CountTreesOutside(gomock.Any(), id, 5, 5).Return(2, nil)
+
+	resp, err := s.PatchEstate(context.Background(), generated.PatchEstateRequestObject{
+		Id:     id,
+		Params: generated.PatchEstateParams{IfMatch: ifMatch(1)},
+		Body:   &generated.EstateRequest{Length: 5, Width: 5},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PatchEstate409JSONResponse{}, resp)
+}
+
+func TestPatchEstatePreconditions(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	id := uuid.NewString()
+	body := &generated.EstateRequest{Length: 5, Width: 5}
+
+	resp, err := s.PatchEstate(context.Background(), generated.PatchEstateRequestObject{Id: id, Body: body})
+	require.NoError(t, err)
+	require.IsType(t, generated.PatchEstate428JSONResponse{}, resp)
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), gomock.Any()).Return(nil)
+	repo.EXPECT().LockEstate(gomock.Any(), id).Return(repository.EstateData{Length: 10, Width: 10, Version: 2}, nil)
+
+	resp, err = s.PatchEstate(context.Background(), generated.PatchEstateRequestObject{
+		Id:     id,
+		Params: generated.PatchEstateParams{IfMatch: ifMatch(1)},
+		Body:   body,
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PatchEstate412JSONResponse{}, resp)
+}
+
+func TestPatchTreeStale(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.NewString(), uuid.NewString()
+	input := repository.TreeUpdate{EstateId: estateId, Id: treeId, Height: ptr(12), Version: 1}
+
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), input).Return(nil)
+	repo.EXPECT().UpdateTree(gomock.Any(), input).Return(repository.TreeData{}, repository.ErrVersionMismatch)
+
+	resp, err := s.PatchTree(context.Background(), generated.PatchTreeRequestObject{
+		Id:     estateId,
+		TreeId: treeId,
+		Params: generated.PatchTreeParams{IfMatch: ifMatch(1)},
+		Body:   &generated.TreeUpdateRequest{Height: ptr(12)},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PatchTree412JSONResponse{}, resp)
+}
+
+func TestGetTreeNotModified(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.New(), uuid.New()
+
+	repo.EXPECT().GetTreeById(gomock.Any(), estateId.String(), treeId.String()).Times(2).
+		Return(repository.TreeData{Id: treeId, EstateId: estateId.String(), X: 1, Y: 1, Height: 5, Version: 2}, nil)
+
+	resp, err := s.GetTree(context.Background(), generated.GetTreeRequestObject{Id: estateId.String(), TreeId: treeId.String()})
+	require.NoError(t, err)
+	ok, isOk := resp.(generated.GetTree200JSONResponse)
+	require.True(t, isOk)
+	require.Equal(t, `"2"`, ok.Headers.ETag)
+
+	resp, err = s.GetTree(context.Background(), generated.GetTreeRequestObject{
+		Id:     estateId.String(),
+		TreeId: treeId.String(),
+		Params: generated.GetTreeParams{IfNoneMatch: &ok.Headers.ETag},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetTree304Response{Headers: generated.GetTree304ResponseHeaders{ETag: `"2"`}}, resp)
+}
+
+func TestGetStatsNotModified(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+	stats := repository.EstateStats{Count: 3, MaxHeight: 20, MinHeight: 10, Median: 10}
+
+	repo.EXPECT().GetEstateStats(gomock.Any(), id).Times(3).Return(stats, nil)
+
+	resp, err := s.GetStats(context.Background(), generated.GetStatsRequestObject{Id: id})
+	require.NoError(t, err)
+	etag := resp.(generated.GetStats200JSONResponse).Headers.ETag
+
+	resp, err = s.GetStats(context.Background(), generated.GetStatsRequestObject{
+		Id:     id,
+		Params: generated.GetStatsParams{IfNoneMatch: &etag},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetStats304Response{}, resp)
+
+	list := `"other", W/` + etag
+	resp, err = s.GetStats(context.Background(), generated.GetStatsRequestObject{
+		Id:     id,
+		Params: generated.GetStatsParams{IfNoneMatch: &list},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetStats304Response{}, resp)
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	header := func(v string) *string { return &v }
+
+	_, err := ifMatchVersion(nil)
+	require.ErrorIs(t, err, errPreconditionRequired)
+	version, err := ifMatchVersion(header(`"7"`))
+	require.NoError(t, err)
+	require.Equal(t, 7, version)
+	version, err = ifMatchVersion(header("*"))
+	require.NoError(t, err)
+	require.Equal(t, repository.AnyVersion, version)
+	_, err = ifMatchVersion(header(`W/"7"`))
+	require.ErrorIs(t, err, errPreconditionFailed)
+}
+
+func TestGetStatsNotFound(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+
+	repo.EXPECT().GetEstateStats(gomock.Any(), id).Return(repository.EstateStats{}, repository.ErrEstateNotFound)
+
+	resp, err := s.GetStats(context.Background(), generated.GetStatsRequestObject{Id: id})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetStats404JSONResponse{}, resp)
+}
+
+func TestGetEstateIdDronePlan(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 5, Width: 1}, nil)
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return([]repository.Tree{
+		{X: 2, Y: 1, Height: 10},
+		{X: 3, Y: 1, Height: 20},
+		{X: 4, Y: 1, Height: 10},
+	}, nil)
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound)
 
+	resp, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 82}, resp.(generated.GetEstateIdDronePlan200JSONResponse).Body)
 }
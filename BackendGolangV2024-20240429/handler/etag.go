@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+var (
+	errPreconditionRequired = errors.New("If-Match header is required")
+	errPreconditionFailed   = errors.New("the resource was changed, reload it and retry")
+)
+
+// versionETag formats the version of an estate or tree as a strong ETag.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// contentETag derives a strong ETag from a value that is computed rather than
+// stored, such as statistics or a drone plan, or from the inputs that fully
+// determine it.
+func contentETag(v any) string {
+	body, _ := json.Marshal(v)
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ifMatchVersion returns the version an If-Match header asks to change.
+// "*" matches any version. A missing header yields errPreconditionRequired and
+// anything but a single strong version ETag errPreconditionFailed, since it
+// can never match.
+func ifMatchVersion(header *string) (int, error) {
+	if header == nil || strings.TrimSpace(*header) == "" {
+		return 0, errPreconditionRequired
+	}
+	tag := strings.TrimSpace(*header)
+	if tag == "*" {
+		return repository.AnyVersion, nil
+	}
+	unquoted, ok := strings.CutPrefix(tag, `"`)
+	if !ok {
+		return 0, errPreconditionFailed
+	}
+	unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	if !ok {
+		return 0, errPreconditionFailed
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, errPreconditionFailed
+	}
+	return version, nil
+}
+
+// noneMatch reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match.
+func noneMatch(header *string, etag string) bool {
+	if header == nil {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(*header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
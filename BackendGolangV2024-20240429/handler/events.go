@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// eventStreamHeartbeat keeps idle streams open through proxies.
+	eventStreamHeartbeat = 15 * time.Second
+	// eventStreamBatch is how many events are read from the outbox at once.
+	eventStreamBatch = 100
+	// eventStreamRetry is the reconnection delay suggested to clients, in
+	// milliseconds.
+	eventStreamRetry = 3000
+	// eventStreamPoll is how soon events held back behind an open
+	// transaction are read again, as their transaction may not notify.
+	eventStreamPoll = time.Second
+	statsEvent      = "stats"
+)
+
+// (GET /estate/{id}/events)
+func (s *Server) GetEstateEvents(ctx context.Context, request generated.GetEstateEventsRequestObject) (generated.GetEstateEventsResponseObject, error) {
+	estateId, err := uuid.Parse(request.Id)
+	if err != nil {
+		return generated.GetEstateEvents404JSONResponse{Message: "estate not found"}, nil
+	}
+	var last repository.EventPosition
+	if request.Params.LastEventID != nil {
+		last, err = repository.ParseEventPosition(strings.TrimSpace(*request.Params.LastEventID))
+		if err != nil {
+			return generated.GetEstateEvents400JSONResponse{Message: "invalid Last-Event-ID"}, nil
+		}
+	} else {
+		// A new stream only sends changes made after the stats it starts
+		// with, so read the position first. Changes still held back are
+		// sent although the stats may count them.
+		last, err = s.Repository.LastEventPosition(ctx, request.Id)
+		if err != nil {
+			s.Logger.ErrorContext(ctx, "getting last event", "estate_id", request.Id, "error", err)
+			return generated.GetEstateEvents500JSONResponse{Message: "internal server error"}, nil
+		}
+	}
+	stats, err := s.Repository.GetEstateStats(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstateEvents404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate stats", "estate_id", request.Id, "error", err)
+		return generated.GetEstateEvents500JSONResponse{Message: "internal server error"}, nil
+	}
+	return &estateEventStream{
+		ctx:      ctx,
+		server:   s,
+		estateId: estateId,
+		last:     last,
+		stats:    statsBody(stats),
+	}, nil
+}
+
+// estateEventStream writes the server-sent events of an estate until the
+// client goes away, the estate is deleted or the server shuts down. The strict
+// handler only hands it the response writer, so it keeps the request context.
+type estateEventStream struct {
+	ctx      context.Context
+	server   *Server
+	estateId uuid.UUID
+	last     repository.EventPosition
+	stats    generated.EstateStatsResponse
+	// pending is set while events are held back behind an open transaction.
+	pending bool
+}
+
+func (st *estateEventStream) VisitGetEstateEventsResponse(w http.ResponseWriter) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("response does not support streaming")
+	}
+	// Subscribe before catching up, so no notification falls in between.
+	subscription := st.server.Events.Subscribe(st.estateId)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry)
+	if err := writeEvent(w, "", statsEvent, st.stats); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		done, err := st.sendNewEvents(w)
+		if err != nil {
+			// The client reconnects with Last-Event-ID and resumes.
+			st.server.Logger.WarnContext(st.ctx, "streaming events", "estate_id", st.estateId, "error", err)
+			return nil
+		}
+		flusher.Flush()
+		if done {
+			return nil
+		}
+
+		var poll <-chan time.Time
+		if st.pending {
+			poll = time.After(eventStreamPoll)
+		}
+		select {
+		case <-st.ctx.Done():
+			return nil
+		case <-poll:
+		case _, ok := <-subscription.C:
+			if !ok {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// sendNewEvents writes the events after the last position followed by
+// refreshed stats. It reports done once the estate was deleted.
+func (st *estateEventStream) sendNewEvents(w io.Writer) (done bool, err error) {
+	sent := 0
+	for {
+		page, pending, err := st.server.Repository.ListEvents(st.ctx, st.estateId.String(), st.last, eventStreamBatch)
+		if err != nil {
+			return false, err
+		}
+		st.pending = pending
+		for _, event := range page {
+			if err := writeEvent(w, event.Position.String(), event.Type, event); err != nil {
+				return false, err
+			}
+			st.last = event.Position
+			if event.Type == repository.EventEstateDeleted {
+				return true, nil
+			}
+		}
+		sent += len(page)
+		if pending || len(page) < eventStreamBatch {
+			break
+		}
+	}
+	if sent == 0 {
+		return false, nil
+	}
+
+	stats, err := st.server.Repository.GetEstateStats(st.ctx, st.estateId.String())
+	if err != nil {
+		return false, err
+	}
+	return false, writeEvent(w, "", statsEvent, statsBody(stats))
+}
+
+// writeEvent writes a single server-sent event; an empty id leaves the
+// client's last event id unchanged.
+func writeEvent(w io.Writer, id string, name string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, body)
+	return err
+}
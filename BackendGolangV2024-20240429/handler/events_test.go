@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEstateEventsStreamsUntilDeleted(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.New()
+	lastEventId := "9-5"
+
+	gomock.InOrder(
+		repo.EXPECT().GetEstateStats(gomock.Any(), estateId.String()).
+			Return(repository.EstateStats{Count: 1, MaxHeight: 10, MinHeight: 10, Median: 10}, nil),
+		repo.EXPECT().ListEvents(gomock.Any(), estateId.String(), repository.EventPosition{Xid: 9, Id: 5}, eventStreamBatch).
+			DoAndReturn(func(context.Context, string, repository.EventPosition, int) ([]repository.Event, bool, error) {
+				s.Events.Notify(estateId)
+				return []repository.Event{testEvent(estateId, 9, 6, repository.EventTreeCreated)}, false, nil
+			}),
+		repo.EXPECT().GetEstateStats(gomock.Any(), estateId.String()).
+			Return(repository.EstateStats{Count: 2, MaxHeight: 20, MinHeight: 10, Median: 15}, nil),
+		repo.EXPECT().ListEvents(gomock.Any(), estateId.String(), repository.EventPosition{Xid: 9, Id: 6}, eventStreamBatch).
+			Return([]repository.Event{testEvent(estateId, 10, 7, repository.EventEstateDeleted)}, false, nil),
+	)
+
+	resp, err := s.GetEstateEvents(context.Background(), generated.GetEstateEventsRequestObject{
+		Id:     estateId.String(),
+		Params: generated.GetEstateEventsParams{LastEventID: &lastEventId},
+	})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, resp.VisitGetEstateEventsResponse(rec))
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	require.Contains(t, body, "event: stats\ndata: {\"count\":1,")
+	require.Contains(t, body, "id: 9-6\nevent: tree.created\ndata: {\"id\":6,")
+	require.Contains(t, body, "event: stats\ndata: {\"count\":2,")
+	require.Contains(t, body, "id: 10-7\nevent: estate.deleted\n")
+}
+
+func TestGetEstateEventsPollsHeldBackEvents(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.New()
+
+	gomock.InOrder(
+		repo.EXPECT().LastEventPosition(gomock.Any(), estateId.String()).Return(repository.EventPosition{}, nil),
+		repo.EXPECT().GetEstateStats(gomock.Any(), estateId.String()).Return(repository.EstateStats{}, nil),
+		// Nothing notifies once the transaction holding the event back
+		// ends, so the stream reads again by itself.
+		repo.EXPECT().ListEvents(gomock.Any(), estateId.String(), repository.EventPosition{}, eventStreamBatch).
+			Return(nil, true, nil),
+		repo.EXPECT().ListEvents(gomock.Any(), estateId.String(), repository.EventPosition{}, eventStreamBatch).
+			Return([]repository.Event{testEvent(estateId, 3, 4, repository.EventEstateDeleted)}, false, nil),
+		repo.EXPECT().GetEstateStats(gomock.Any(), estateId.String()).Return(repository.EstateStats{}, nil).AnyTimes(),
+	)
+
+	resp, err := s.GetEstateEvents(context.Background(), generated.GetEstateEventsRequestObject{Id: estateId.String()})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, resp.VisitGetEstateEventsResponse(rec))
+	require.Contains(t, rec.Body.String(), "id: 3-4\nevent: estate.deleted\n")
+}
+
+func testEvent(estateId uuid.UUID, xid, id int64, eventType string) repository.Event {
+	return repository.Event{
+		Id:         id,
+		Type:       eventType,
+		EstateId:   estateId,
+		Data:       json.RawMessage(`{}`),
+		OccurredAt: time.Now().UTC(),
+		Position:   repository.EventPosition{Xid: xid, Id: id},
+	}
+}
+
+func TestGetEstateEventsInvalidLastEventId(t *testing.T) {
+	s, _ := newTestServer(t)
+	lastEventId := "5"
+	resp, err := s.GetEstateEvents(context.Background(), generated.GetEstateEventsRequestObject{
+		Id:     uuid.NewString(),
+		Params: generated.GetEstateEventsParams{LastEventID: &lastEventId},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetEstateEvents400JSONResponse{}, resp)
+}
+
+func TestGetEstateEventsEstateNotFound(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+	repo.EXPECT().LastEventPosition(gomock.Any(), id).Return(repository.EventPosition{}, nil)
+	repo.EXPECT().GetEstateStats(gomock.Any(), id).Return(repository.EstateStats{}, repository.ErrEstateNotFound)
+
+	resp, err := s.GetEstateEvents(context.Background(), generated.GetEstateEventsRequestObject{Id: id})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetEstateEvents404JSONResponse{}, resp)
+}
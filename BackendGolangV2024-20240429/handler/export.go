@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/SawitProRecruitment/UserService/flightplan"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+)
+
+// (GET /estate/{id}/missions/{mission_id}/export)
+func (s *Server) GetMissionExport(ctx context.Context, request generated.GetMissionExportRequestObject) (generated.GetMissionExportResponseObject, error) {
+	params := request.Params
+	format := flightplan.Format(params.Format)
+	if !validFormat(format) {
+		return generated.GetMissionExport400JSONResponse{Message: "format must be qgc, wpl, kml, gpx or csv"}, nil
+	}
+
+	mission, err := s.Repository.GetMission(ctx, request.Id, request.MissionId)
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.GetMissionExport404JSONResponse{Message: "mission not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting mission", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.GetMissionExport500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	var ref geo.Reference
+	switch {
+	case params.Latitude != nil && params.Longitude != nil:
+		ref = geo.Reference{
+			Latitude:       *params.Latitude,
+			Longitude:      *params.Longitude,
+			Bearing:        geo.DefaultBearing,
+			PlotSizeMeters: mission.PlotSizeMeters,
+		}
+		if params.Bearing != nil {
+			ref.Bearing = *params.Bearing
+		}
+		if err := ref.Validate(); err != nil {
+			return generated.GetMissionExport400JSONResponse{Message: err.Error()}, nil
+		}
+	case params.Latitude != nil || params.Longitude != nil:
+		return generated.GetMissionExport400JSONResponse{Message: "latitude and longitude are required together"}, nil
+	default:
+		estate, err := s.Repository.GetEstateById(ctx, request.Id)
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return generated.GetMissionExport404JSONResponse{Message: "mission not found"}, nil
+		}
+		if err != nil {
+			s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+			return generated.GetMissionExport500JSONResponse{Message: "internal server error"}, nil
+		}
+		if estate.GeoReference == nil {
+			return generated.GetMissionExport400JSONResponse{
+				Message: "latitude and longitude are required for estates that are not geo-referenced",
+			}, nil
+		}
+		ref = *estate.GeoReference
+	}
+
+	// Only KML places waypoints over the ground; the other formats keep
+	// altitudes relative to the take-off point.
+	var ground terrain.Model
+	if format == flightplan.KML {
+		grid, err := s.Repository.GetTerrain(ctx, request.Id)
+		if err != nil && !errors.Is(err, repository.ErrTerrainNotFound) {
+			s.Logger.ErrorContext(ctx, "getting terrain", "estate_id", request.Id, "error", err)
+			return generated.GetMissionExport500JSONResponse{Message: "internal server error"}, nil
+		}
+		if err == nil {
+			ground = terrain.NewModel(grid, mission.PlotSizeMeters)
+		}
+	}
+
+	var file bytes.Buffer
+	name := "mission-" + mission.Id.String()
+	if err := flightplan.Write(&file, format, name, flightplan.Place(mission.Path, ref, ground)); err != nil {
+		return nil, err
+	}
+	return flightPlanFile{name: name + format.Extension(), format: format, body: file.Bytes()}, nil
+}
+
+func validFormat(format flightplan.Format) bool {
+	for _, f := range flightplan.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// flightPlanFile is an exported flight plan. The generated responses fix one
+// content type each, so the format picks it here instead.
+type flightPlanFile struct {
+	name   string
+	format flightplan.Format
+	body   []byte
+}
+
+func (f flightPlanFile) VisitGetMissionExportResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", f.format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.body)))
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(f.body)
+	return err
+}
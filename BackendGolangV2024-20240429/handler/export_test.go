@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMissionExport(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+	mission := repository.Mission{
+		Id:             uuid.MustParse(missionId),
+		PlotSizeMeters: 10,
+		Path:           []repository.Waypoint{{X: 1, Y: 1, Altitude: 1}, {X: 2, Y: 1, Altitude: 1}},
+	}
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(mission, nil)
+
+	resp, err := s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id:        estateId,
+		MissionId: missionId,
+		Params:    generated.GetMissionExportParams{Format: generated.Wpl, Latitude: ptr(1.5), Longitude: ptr(101.25)},
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, resp.VisitGetMissionExportResponse(w))
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `attachment; filename="mission-`+missionId+`.waypoints"`, w.Header().Get("Content-Disposition"))
+	require.True(t, strings.HasPrefix(w.Body.String(), "QGC WPL 110\n0\t1\t0\t16\t0\t0\t0\t0\t1.50000000\t101.25000000\t"))
+}
+
+func TestGetMissionExportGeoReferencedEstate(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+	mission := repository.Mission{
+		Id:             uuid.MustParse(missionId),
+		PlotSizeMeters: 10,
+		Path:           []repository.Waypoint{{X: 1, Y: 1, Altitude: 1}, {X: 1, Y: 2, Altitude: 1}},
+	}
+	// The estate's own plot size wins over the planner's.
+	ref := &geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: 0, PlotSizeMeters: 20}
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(mission, nil)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{GeoReference: ref}, nil)
+
+	resp, err := s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id:        estateId,
+		MissionId: missionId,
+		Params:    generated.GetMissionExportParams{Format: generated.Csv},
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, resp.VisitGetMissionExportResponse(w))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	// With the x axis pointing north, y points west.
+	latitude, longitude := ref.ToWGS84(1, 2)
+	require.Less(t, longitude, 101.25)
+	require.Equal(t, fmt.Sprintf("2,1,2,%.8f,%.8f,1", latitude, longitude), lines[2])
+}
+
+func TestGetMissionExportKMLOverTerrain(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+	mission := repository.Mission{
+		Id:             uuid.MustParse(missionId),
+		PlotSizeMeters: 10,
+		Path:           []repository.Waypoint{{X: 1, Y: 1, Altitude: 1}, {X: 2, Y: 1, Altitude: 8}},
+	}
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(mission, nil)
+	repo.EXPECT().GetTerrain(gomock.Any(), estateId).Return(repository.Terrain{
+		Columns: 2, Rows: 1, XLowerLeft: -5, YLowerLeft: -5, CellSize: 10, Elevations: []float64{40, 47},
+	}, nil)
+
+	resp, err := s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id:        estateId,
+		MissionId: missionId,
+		Params:    generated.GetMissionExportParams{Format: generated.Kml, Latitude: ptr(1.5), Longitude: ptr(101.25)},
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, resp.VisitGetMissionExportResponse(w))
+	require.Equal(t, 200, w.Code)
+	// Plot (2,1) lies 7 meters above the take-off point.
+	require.Contains(t, w.Body.String(), "<coordinates>101.25000000,1.50000000,1 101.25008986,1.50000000,1</coordinates>")
+}
+
+func TestGetMissionExportRejected(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+
+	resp, err := s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id: estateId, MissionId: missionId,
+		Params: generated.GetMissionExportParams{Format: "shp"},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetMissionExport400JSONResponse{}, resp)
+
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(repository.Mission{PlotSizeMeters: 10}, nil)
+	resp, err = s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id: estateId, MissionId: missionId,
+		Params: generated.GetMissionExportParams{Format: generated.Kml, Latitude: ptr(91.0), Longitude: ptr(0.0)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetMissionExport400JSONResponse{Message: "latitude must be between -90 and 90"}, resp)
+
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(repository.Mission{PlotSizeMeters: 10}, nil)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{}, nil)
+	resp, err = s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id: estateId, MissionId: missionId,
+		Params: generated.GetMissionExportParams{Format: generated.Csv},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetMissionExport400JSONResponse{
+		Message: "latitude and longitude are required for estates that are not geo-referenced",
+	}, resp)
+
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(repository.Mission{}, repository.ErrMissionNotFound)
+	resp, err = s.GetMissionExport(context.Background(), generated.GetMissionExportRequestObject{
+		Id: estateId, MissionId: missionId,
+		Params: generated.GetMissionExportParams{Format: generated.Gpx},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetMissionExport404JSONResponse{}, resp)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// errNotGeoReferenced is returned for coordinates given for an estate that is
+// not placed on the globe.
+var errNotGeoReferenced = errors.New("estate is not geo-referenced")
+
+// (GET /estate/{id}/geo/to-wgs84)
+func (s *Server) GetPlotToWgs84(ctx context.Context, request generated.GetPlotToWgs84RequestObject) (generated.GetPlotToWgs84ResponseObject, error) {
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetPlotToWgs84404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.GetPlotToWgs84500JSONResponse{Message: "internal server error"}, nil
+	}
+	if estate.GeoReference == nil {
+		return generated.GetPlotToWgs84409JSONResponse{Message: errNotGeoReferenced.Error()}, nil
+	}
+	latitude, longitude := estate.GeoReference.ToWGS84(request.Params.X, request.Params.Y)
+	return generated.GetPlotToWgs84200JSONResponse{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// (GET /estate/{id}/geo/to-plot)
+func (s *Server) GetWgs84ToPlot(ctx context.Context, request generated.GetWgs84ToPlotRequestObject) (generated.GetWgs84ToPlotResponseObject, error) {
+	params := request.Params
+	if err := geo.CheckCoordinates(params.Latitude, params.Longitude); err != nil {
+		return generated.GetWgs84ToPlot400JSONResponse{Message: err.Error()}, nil
+	}
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetWgs84ToPlot404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.GetWgs84ToPlot500JSONResponse{Message: "internal server error"}, nil
+	}
+	if estate.GeoReference == nil {
+		return generated.GetWgs84ToPlot409JSONResponse{Message: errNotGeoReferenced.Error()}, nil
+	}
+	x, y := estate.GeoReference.FromWGS84(params.Latitude, params.Longitude)
+	body := generated.GetWgs84ToPlot200JSONResponse{X: x, Y: y}
+	plotX, plotY := math.Round(x), math.Round(y)
+	if plotX >= 1 && plotX <= float64(estate.Length) && plotY >= 1 && plotY <= float64(estate.Width) {
+		body.Plot = &generated.Plot{X: int(plotX), Y: int(plotY)}
+	}
+	return body, nil
+}
+
+// geoReference fills in the defaults of a requested geo-reference.
+func geoReference(body *generated.GeoReference, drone DroneOptions) *geo.Reference {
+	if body == nil {
+		return nil
+	}
+	ref := &geo.Reference{
+		Latitude:       body.Latitude,
+		Longitude:      body.Longitude,
+		Bearing:        geo.DefaultBearing,
+		PlotSizeMeters: drone.PlotSizeMeters,
+	}
+	if body.Bearing != nil {
+		ref.Bearing = *body.Bearing
+	}
+	if body.PlotSizeMeters != nil {
+		ref.PlotSizeMeters = *body.PlotSizeMeters
+	}
+	return ref
+}
+
+func geoReferenceBody(ref *geo.Reference) *generated.GeoReference {
+	if ref == nil {
+		return nil
+	}
+	return &generated.GeoReference{
+		Latitude:       ref.Latitude,
+		Longitude:      ref.Longitude,
+		Bearing:        &ref.Bearing,
+		PlotSizeMeters: &ref.PlotSizeMeters,
+	}
+}
+
+// placedByCoordinates reports whether a tree is submitted with latitude and
+// longitude rather than a plot.
+func placedByCoordinates(tree generated.TreeRequest) bool {
+	return tree.Latitude != nil || tree.Longitude != nil
+}
+
+// treePlot returns the plot a tree is submitted for: x and y, or latitude and
+// longitude rounded to the nearest plot of ref.
+func treePlot(tree generated.TreeRequest, ref *geo.Reference) (x, y int, err error) {
+	byPlot := tree.X != nil && tree.Y != nil
+	byCoordinates := tree.Latitude != nil && tree.Longitude != nil
+	switch {
+	case byPlot && !placedByCoordinates(tree):
+		return *tree.X, *tree.Y, nil
+	case byCoordinates && tree.X == nil && tree.Y == nil:
+		if ref == nil {
+			return 0, 0, errNotGeoReferenced
+		}
+		if err := geo.CheckCoordinates(*tree.Latitude, *tree.Longitude); err != nil {
+			return 0, 0, err
+		}
+		fx, fy := ref.FromWGS84(*tree.Latitude, *tree.Longitude)
+		return int(math.Round(fx)), int(math.Round(fy)), nil
+	}
+	return 0, 0, errors.New("either x and y or latitude and longitude are required")
+}
+
+// treesReference returns the geo-reference of an estate when one of the trees
+// is placed by coordinates, and nil otherwise.
+func (s *Server) treesReference(ctx context.Context, estateId string, trees ...generated.TreeRequest) (*geo.Reference, error) {
+	for _, tree := range trees {
+		if !placedByCoordinates(tree) {
+			continue
+		}
+		estate, err := s.Repository.GetEstateById(ctx, estateId)
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return nil, invalidInputError{err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return estate.GeoReference, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var estateReference = &geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: geo.DefaultBearing, PlotSizeMeters: 10}
+
+func TestPostEstateGeoReferenceDefaults(t *testing.T) {
+	s, repo := newTestServer(t)
+	input := repository.EstateRequest{Length: 10, Width: 20, GeoReference: estateReference}
+
+	repo.EXPECT().ValidateEstateRequest(gomock.Any(), input).Return(nil)
+	repo.EXPECT().InsertEstate(gomock.Any(), input).Return(repository.EstateResponse{Id: uuid.New()}, nil)
+
+	resp, err := s.PostEstate(context.Background(), generated.PostEstateRequestObject{
+		Body: &generated.EstateRequest{
+			Length:       10,
+			Width:        20,
+			GeoReference: &generated.GeoReference{Latitude: 1.5, Longitude: 101.25},
+		},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostEstate200JSONResponse{}, resp)
+}
+
+func TestGetWgs84ToPlot(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).
+		Return(repository.EstateData{Length: 5, Width: 5, GeoReference: estateReference}, nil).Times(2)
+
+	latitude, longitude := estateReference.ToWGS84(3.2, 1.9)
+	resp, err := s.GetWgs84ToPlot(context.Background(), generated.GetWgs84ToPlotRequestObject{
+		Id:     estateId,
+		Params: generated.GetWgs84ToPlotParams{Latitude: latitude, Longitude: longitude},
+	})
+	require.NoError(t, err)
+	body := resp.(generated.GetWgs84ToPlot200JSONResponse)
+	require.InDelta(t, 3.2, body.X, 1e-6)
+	require.InDelta(t, 1.9, body.Y, 1e-6)
+	require.Equal(t, &generated.Plot{X: 3, Y: 2}, body.Plot)
+
+	latitude, longitude = estateReference.ToWGS84(6, 1)
+	resp, err = s.GetWgs84ToPlot(context.Background(), generated.GetWgs84ToPlotRequestObject{
+		Id:     estateId,
+		Params: generated.GetWgs84ToPlotParams{Latitude: latitude, Longitude: longitude},
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp.(generated.GetWgs84ToPlot200JSONResponse).Plot, "outside the estate")
+}
+
+func TestGetPlotToWgs84NotGeoReferenced(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 5, Width: 5}, nil)
+
+	resp, err := s.GetPlotToWgs84(context.Background(), generated.GetPlotToWgs84RequestObject{
+		Id:     estateId,
+		Params: generated.GetPlotToWgs84Params{X: 1, Y: 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetPlotToWgs84409JSONResponse{Message: "estate is not geo-referenced"}, resp)
+}
+
+func TestPostTreeByCoordinates(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	input := repository.TreeRequest{EstateId: estateId, X: 4, Y: 2, Height: 10}
+
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{GeoReference: estateReference}, nil)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, input).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), input).Return(repository.TreeResponse{Id: uuid.New()}, nil)
+
+	// A GPS fix a few meters off the plot centre.
+	latitude, longitude := estateReference.ToWGS84(4.3, 1.8)
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{
+		Id:   estateId,
+		Body: &generated.TreeRequest{Latitude: &latitude, Longitude: &longitude, Height: 10},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostTree200JSONResponse{}, resp)
+}
+
+func TestTreePlot(t *testing.T) {
+	x, y := 2, 3
+	latitude, longitude := 1.5, 101.25
+
+	_, _, err := treePlot(generated.TreeRequest{Latitude: &latitude, Longitude: &longitude}, nil)
+	require.Equal(t, errNotGeoReferenced, err)
+
+	_, _, err = treePlot(generated.TreeRequest{X: &x, Y: &y, Latitude: &latitude, Longitude: &longitude}, estateReference)
+	require.EqualError(t, err, "either x and y or latitude and longitude are required")
+
+	_, _, err = treePlot(generated.TreeRequest{X: &x}, estateReference)
+	require.Error(t, err)
+
+	plotX, plotY, err := treePlot(generated.TreeRequest{Latitude: &latitude, Longitude: &longitude}, estateReference)
+	require.NoError(t, err)
+	require.Equal(t, [2]int{1, 1}, [2]int{plotX, plotY})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// maxHarvesterLength matches the harvester column.
+const maxHarvesterLength = 100
+
+// (POST /estate/{id}/tree/{tree_id}/harvests)
+func (s *Server) PostHarvest(ctx context.Context, request generated.PostHarvestRequestObject) (generated.PostHarvestResponseObject, error) {
+	if request.Body == nil {
+		return generated.PostHarvest400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	if err := validateHarvest(*request.Body, time.Now()); err != nil {
+		return generated.PostHarvest400JSONResponse{Message: err.Error()}, nil
+	}
+	input := repository.HarvestRequest{
+		EstateId:    request.Id,
+		TreeId:      request.TreeId,
+		HarvestedOn: request.Body.HarvestedOn.Time,
+		Bunches:     request.Body.Bunches,
+		WeightKg:    request.Body.WeightKg,
+	}
+	if request.Body.Harvester != nil {
+		input.Harvester = *request.Body.Harvester
+	}
+
+	harvest, err := s.Repository.InsertHarvest(ctx, input)
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.PostHarvest404JSONResponse{Message: "tree not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "recording harvest", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.PostHarvest500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostHarvest200JSONResponse(harvestBody(harvest)), nil
+}
+
+// validateHarvest checks a harvest against the date of now.
+func validateHarvest(body generated.HarvestRequest, now time.Time) error {
+	if body.HarvestedOn.After(now) {
+		return fmt.Errorf("harvested_on (%s) is in the future", body.HarvestedOn.Format(time.DateOnly))
+	}
+	if body.Bunches < 0 {
+		return errors.New("bunches must not be negative")
+	}
+	if body.WeightKg < 0 {
+		return errors.New("weight_kg must not be negative")
+	}
+	if body.Harvester != nil && len(*body.Harvester) > maxHarvesterLength {
+		return fmt.Errorf("harvester exceeds %d characters", maxHarvesterLength)
+	}
+	return nil
+}
+
+// (GET /estate/{id}/tree/{tree_id}/harvests)
+func (s *Server) ListHarvests(ctx context.Context, request generated.ListHarvestsRequestObject) (generated.ListHarvestsResponseObject, error) {
+	harvests, err := s.Repository.ListHarvests(ctx, request.Id, request.TreeId)
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.ListHarvests404JSONResponse{Message: "tree not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing harvests", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.ListHarvests500JSONResponse{Message: "internal server error"}, nil
+	}
+	response := generated.ListHarvests200JSONResponse{}
+	for _, harvest := range harvests {
+		response = append(response, harvestBody(harvest))
+	}
+	return response, nil
+}
+
+func harvestBody(harvest repository.Harvest) generated.Harvest {
+	body := generated.Harvest{
+		Id:          harvest.Id,
+		EstateId:    harvest.EstateId,
+		TreeId:      harvest.TreeId,
+		HarvestedOn: openapi_types.Date{Time: harvest.HarvestedOn},
+		Bunches:     harvest.Bunches,
+		WeightKg:    harvest.WeightKg,
+		CreatedAt:   harvest.CreatedAt,
+	}
+	if harvest.Harvester != "" {
+		body.Harvester = &harvest.Harvester
+	}
+	return body
+}
+
+// (GET /estate/{id}/yield)
+func (s *Server) GetEstateYield(ctx context.Context, request generated.GetEstateYieldRequestObject) (generated.GetEstateYieldResponseObject, error) {
+	params := request.Params
+	query := repository.YieldQuery{
+		EstateId: request.Id,
+		Period:   repository.YieldMonth,
+		From:     dateTime(params.From),
+		To:       dateTime(params.To),
+	}
+	if params.GroupBy != nil {
+		for _, column := range *params.GroupBy {
+			switch column {
+			case generated.GetEstateYieldParamsGroupByPeriod, generated.GetEstateYieldParamsGroupByRow, generated.GetEstateYieldParamsGroupByTree:
+			default:
+				return generated.GetEstateYield400JSONResponse{Message: fmt.Sprintf("cannot group by %q", column)}, nil
+			}
+			if !slices.Contains(query.GroupBy, string(column)) {
+				query.GroupBy = append(query.GroupBy, string(column))
+			}
+		}
+	}
+	if params.Period != nil {
+		switch *params.Period {
+		case generated.Day, generated.Week, generated.Month, generated.Year:
+			query.Period = string(*params.Period)
+		default:
+			return generated.GetEstateYield400JSONResponse{Message: fmt.Sprintf("unknown period %q", *params.Period)}, nil
+		}
+	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return generated.GetEstateYield400JSONResponse{Message: "from must not be after to"}, nil
+	}
+
+	groups, err := s.Repository.GetEstateYield(ctx, query)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.GetEstateYield404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate yield", "estate_id", request.Id, "error", err)
+		return generated.GetEstateYield500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	// The groups split the harvests, so they add up to the total.
+	response := generated.GetEstateYield200JSONResponse{Groups: []generated.YieldGroup{}}
+	for _, group := range groups {
+		response.Total.Harvests += group.Harvests
+		response.Total.Bunches += group.Bunches
+		response.Total.WeightKg += group.WeightKg
+		if len(query.GroupBy) > 0 {
+			response.Groups = append(response.Groups, yieldGroupBody(group))
+		}
+	}
+	return response, nil
+}
+
+func yieldGroupBody(group repository.YieldGroup) generated.YieldGroup {
+	body := generated.YieldGroup{
+		Row:      group.Row,
+		TreeId:   group.TreeId,
+		X:        group.X,
+		Harvests: group.Harvests,
+		Bunches:  group.Bunches,
+		WeightKg: group.WeightKg,
+	}
+	if group.PeriodStart != nil {
+		body.PeriodStart = &openapi_types.Date{Time: *group.PeriodStart}
+	}
+	return body
+}
+
+func yieldStatsBody(stats repository.YieldStats) *generated.YieldStats {
+	body := &generated.YieldStats{
+		HarvestedTrees: stats.HarvestedTrees,
+		WeightKg:       stats.WeightKg,
+		Correlation:    stats.Correlation,
+		ByHeight:       []generated.HeightYield{},
+	}
+	for _, height := range stats.ByHeight {
+		body.ByHeight = append(body.ByHeight, generated.HeightYield{
+			Height:   height.Height,
+			Trees:    height.Trees,
+			WeightKg: height.WeightKg,
+		})
+	}
+	return body
+}
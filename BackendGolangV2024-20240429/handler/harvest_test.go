@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostHarvest(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.New(), uuid.New()
+	harvestedOn := time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)
+	input := repository.HarvestRequest{
+		EstateId: estateId.String(), TreeId: treeId.String(),
+		HarvestedOn: harvestedOn, Bunches: 2, WeightKg: 41.5, Harvester: "Adi",
+	}
+	repo.EXPECT().InsertHarvest(gomock.Any(), input).Return(repository.Harvest{
+		Id: uuid.New(), EstateId: estateId, TreeId: treeId, HarvestedOn: harvestedOn, Bunches: 2, WeightKg: 41.5, Harvester: "Adi",
+	}, nil)
+
+	request := generated.PostHarvestRequestObject{
+		Id:     estateId.String(),
+		TreeId: treeId.String(),
+		Body: &generated.HarvestRequest{
+			HarvestedOn: openapi_types.Date{Time: harvestedOn}, Bunches: 2, WeightKg: 41.5, Harvester: ptr("Adi"),
+		},
+	}
+	resp, err := s.PostHarvest(context.Background(), request)
+	require.NoError(t, err)
+	body := resp.(generated.PostHarvest200JSONResponse)
+	require.Equal(t, treeId, body.TreeId)
+	require.Equal(t, "Adi", *body.Harvester)
+
+	repo.EXPECT().InsertHarvest(gomock.Any(), gomock.Any()).Return(repository.Harvest{}, repository.ErrTreeNotFound)
+	resp, err = s.PostHarvest(context.Background(), request)
+	require.NoError(t, err)
+	require.IsType(t, generated.PostHarvest404JSONResponse{}, resp)
+}
+
+func TestValidateHarvest(t *testing.T) {
+	now := time.Date(2024, 4, 2, 9, 0, 0, 0, time.UTC)
+	today := openapi_types.Date{Time: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)}
+	require.NoError(t, validateHarvest(generated.HarvestRequest{HarvestedOn: today, Bunches: 1, WeightKg: 20}, now))
+
+	tomorrow := openapi_types.Date{Time: today.AddDate(0, 0, 1)}
+	require.EqualError(t, validateHarvest(generated.HarvestRequest{HarvestedOn: tomorrow}, now),
+		"harvested_on (2024-04-03) is in the future")
+	require.EqualError(t, validateHarvest(generated.HarvestRequest{HarvestedOn: today, WeightKg: -1}, now),
+		"weight_kg must not be negative")
+}
+
+func TestGetEstateYield(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.NewString(), uuid.New()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	resp, err := s.GetEstateYield(context.Background(), generated.GetEstateYieldRequestObject{
+		Id: estateId,
+		Params: generated.GetEstateYieldParams{
+			From: &openapi_types.Date{Time: from},
+			To:   &openapi_types.Date{Time: from.AddDate(0, 0, -1)},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetEstateYield400JSONResponse{Message: "from must not be after to"}, resp)
+
+	repo.EXPECT().GetEstateYield(gomock.Any(), repository.YieldQuery{
+		EstateId: estateId, GroupBy: []string{"period", "tree"}, Period: "month", From: &from,
+	}).Return([]repository.YieldGroup{
+		{PeriodStart: &march, Row: ptr(1), TreeId: &treeId, X: ptr(2), Harvests: 2, Bunches: 3, WeightKg: 60},
+		{PeriodStart: &march, Row: ptr(1), TreeId: ptr(uuid.New()), X: ptr(3), Harvests: 1, Bunches: 1, WeightKg: 18.5},
+	}, nil)
+
+	resp, err = s.GetEstateYield(context.Background(), generated.GetEstateYieldRequestObject{
+		Id: estateId,
+		Params: generated.GetEstateYieldParams{
+			GroupBy: &[]generated.GetEstateYieldParamsGroupBy{"period", "tree"},
+			From:    &openapi_types.Date{Time: from},
+		},
+	})
+	require.NoError(t, err)
+	report := resp.(generated.GetEstateYield200JSONResponse)
+	require.Equal(t, generated.YieldGroup{Harvests: 3, Bunches: 4, WeightKg: 78.5}, report.Total)
+	require.Len(t, report.Groups, 2)
+	require.Equal(t, "2024-03-01", report.Groups[0].PeriodStart.String())
+	require.Equal(t, &treeId, report.Groups[0].TreeId)
+}
+
+func TestGetStatsIncludeYield(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+	correlation := 0.8
+	repo.EXPECT().GetEstateStats(gomock.Any(), id).Return(repository.EstateStats{Count: 2, MaxHeight: 12, MinHeight: 8, Median: 10}, nil)
+	repo.EXPECT().GetEstateYieldStats(gomock.Any(), id).Return(repository.YieldStats{
+		HarvestedTrees: 2, WeightKg: 90, Correlation: &correlation,
+		ByHeight: []repository.HeightYield{{Height: 8, Trees: 1, WeightKg: 30}, {Height: 12, Trees: 1, WeightKg: 60}},
+	}, nil)
+
+	resp, err := s.GetStats(context.Background(), generated.GetStatsRequestObject{
+		Id:     id,
+		Params: generated.GetStatsParams{IncludeYield: ptr(true)},
+	})
+	require.NoError(t, err)
+	yield := resp.(generated.GetStats200JSONResponse).Body.Yield
+	require.Equal(t, &generated.YieldStats{
+		HarvestedTrees: 2, WeightKg: 90, Correlation: &correlation,
+		ByHeight: []generated.HeightYield{{Height: 8, Trees: 1, WeightKg: 30}, {Height: 12, Trees: 1, WeightKg: 60}},
+	}, yield)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// healthCheckTimeout bounds each readiness check so a hanging dependency is
+// reported as failed instead of blocking the probe.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck is a named readiness check. A nil error means healthy.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// SetReady marks whether startup finished. Until it is called with true, and
+// again after it is called with false during shutdown, /readyz answers 503.
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// defaultHealthChecks are the readiness checks every server runs.
+func (s *Server) defaultHealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{Name: "database", Check: s.Repository.Ping},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			version, err := s.Repository.GetSchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if version < repository.SchemaVersion {
+				return fmt.Errorf("schema version %d is older than the required %d", version, repository.SchemaVersion)
+			}
+			return nil
+		}},
+	}
+}
+
+// (GET /healthz)
+func (s *Server) GetHealthz(ctx context.Context, request generated.GetHealthzRequestObject) (generated.GetHealthzResponseObject, error) {
+	return generated.GetHealthz200JSONResponse{
+		Status: generated.HealthResponseStatusOk,
+		Checks: []generated.HealthCheck{},
+	}, nil
+}
+
+// (GET /readyz)
+func (s *Server) GetReadyz(ctx context.Context, request generated.GetReadyzRequestObject) (generated.GetReadyzResponseObject, error) {
+	resp := generated.HealthResponse{
+		Status: generated.HealthResponseStatusOk,
+		Checks: s.RunHealthChecks(ctx),
+	}
+	if !s.ready.Load() {
+		resp.Status = generated.HealthResponseStatusUnavailable
+		resp.Checks = append([]generated.HealthCheck{{
+			Name:   "startup",
+			Status: generated.HealthCheckStatusPending,
+		}}, resp.Checks...)
+	}
+	for _, check := range resp.Checks {
+		if check.Status != generated.HealthCheckStatusOk {
+			resp.Status = generated.HealthResponseStatusUnavailable
+		}
+	}
+
+	if resp.Status != generated.HealthResponseStatusOk {
+		return generated.GetReadyz503JSONResponse(resp), nil
+	}
+	return generated.GetReadyz200JSONResponse(resp), nil
+}
+
+// RunHealthChecks runs every readiness check and reports each result.
+func (s *Server) RunHealthChecks(ctx context.Context) []generated.HealthCheck {
+	results := make([]generated.HealthCheck, 0, len(s.healthChecks))
+	for _, check := range s.healthChecks {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		start := time.Now()
+		err := check.Check(checkCtx)
+		cancel()
+
+		result := generated.HealthCheck{
+			Name:       check.Name,
+			Status:     generated.HealthCheckStatusOk,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			message := err.Error()
+			result.Status = generated.HealthCheckStatusFailed
+			result.Error = &message
+			s.Logger.WarnContext(ctx, "health check failed", "check", check.Name, "error", err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Healthy reports whether every readiness check passes, ignoring the startup
+// state. It is used while starting up to decide when to call SetReady.
+func (s *Server) Healthy(ctx context.Context) bool {
+	for _, check := range s.RunHealthChecks(ctx) {
+		if check.Status != generated.HealthCheckStatusOk {
+			return false
+		}
+	}
+	return true
+}
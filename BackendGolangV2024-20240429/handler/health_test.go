@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReadyzBeforeStartup(t *testing.T) {
+	s, repo := newTestServer(t)
+	repo.EXPECT().Ping(gomock.Any()).Return(nil)
+	repo.EXPECT().GetSchemaVersion(gomock.Any()).Return(repository.SchemaVersion, nil)
+
+	resp, err := s.GetReadyz(context.Background(), generated.GetReadyzRequestObject{})
+	require.NoError(t, err)
+	unavailable, ok := resp.(generated.GetReadyz503JSONResponse)
+	require.True(t, ok)
+	require.Equal(t, "startup", unavailable.Checks[0].Name)
+	require.Equal(t, generated.HealthCheckStatusPending, unavailable.Checks[0].Status)
+}
+
+func TestGetReadyz(t *testing.T) {
+	s, repo := newTestServer(t)
+	s.SetReady(true)
+	repo.EXPECT().Ping(gomock.Any()).Return(nil)
+	repo.EXPECT().GetSchemaVersion(gomock.Any()).Return(repository.SchemaVersion, nil)
+
+	resp, err := s.GetReadyz(context.Background(), generated.GetReadyzRequestObject{})
+	require.NoError(t, err)
+	ready, ok := resp.(generated.GetReadyz200JSONResponse)
+	require.True(t, ok)
+	require.Equal(t, generated.HealthResponseStatusOk, ready.Status)
+	require.Len(t, ready.Checks, 2)
+}
+
+func TestGetReadyzReportsFailingChecks(t *testing.T) {
+	s, repo := newTestServer(t)
+	s.SetReady(true)
+	repo.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused"))
+	repo.EXPECT().GetSchemaVersion(gomock.Any()).Return(repository.SchemaVersion-1, nil)
+
+	resp, err := s.GetReadyz(context.Background(), generated.GetReadyzRequestObject{})
+	require.NoError(t, err)
+	unavailable, ok := resp.(generated.GetReadyz503JSONResponse)
+	require.True(t, ok)
+	for _, check := range unavailable.Checks {
+		require.Equal(t, generated.HealthCheckStatusFailed, check.Status)
+		require.NotNil(t, check.Error)
+	}
+}
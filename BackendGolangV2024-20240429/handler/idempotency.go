@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// IdempotencyKeyHeader names the header clients use to make a POST safe
+	// to retry.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from an earlier
+	// request with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxBufferedBodyBytes is how much of a request body is held in memory
+	// to hash it; larger bodies, such as point clouds, are spooled to a
+	// temporary file.
+	maxBufferedBodyBytes = 1 << 20
+	// idempotencyWriteTimeout bounds storing or releasing a key after the
+	// handler ran, which happens even when the client went away.
+	idempotencyWriteTimeout  = 5 * time.Second
+	idempotencyPurgeInterval = time.Hour
+)
+
+// IdempotencyOptions configure how long idempotency keys are kept.
+type IdempotencyOptions struct {
+	// TTL is how long a key replays its response.
+	TTL time.Duration
+	// LockTimeout is how long a key whose request never finished, e.g.
+	// because the instance crashed, blocks retries.
+	LockTimeout time.Duration
+}
+
+var DefaultIdempotencyOptions = IdempotencyOptions{
+	TTL:         24 * time.Hour,
+	LockTimeout: time.Minute,
+}
+
+// replayedHeaders are the response headers stored with a response besides
+// its content type.
+var replayedHeaders = []string{"ETag", "Location"}
+
+// IdempotencyMiddleware makes POST requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key runs and its response is stored;
+// a retry with the same key, query and body gets the stored response back, a
+// retry while the first request still runs gets 409 and reusing the key for a
+// different request gets 422. Replays carry the status, body, content type and
+// replayedHeaders of the first response. Keys are scoped to the Authorization
+// header, so responses are only replayed to callers with the same credentials.
+// Failed requests (5xx) release the key so they can be retried. Zero options
+// use DefaultIdempotencyOptions.
+func (s *Server) IdempotencyMiddleware(opts IdempotencyOptions) echo.MiddlewareFunc {
+	if opts == (IdempotencyOptions{}) {
+		opts = DefaultIdempotencyOptions
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(IdempotencyKeyHeader)
+			if req.Method != http.MethodPost || key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "Idempotency-Key is too long")
+			}
+
+			h := newRequestHash(req.Method, requestTarget(req.URL))
+			body, err := spoolBody(io.TeeReader(req.Body, h))
+			var spoolErr *fs.PathError
+			if errors.As(err, &spoolErr) {
+				s.Logger.ErrorContext(req.Context(), "spooling request body", "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "reading request body")
+			}
+			defer body.Close()
+			req.Body = body
+			hash := hex.EncodeToString(h.Sum(nil))
+			key = callerKey(req.Header.Get(echo.HeaderAuthorization), key)
+
+			ctx := req.Context()
+			claim, err := s.Repository.ClaimIdempotencyKey(ctx, repository.IdempotencyKeyRequest{
+				Key:         key,
+				RequestHash: hash,
+				TTL:         opts.TTL,
+				LockTimeout: opts.LockTimeout,
+			})
+			if err != nil {
+				s.Logger.ErrorContext(ctx, "claiming idempotency key", "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
+			}
+			if !claim.Claimed {
+				switch {
+				case claim.RequestHash != hash:
+					return echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				case claim.StatusCode == 0:
+					return echo.NewHTTPError(http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+				}
+				s.Logger.InfoContext(ctx, "replaying idempotent response", "status", claim.StatusCode)
+				for name, value := range claim.Headers {
+					c.Response().Header().Set(name, value)
+				}
+				c.Response().Header().Set(IdempotentReplayedHeader, "true")
+				return c.Blob(claim.StatusCode, claim.ContentType, claim.Body)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			err = next(c)
+			c.Response().Writer = recorder.ResponseWriter
+
+			writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+			defer cancel()
+			status := c.Response().Status
+			if err != nil || !c.Response().Committed || status >= http.StatusInternalServerError {
+				if releaseErr := s.Repository.ReleaseIdempotencyKey(writeCtx, key); releaseErr != nil {
+					s.Logger.ErrorContext(ctx, "releasing idempotency key", "error", releaseErr)
+				}
+				return err
+			}
+			var headers map[string]string
+			for _, name := range replayedHeaders {
+				if value := c.Response().Header().Get(name); value != "" {
+					if headers == nil {
+						headers = map[string]string{}
+					}
+					headers[name] = value
+				}
+			}
+			saveErr := s.Repository.SaveIdempotentResponse(writeCtx, repository.IdempotentResponse{
+				Key:         key,
+				StatusCode:  status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Headers:     headers,
+				Body:        recorder.body.Bytes(),
+			})
+			if saveErr != nil {
+				// The response already went out; a retry will be answered
+				// with 409 until the lock timeout passes.
+				s.Logger.ErrorContext(ctx, "saving idempotent response", "error", saveErr)
+			}
+			return nil
+		}
+	}
+}
+
+// RunIdempotencyPurge deletes expired idempotency keys every hour until ctx is
+// done.
+func (s *Server) RunIdempotencyPurge(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deleted, err := s.Repository.DeleteExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			s.Logger.WarnContext(ctx, "purging idempotency keys", "error", err)
+			continue
+		}
+		s.Logger.DebugContext(ctx, "purged idempotency keys", "deleted", deleted)
+	}
+}
+
+// callerKey scopes an Idempotency-Key to the credentials of the caller, so a
+// response is never replayed to a caller that could not have made the
+// request, such as an admin-only one to an anonymous caller. The result fits
+// the key column whatever the length of the key.
+func callerKey(authorization, key string) string {
+	h := sha256.New()
+	io.WriteString(h, authorization)
+	h.Write([]byte{0})
+	io.WriteString(h, key)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestTarget is the path and the query of a request with its parameters
+// sorted, so options such as dry_run tell requests apart.
+func requestTarget(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + u.Query().Encode()
+}
+
+// newRequestHash starts the hash identifying a request by method, target and
+// body, so a key can not be replayed for a different operation. The body is
+// written to it as it is read.
+func newRequestHash(method, target string) hash.Hash {
+	h := sha256.New()
+	io.WriteString(h, method)
+	h.Write([]byte{0})
+	io.WriteString(h, target)
+	h.Write([]byte{0})
+	return h
+}
+
+// spoolBody reads a request body to the end and returns a copy of it for the
+// handler, in memory up to maxBufferedBodyBytes and in a temporary file,
+// removed on Close, beyond. Errors of the file are *fs.PathError.
+func spoolBody(r io.Reader) (io.ReadCloser, error) {
+	var head bytes.Buffer
+	if _, err := io.CopyN(&head, r, maxBufferedBodyBytes+1); err == io.EOF {
+		return io.NopCloser(&head), nil
+	} else if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledBody{file}
+	if _, err := io.Copy(file, io.MultiReader(&head, r)); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// spooledBody is a request body spooled to a temporary file.
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// responseRecorder copies the response body while it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+const estateRequestBody = `{"length":10,"width":20}`
+
+// serveIdempotent sends a POST /estate with the given key through the
+// idempotency middleware and reports how often the handler ran.
+func serveIdempotent(s *Server, key, body string, status int) (*httptest.ResponseRecorder, int) {
+	req := httptest.NewRequest(http.MethodPost, "/estate", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return serveIdempotentRequest(s, req, status)
+}
+
+func serveIdempotentRequest(s *Server, req *http.Request, status int) (*httptest.ResponseRecorder, int) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+
+	calls := 0
+	handler := s.IdempotencyMiddleware(IdempotencyOptions{})(func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set("ETag", `"1"`)
+		return c.JSON(status, map[string]string{"id": "created"})
+	})
+	if err := handler(e.NewContext(req, rec)); err != nil {
+		e.HTTPErrorHandler(err, e.NewContext(req, rec))
+	}
+	return rec, calls
+}
+
+func TestIdempotencyStoresFirstResponse(t *testing.T) {
+	s, repo := newTestServer(t)
+	hash := requestHash(http.MethodPost, "/estate", []byte(estateRequestBody))
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), repository.IdempotencyKeyRequest{
+		Key:         callerKey("", "key-1"),
+		RequestHash: hash,
+		TTL:         DefaultIdempotencyOptions.TTL,
+		LockTimeout: DefaultIdempotencyOptions.LockTimeout,
+	}).Return(repository.IdempotencyKey{Claimed: true, RequestHash: hash}, nil)
+	repo.EXPECT().SaveIdempotentResponse(gomock.Any(), repository.IdempotentResponse{
+		Key:         callerKey("", "key-1"),
+		StatusCode:  http.StatusOK,
+		ContentType: "application/json; charset=UTF-8",
+		Headers:     map[string]string{"ETag": `"1"`},
+		Body:        []byte("{\"id\":\"created\"}\n"),
+	}).Return(nil)
+
+	rec, calls := serveIdempotent(s, "key-1", estateRequestBody, http.StatusOK)
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	s, repo := newTestServer(t)
+	hash := requestHash(http.MethodPost, "/estate", []byte(estateRequestBody))
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(repository.IdempotencyKey{
+		RequestHash: hash,
+		StatusCode:  http.StatusOK,
+		ContentType: echo.MIMEApplicationJSON,
+		Headers:     map[string]string{"ETag": `"1"`},
+		Body:        []byte(`{"id":"first"}`),
+	}, nil)
+
+	rec, calls := serveIdempotent(s, "key-1", estateRequestBody, http.StatusOK)
+	require.Zero(t, calls)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `{"id":"first"}`, rec.Body.String())
+	require.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	require.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	s, repo := newTestServer(t)
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(repository.IdempotencyKey{
+		RequestHash: requestHash(http.MethodPost, "/estate", []byte(`{"length":1,"width":1}`)),
+		StatusCode:  http.StatusOK,
+	}, nil)
+
+	rec, calls := serveIdempotent(s, "key-1", estateRequestBody, http.StatusOK)
+	require.Zero(t, calls)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	s, repo := newTestServer(t)
+	hash := requestHash(http.MethodPost, "/estate", []byte(estateRequestBody))
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(repository.IdempotencyKey{RequestHash: hash}, nil)
+
+	rec, calls := serveIdempotent(s, "key-1", estateRequestBody, http.StatusOK)
+	require.Zero(t, calls)
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestIdempotencyReleasesFailedRequest(t *testing.T) {
+	s, repo := newTestServer(t)
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(repository.IdempotencyKey{Claimed: true}, nil)
+	repo.EXPECT().ReleaseIdempotencyKey(gomock.Any(), callerKey("", "key-1")).Return(nil)
+
+	rec, calls := serveIdempotent(s, "key-1", estateRequestBody, http.StatusInternalServerError)
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestIdempotencyTellsQueriesApart(t *testing.T) {
+	s, repo := newTestServer(t)
+	dryRun := requestHash(http.MethodPost, "/estate/1/trees/survey?dry_run=true", []byte("trees"))
+	require.NotEqual(t, dryRun, requestHash(http.MethodPost, "/estate/1/trees/survey", []byte("trees")))
+	require.Equal(t, dryRun, requestHash(http.MethodPost, requestTarget(&url.URL{
+		Path: "/estate/1/trees/survey", RawQuery: "dry_run=true",
+	}), []byte("trees")))
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(repository.IdempotencyKey{
+		RequestHash: dryRun,
+		StatusCode:  http.StatusOK,
+	}, nil)
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/survey", strings.NewReader("trees"))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+	rec, calls := serveIdempotentRequest(s, req, http.StatusOK)
+	require.Zero(t, calls)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the dry run is not replayed for the import")
+}
+
+func TestIdempotencyKeysAreScopedToTheCaller(t *testing.T) {
+	s, repo := newTestServer(t)
+	require.NotEqual(t, callerKey("", "key-1"), callerKey("Bearer admin", "key-1"))
+
+	repo.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, claim repository.IdempotencyKeyRequest) (repository.IdempotencyKey, error) {
+			require.Equal(t, callerKey("Bearer admin", "key-1"), claim.Key)
+			return repository.IdempotencyKey{Claimed: true, RequestHash: claim.RequestHash}, nil
+		})
+	repo.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader("{}"))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	req.Header.Set(echo.HeaderAuthorization, "Bearer admin")
+
+	_, calls := serveIdempotentRequest(s, req, http.StatusOK)
+	require.Equal(t, 1, calls)
+}
+
+func TestSpoolBody(t *testing.T) {
+	small, err := spoolBody(strings.NewReader(estateRequestBody))
+	require.NoError(t, err)
+	require.NotImplements(t, (*interface{ Name() string })(nil), small, "small bodies stay in memory")
+	require.NoError(t, small.Close())
+
+	large := bytes.Repeat([]byte("0,0,1\n"), maxBufferedBodyBytes)
+	spooled, err := spoolBody(bytes.NewReader(large))
+	require.NoError(t, err)
+	name := spooled.(*spooledBody).Name()
+	read, err := io.ReadAll(spooled)
+	require.NoError(t, err)
+	require.Equal(t, large, read)
+	require.NoError(t, spooled.Close())
+	_, err = os.Stat(name)
+	require.True(t, os.IsNotExist(err), "the file is removed")
+}
+
+// requestHash is the hash the middleware computes for a request.
+func requestHash(method, target string, body []byte) string {
+	h := newRequestHash(method, target)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestTimeouts bounds how long each operation may run. Operations that are
+// not listed in PerOperation use Default; a zero duration disables the limit.
+type RequestTimeouts struct {
+	Default      time.Duration
+	PerOperation map[string]time.Duration
+}
+
+// For returns the timeout that applies to the given operation id.
+func (t RequestTimeouts) For(operationID string) time.Duration {
+	if d, ok := t.PerOperation[operationID]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// TimeoutMiddleware attaches a deadline to the request context of every
+// operation. Handlers and the repository see the deadline through the context
+// they receive, and a request that runs out of time is answered with 503.
+func TimeoutMiddleware(timeouts RequestTimeouts) generated.StrictMiddlewareFunc {
+	return func(next generated.StrictHandlerFunc, operationID string) generated.StrictHandlerFunc {
+		timeout := timeouts.For(operationID)
+		if timeout <= 0 {
+			return next
+		}
+		return func(ctx echo.Context, request interface{}) (interface{}, error) {
+			timeoutCtx, cancel := context.WithTimeout(ctx.Request().Context(), timeout)
+			defer cancel()
+			ctx.SetRequest(ctx.Request().WithContext(timeoutCtx))
+
+			response, err := next(ctx, request)
+			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+				return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "request timed out")
+			}
+			return response, err
+		}
+	}
+}
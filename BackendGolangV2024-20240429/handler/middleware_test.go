@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	e := echo.New()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	mw := TimeoutMiddleware(RequestTimeouts{Default: time.Millisecond})
+	handler := mw(func(ctx echo.Context, request interface{}) (interface{}, error) {
+		<-ctx.Request().Context().Done()
+		return nil, ctx.Request().Context().Err()
+	}, "GetStats")
+
+	_, err := handler(ctx, nil)
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+}
+
+func TestTimeoutMiddlewareDisabled(t *testing.T) {
+	e := echo.New()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	mw := TimeoutMiddleware(RequestTimeouts{})
+	handler := mw(func(ctx echo.Context, request interface{}) (interface{}, error) {
+		_, hasDeadline := ctx.Request().Context().Deadline()
+		require.False(t, hasDeadline)
+		return "ok", nil
+	}, "GetStats")
+
+	resp, err := handler(ctx, context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp)
+}
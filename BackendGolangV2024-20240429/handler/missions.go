@@ -0,0 +1,385 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMissionPageSize = 50
+	maxMissionPageSize     = 500
+)
+
+// (POST /estate/{id}/missions)
+func (s *Server) PostMission(ctx context.Context, request generated.PostMissionRequestObject) (generated.PostMissionResponseObject, error) {
+	var maxDistance *int
+	if request.Body != nil && request.Body.MaxDistance != nil {
+		if *request.Body.MaxDistance < 1 {
+			return generated.PostMission400JSONResponse{Message: "invalid max_distance"}, nil
+		}
+		maxDistance = request.Body.MaxDistance
+	}
+
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostMission404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.PostMission500JSONResponse{Message: "internal server error"}, nil
+	}
+	trees, err := s.Repository.GetTreesByEstateId(ctx, request.Id)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting trees", "estate_id", request.Id, "error", err)
+		return generated.PostMission500JSONResponse{Message: "internal server error"}, nil
+	}
+	ground, err := s.terrainModel(ctx, estate)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting terrain", "estate_id", request.Id, "error", err)
+		return generated.PostMission500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	drone := s.droneOptions(estate)
+	start := time.Now()
+	estatePlots := estate.Length * estate.Width
+	planCtx, span := tracer.Start(ctx, "planner.flightPath", trace.WithAttributes(
+		attribute.Int("estate.plots", estatePlots),
+		attribute.Int("estate.trees", len(trees)),
+	))
+	plan, err := flightPath(planCtx, drone, trees, ground, estate.Length, estate.Width, maxDistance)
+	span.SetAttributes(attribute.Int("planner.plots_traversed", plan.plotsTraversed))
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	distance := planDistance(drone, trees, estate.Length, estate.Width)
+	if !ground.Flat() {
+		distance = plan.totalDistance(ground)
+	}
+	if maxDistance != nil && *maxDistance < distance {
+		distance = *maxDistance
+	}
+	s.Metrics.ObservePlanner("PostMission", time.Since(start), plan.plotsTraversed, estatePlots)
+
+	mission, err := s.Repository.CreateMission(ctx, repository.MissionRequest{
+		EstateId:        request.Id,
+		MaxDistance:     maxDistance,
+		PlotSizeMeters:  drone.PlotSizeMeters,
+		ClearanceMeters: drone.ClearanceMeters,
+		Distance:        distance,
+		LandingX:        plan.landing.X,
+		LandingY:        plan.landing.Y,
+		TreesHash:       treesHash(trees),
+		Path:            plan.path,
+	})
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostMission404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "creating mission", "estate_id", request.Id, "error", err)
+		return generated.PostMission500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostMission200JSONResponse(missionBody(mission)), nil
+}
+
+// (GET /estate/{id}/missions)
+func (s *Server) ListMissions(ctx context.Context, request generated.ListMissionsRequestObject) (generated.ListMissionsResponseObject, error) {
+	params := request.Params
+	query := repository.MissionQuery{EstateId: request.Id, Limit: defaultMissionPageSize}
+	if params.Status != nil {
+		status := string(*params.Status)
+		query.Status = &status
+	}
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxMissionPageSize {
+			return generated.ListMissions400JSONResponse{Message: "limit must be between 1 and 500"}, nil
+		}
+		query.Limit = *params.Limit
+	}
+	if params.Cursor != nil {
+		cursor, err := parseMissionCursor(*params.Cursor)
+		if err != nil {
+			return generated.ListMissions400JSONResponse{Message: "invalid cursor"}, nil
+		}
+		query.After = &cursor
+	}
+
+	if _, err := s.Repository.GetEstateById(ctx, request.Id); err != nil {
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return generated.ListMissions404JSONResponse{Message: "estate not found"}, nil
+		}
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.ListMissions500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	// Ask for one more mission to learn whether there is a next page.
+	limit := query.Limit
+	query.Limit++
+	missions, err := s.Repository.ListMissions(ctx, query)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.ListMissions404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing missions", "estate_id", request.Id, "error", err)
+		return generated.ListMissions500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	page := generated.ListMissions200JSONResponse{Missions: []generated.Mission{}}
+	if len(missions) > limit {
+		missions = missions[:limit]
+		cursor := formatMissionCursor(missions[limit-1])
+		page.NextCursor = &cursor
+	}
+	for _, mission := range missions {
+		page.Missions = append(page.Missions, missionBody(mission))
+	}
+	return page, nil
+}
+
+// (GET /estate/{id}/missions/{mission_id})
+func (s *Server) GetMission(ctx context.Context, request generated.GetMissionRequestObject) (generated.GetMissionResponseObject, error) {
+	mission, err := s.Repository.GetMission(ctx, request.Id, request.MissionId)
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.GetMission404JSONResponse{Message: "mission not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting mission", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.GetMission500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.GetMission200JSONResponse(missionBody(mission)), nil
+}
+
+// (POST /estate/{id}/missions/{mission_id}/status)
+func (s *Server) PostMissionStatus(ctx context.Context, request generated.PostMissionStatusRequestObject) (generated.PostMissionStatusResponseObject, error) {
+	status := request.Body.Status
+	if status != generated.InFlight && status != generated.Completed && status != generated.Aborted {
+		return generated.PostMissionStatus400JSONResponse{Message: "status must be in_flight, completed or aborted"}, nil
+	}
+	mission, err := s.Repository.UpdateMissionStatus(ctx, request.Id, request.MissionId, string(status))
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.PostMissionStatus404JSONResponse{Message: "mission not found"}, nil
+	}
+	if errors.Is(err, repository.ErrMissionTransition) {
+		return generated.PostMissionStatus409JSONResponse{
+			Message: fmt.Sprintf("mission can not move to %s from its current status", status),
+		}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "updating mission status", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.PostMissionStatus500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostMissionStatus200JSONResponse(missionBody(mission)), nil
+}
+
+func missionBody(mission repository.Mission) generated.Mission {
+	body := generated.Mission{
+		Id:              mission.Id,
+		EstateId:        mission.EstateId,
+		Status:          generated.MissionStatus(mission.Status),
+		Distance:        mission.Distance,
+		LandingPoint:    generated.LandingPoint{X: mission.LandingX, Y: mission.LandingY},
+		MaxDistance:     mission.MaxDistance,
+		PlotSizeMeters:  mission.PlotSizeMeters,
+		ClearanceMeters: mission.ClearanceMeters,
+		TreesHash:       mission.TreesHash,
+		CreatedAt:       mission.CreatedAt,
+		StartedAt:       mission.StartedAt,
+		CompletedAt:     mission.CompletedAt,
+		AbortedAt:       mission.AbortedAt,
+	}
+	if mission.Path != nil {
+		path := make([]generated.Waypoint, len(mission.Path))
+		for i, waypoint := range mission.Path {
+			path[i] = generated.Waypoint{X: waypoint.X, Y: waypoint.Y, Altitude: waypoint.Altitude}
+		}
+		body.Path = &path
+	}
+	return body
+}
+
+// Mission cursors are "<created_at in unix nanoseconds>_<id>" of the last
+// mission of a page.
+func formatMissionCursor(mission repository.Mission) string {
+	return strconv.FormatInt(mission.CreatedAt.UnixNano(), 10) + "_" + mission.Id.String()
+}
+
+func parseMissionCursor(cursor string) (repository.MissionCursor, error) {
+	nanos, id, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return repository.MissionCursor{}, errors.New("invalid cursor")
+	}
+	createdAt, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return repository.MissionCursor{}, err
+	}
+	missionId, err := uuid.Parse(id)
+	if err != nil {
+		return repository.MissionCursor{}, err
+	}
+	return repository.MissionCursor{CreatedAt: time.Unix(0, createdAt), Id: missionId}, nil
+}
+
+// treesHash identifies the trees a plan was computed over. It sorts trees.
+func treesHash(trees []repository.Tree) string {
+	sortTrees(trees)
+	return strings.Trim(contentETag(trees), `"`)
+}
+
+// planDistance is the distance of GetEstateIdDronePlan over flat estates:
+// every plot is crossed once, climbing over and descending between the trees.
+func planDistance(drone DroneOptions, trees []repository.Tree, length, width int) int {
+	totalElevation := 0
+	if len(trees) > 0 {
+		totalElevation = calculateTotalElevation(trees)
+	}
+	return horizontalDistance(drone, length, width) + totalElevation + 2*drone.ClearanceMeters
+}
+
+// horizontalDistance is flown between the plots of a length x width estate,
+// rounded to the meter.
+func horizontalDistance(drone DroneOptions, length, width int) int {
+	return int(math.Round(float64(length*width-1) * drone.PlotSizeMeters))
+}
+
+// flight is a flight path walked by flightPath.
+type flight struct {
+	// path holds the waypoints where the flight starts, turns, changes
+	// altitude or lands.
+	path    []repository.Waypoint
+	landing generated.LandingPoint
+	// distance is flown from the take-off to the landing plot, without the
+	// final descent.
+	distance       int
+	plotsTraversed int
+}
+
+// flightPath walks the plots in flight order, along the first row from (1,1)
+// and back along the next, holding clearance above the ground and trees.
+// Altitudes are relative to plot (1,1), where the drone takes off. With a
+// maxDistance the drone lands on the plot where the distance flown reaches it.
+func flightPath(ctx context.Context, drone DroneOptions, trees []repository.Tree, ground terrain.Model, length, width int, maxDistance *int) (flight, error) {
+	heights := make(map[[2]int]int, len(trees))
+	for _, tree := range trees {
+		heights[[2]int{tree.X, tree.Y}] = tree.Height
+	}
+
+	var (
+		path           []repository.Waypoint
+		previous       repository.Waypoint
+		previousAdded  bool
+		travelDistance float64
+		plotsTraversed int
+	)
+	add := func(waypoint repository.Waypoint) {
+		path = append(path, waypoint)
+	}
+	for y := 1; y <= width; y++ {
+		for i := 1; i <= length; i++ {
+			if (plotsTraversed+1)%cancellationCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return flight{plotsTraversed: plotsTraversed}, err
+				}
+			}
+			x := i
+			if y%2 == 0 {
+				x = length + 1 - i // Even rows: right to left
+			}
+			current := repository.Waypoint{
+				X:        x,
+				Y:        y,
+				Altitude: ground.Ground(x, y) + heights[[2]int{x, y}] + drone.ClearanceMeters,
+			}
+
+			if plotsTraversed == 0 {
+				travelDistance = float64(current.Altitude) // take off
+			} else {
+				travelDistance += drone.PlotSizeMeters + math.Abs(float64(current.Altitude-previous.Altitude))
+			}
+			plotsTraversed++
+
+			last := i == length && y == width
+			landing := maxDistance != nil && travelDistance >= float64(*maxDistance)
+			if current.Altitude != previous.Altitude && !previousAdded && plotsTraversed > 1 {
+				// Level flight up to here, then climb or descend.
+				add(previous)
+			}
+			previousAdded = plotsTraversed == 1 || i == 1 || i == length || last || landing ||
+				current.Altitude != previous.Altitude
+			if previousAdded {
+				add(current)
+			}
+			if landing || last {
+				return flight{
+					path:           path,
+					landing:        generated.LandingPoint{X: x, Y: y},
+					distance:       int(math.Round(travelDistance)),
+					plotsTraversed: plotsTraversed,
+				}, nil
+			}
+			previous = current
+		}
+	}
+	return flight{path: path, plotsTraversed: plotsTraversed}, nil
+}
+
+// walkFlight walks the flight over an estate for the drone plan endpoints.
+func (s *Server) walkFlight(ctx context.Context, estate repository.EstateData, trees []repository.Tree, ground terrain.Model, maxDistance *int) (flight, error) {
+	planCtx, span := tracer.Start(ctx, "planner.flightPath", trace.WithAttributes(
+		attribute.Int("estate.plots", estate.Length*estate.Width),
+		attribute.Int("estate.trees", len(trees)),
+	))
+	defer span.End()
+	plan, err := flightPath(planCtx, s.droneOptions(estate), trees, ground, estate.Length, estate.Width, maxDistance)
+	span.SetAttributes(attribute.Int("planner.plots_traversed", plan.plotsTraversed))
+	return plan, err
+}
+
+// landingPoint answers GetEstateIdDronePlanWithMaxDistance with the flight
+// missions are planned with, where the drone lands once the climbs and the
+// plot moves add up to maxDistance. Landing plots of block plans are mapped
+// back onto the estate.
+func (s *Server) landingPoint(ctx context.Context, estate repository.EstateData, trees []repository.Tree, ground terrain.Model, block *repository.Block, maxDistance int, etag string, start time.Time) (generated.GetEstateIdDronePlanWithMaxDistanceResponseObject, error) {
+	var limit *int
+	if maxDistance > 0 {
+		limit = &maxDistance
+	}
+	plan, err := s.walkFlight(ctx, estate, trees, ground, limit)
+	if err != nil {
+		return nil, err
+	}
+	estatePlots := estate.Length * estate.Width
+	// A flight over terrain that reached the last plot tells the whole
+	// distance; flat estates were checked against the drone plan.
+	if !ground.Flat() && plan.plotsTraversed == estatePlots && maxDistance > plan.totalDistance(ground) {
+		return generated.GetEstateIdDronePlanWithMaxDistance400JSONResponse{Message: "invalid max_distance"}, nil
+	}
+	s.Metrics.ObservePlanner("GetEstateIdDronePlanWithMaxDistance", time.Since(start), plan.plotsTraversed, estatePlots)
+	return generated.GetEstateIdDronePlanWithMaxDistance200JSONResponse{
+		Body: generated.DropPlanResponseWithMaxDistance{
+			Distance:     maxDistance,
+			LandingPoint: estateLanding(block, plan.landing),
+		},
+		Headers: generated.GetEstateIdDronePlanWithMaxDistance200ResponseHeaders{ETag: etag},
+	}, nil
+}
+
+// totalDistance is the distance of a complete flight over terrain, down to
+// the ground of the landing plot.
+func (f flight) totalDistance(ground terrain.Model) int {
+	if len(f.path) == 0 {
+		return f.distance
+	}
+	return f.distance + f.path[len(f.path)-1].Altitude - ground.Ground(f.landing.X, f.landing.Y)
+}
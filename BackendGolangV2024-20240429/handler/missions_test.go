@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlightPath(t *testing.T) {
+	trees := []repository.Tree{{X: 2, Y: 1, Height: 10}, {X: 3, Y: 1, Height: 20}, {X: 3, Y: 2, Height: 5}}
+
+	plan, err := flightPath(context.Background(), DefaultDroneOptions, trees, terrain.Model{}, 3, 2, nil)
+	require.NoError(t, err)
+	require.Equal(t, []repository.Waypoint{
+		{X: 1, Y: 1, Altitude: 1},
+		{X: 2, Y: 1, Altitude: 11},
+		{X: 3, Y: 1, Altitude: 21},
+		{X: 3, Y: 2, Altitude: 6}, // the second row is flown backwards
+		{X: 2, Y: 2, Altitude: 1},
+		{X: 1, Y: 2, Altitude: 1},
+	}, plan.path)
+	require.Equal(t, generated.LandingPoint{X: 1, Y: 2}, plan.landing)
+	require.Equal(t, 6, plan.plotsTraversed)
+
+	// Take off to 1m, then 10m and 10m climbs plus two plots of 10m.
+	maxDistance := 41
+	plan, err = flightPath(context.Background(), DefaultDroneOptions, trees, terrain.Model{}, 3, 2, &maxDistance)
+	require.NoError(t, err)
+	require.Equal(t, generated.LandingPoint{X: 3, Y: 1}, plan.landing)
+	require.Equal(t, 3, plan.plotsTraversed)
+	require.Equal(t, 41, plan.distance)
+	require.Equal(t, repository.Waypoint{X: 3, Y: 1, Altitude: 21}, plan.path[len(plan.path)-1])
+}
+
+func TestFlightPathSkipsLevelPlots(t *testing.T) {
+	plan, err := flightPath(context.Background(), DefaultDroneOptions, []repository.Tree{{X: 5, Y: 1, Height: 10}}, terrain.Model{}, 8, 1, nil)
+	require.NoError(t, err)
+	require.Equal(t, []repository.Waypoint{
+		{X: 1, Y: 1, Altitude: 1},
+		{X: 4, Y: 1, Altitude: 1},
+		{X: 5, Y: 1, Altitude: 11},
+		{X: 6, Y: 1, Altitude: 1},
+		{X: 8, Y: 1, Altitude: 1},
+	}, plan.path)
+}
+
+func TestFlightPathCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := flightPath(ctx, DefaultDroneOptions, nil, terrain.Model{}, 10000, 10000, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPostMission(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 5, Width: 1}, nil)
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return([]repository.Tree{
+		{X: 2, Y: 1, Height: 10},
+		{X: 3, Y: 1, Height: 20},
+		{X: 4, Y: 1, Height: 10},
+	}, nil)
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound)
+	repo.EXPECT().CreateMission(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.MissionRequest) (repository.Mission, error) {
+			// The same distance as GetEstateIdDronePlan.
+			require.Equal(t, 82, input.Distance)
+			require.Equal(t, 5, input.LandingX)
+			require.Len(t, input.TreesHash, 32)
+			require.Len(t, input.Path, 5)
+			return repository.Mission{
+				Id:        uuid.New(),
+				EstateId:  id,
+				Status:    repository.MissionPlanned,
+				Distance:  input.Distance,
+				LandingX:  input.LandingX,
+				LandingY:  input.LandingY,
+				TreesHash: input.TreesHash,
+				Path:      input.Path,
+				CreatedAt: time.Now(),
+			}, nil
+		})
+
+	resp, err := s.PostMission(context.Background(), generated.PostMissionRequestObject{
+		Id:   id.String(),
+		Body: &generated.PostMissionJSONRequestBody{},
+	})
+	require.NoError(t, err)
+	mission := resp.(generated.PostMission200JSONResponse)
+	require.Equal(t, generated.Planned, mission.Status)
+	require.Equal(t, generated.LandingPoint{X: 5, Y: 1}, mission.LandingPoint)
+	require.Len(t, *mission.Path, 5)
+}
+
+func TestPostMissionUsesEstatePlotSize(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	estate := repository.EstateData{
+		Id: id, Length: 5, Width: 1,
+		GeoReference: &geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: geo.DefaultBearing, PlotSizeMeters: 2.5},
+	}
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(estate, nil).Times(2)
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return([]repository.Tree{}, nil).Times(2)
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound).Times(2)
+	repo.EXPECT().CreateMission(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.MissionRequest) (repository.Mission, error) {
+			// 4 plot moves of 2.5m and the climb and descent of 1m.
+			require.Equal(t, 12, input.Distance)
+			require.Equal(t, 2.5, input.PlotSizeMeters)
+			return repository.Mission{Id: uuid.New(), EstateId: id, PlotSizeMeters: input.PlotSizeMeters, Distance: input.Distance}, nil
+		})
+
+	plan, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 12}, plan.(generated.GetEstateIdDronePlan200JSONResponse).Body)
+
+	resp, err := s.PostMission(context.Background(), generated.PostMissionRequestObject{
+		Id:   id.String(),
+		Body: &generated.PostMissionJSONRequestBody{},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2.5, resp.(generated.PostMission200JSONResponse).PlotSizeMeters)
+}
+
+func TestPostMissionLandsWhereThePlanDoes(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	maxDistance := 45
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 2, Width: 3}, nil).Times(2)
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).DoAndReturn(func(context.Context, string) ([]repository.Tree, error) {
+		return []repository.Tree{{X: 2, Y: 1, Height: 10}, {X: 1, Y: 3, Height: 5}}, nil
+	}).Times(2)
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(repository.Terrain{}, repository.ErrTerrainNotFound).Times(2)
+	repo.EXPECT().CreateMission(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.MissionRequest) (repository.Mission, error) {
+			return repository.Mission{Id: uuid.New(), EstateId: id, LandingX: input.LandingX, LandingY: input.LandingY}, nil
+		})
+
+	plan, err := s.GetEstateIdDronePlanWithMaxDistance(context.Background(), generated.GetEstateIdDronePlanWithMaxDistanceRequestObject{
+		Id: id.String(), Params: generated.GetEstateIdDronePlanWithMaxDistanceParams{MaxDistance: maxDistance},
+	})
+	require.NoError(t, err)
+	resp, err := s.PostMission(context.Background(), generated.PostMissionRequestObject{
+		Id:   id.String(),
+		Body: &generated.PostMissionJSONRequestBody{MaxDistance: &maxDistance},
+	})
+	require.NoError(t, err)
+	landing := plan.(generated.GetEstateIdDronePlanWithMaxDistance200JSONResponse).Body.LandingPoint
+	require.Equal(t, generated.LandingPoint{X: 1, Y: 2}, landing)
+	require.Equal(t, landing, resp.(generated.PostMission200JSONResponse).LandingPoint)
+}
+
+func TestPostMissionStatus(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+
+	resp, err := s.PostMissionStatus(context.Background(), generated.PostMissionStatusRequestObject{
+		Id: estateId, MissionId: missionId, Body: &generated.MissionStatusRequest{Status: generated.Planned},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostMissionStatus400JSONResponse{}, resp)
+
+	repo.EXPECT().UpdateMissionStatus(gomock.Any(), estateId, missionId, "completed").Return(repository.Mission{}, repository.ErrMissionTransition)
+	resp, err = s.PostMissionStatus(context.Background(), generated.PostMissionStatusRequestObject{
+		Id: estateId, MissionId: missionId, Body: &generated.MissionStatusRequest{Status: generated.Completed},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostMissionStatus409JSONResponse{}, resp)
+}
+
+func TestListMissionsPages(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.New()
+	missions := []repository.Mission{
+		{Id: uuid.New(), EstateId: estateId, Status: repository.MissionCompleted, CreatedAt: time.Unix(0, 3000)},
+		{Id: uuid.New(), EstateId: estateId, Status: repository.MissionPlanned, CreatedAt: time.Unix(0, 2000)},
+	}
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId.String()).Return(repository.EstateData{Id: estateId}, nil)
+	repo.EXPECT().ListMissions(gomock.Any(), repository.MissionQuery{EstateId: estateId.String(), Limit: 2}).Return(missions, nil)
+
+	limit := 1
+	resp, err := s.ListMissions(context.Background(), generated.ListMissionsRequestObject{
+		Id:     estateId.String(),
+		Params: generated.ListMissionsParams{Limit: &limit},
+	})
+	require.NoError(t, err)
+	page := resp.(generated.ListMissions200JSONResponse)
+	require.Len(t, page.Missions, 1)
+	require.Nil(t, page.Missions[0].Path)
+
+	cursor, err := parseMissionCursor(*page.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, repository.MissionCursor{CreatedAt: time.Unix(0, 3000), Id: missions[0].Id}, cursor)
+
+	_, err = parseMissionCursor("3000")
+	require.Error(t, err)
+}
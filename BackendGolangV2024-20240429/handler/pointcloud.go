@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pointcloud"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxPointCloudPoints caps an upload; the points are binned as they are
+	// read, so only the plots they fall on are held in memory.
+	maxPointCloudPoints = 50000000
+	// maxPointCloudTrees caps the changes of an import. A cloud covers a whole
+	// estate, so it may change more trees than a bulk request.
+	maxPointCloudTrees = 10 * maxBulkTrees
+)
+
+// errTreesChanged is returned when trees change while an import is applied.
+var errTreesChanged = errors.New("a tree changed during the import, retry it")
+
+// (POST /estate/{id}/trees/point-cloud)
+func (s *Server) PostTreesPointCloud(ctx context.Context, request generated.PostTreesPointCloudRequestObject) (generated.PostTreesPointCloudResponseObject, error) {
+	params := request.Params
+	dryRun := params.DryRun != nil && *params.DryRun
+	minHeight := 1
+	if params.MinHeight != nil {
+		if *params.MinHeight < 1 {
+			return generated.PostTreesPointCloud400JSONResponse{Message: "min_height must be at least 1"}, nil
+		}
+		minHeight = *params.MinHeight
+	}
+
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostTreesPointCloud404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.PostTreesPointCloud500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	file, err := uploadedFile(request.Body)
+	if err != nil {
+		return generated.PostTreesPointCloud400JSONResponse{Message: err.Error()}, nil
+	}
+	defer file.Close()
+	canopy := pointcloud.NewCanopy(estate.Length, estate.Width, s.plotSizeMeters(estate))
+	if err := pointcloud.Read(file, maxPointCloudPoints, canopy.Add); err != nil {
+		return generated.PostTreesPointCloud400JSONResponse{Message: err.Error()}, nil
+	}
+
+	var result generated.PointCloudImport
+	apply := func(repo repository.RepositoryInterface) error {
+		// The transaction may be retried from the start.
+		var versions map[uuid.UUID]int
+		result, versions, err = pointCloudChanges(ctx, repo, request.Id, canopy, minHeight)
+		if err != nil || dryRun {
+			return err
+		}
+		if changes := len(result.Created) + len(result.Updated); changes > maxPointCloudTrees {
+			return invalidInputError{fmt.Errorf("the point cloud changes %d trees, at most %d at once", changes, maxPointCloudTrees)}
+		}
+		return applyPointCloud(ctx, repo, request.Id, &result, versions)
+	}
+	if dryRun {
+		err = apply(s.Repository)
+	} else {
+		err = s.Repository.WithTx(ctx, apply)
+	}
+	var invalid invalidInputError
+	if errors.As(err, &invalid) {
+		return generated.PostTreesPointCloud400JSONResponse{Message: err.Error()}, nil
+	}
+	if errors.Is(err, errTreesChanged) {
+		return generated.PostTreesPointCloud409JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "importing point cloud", "estate_id", request.Id, "error", err)
+		return generated.PostTreesPointCloud500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	result.DryRun = dryRun
+	result.Points, result.OutsidePoints = canopy.Points()
+	result.MeasuredPlots = canopy.Plots()
+	if !dryRun {
+		s.Logger.InfoContext(ctx, "imported point cloud", "estate_id", request.Id,
+			"created", len(result.Created), "updated", len(result.Updated))
+	}
+	return generated.PostTreesPointCloud200JSONResponse(result), nil
+}
+
+// pointCloudChanges compares the canopy of every measured plot with the tree
+// standing on it, in plot order. Canopies lower than minHeight are ignored;
+// trees on plots without a canopy are left alone, and invalid changes are
+// skipped. It also returns the versions of the trees to update.
+func pointCloudChanges(ctx context.Context, repo repository.RepositoryInterface, estateId string, canopy *pointcloud.Canopy, minHeight int) (generated.PointCloudImport, map[uuid.UUID]int, error) {
+	trees, err := repo.ListTrees(ctx, estateId)
+	if err != nil {
+		return generated.PointCloudImport{}, nil, err
+	}
+	standing := make(map[[2]int]repository.TreeData, len(trees))
+	for _, tree := range trees {
+		standing[[2]int{tree.X, tree.Y}] = tree
+	}
+
+	var measured []generated.TreeChange
+	canopy.Each(func(x, y, height int) {
+		if height >= minHeight {
+			measured = append(measured, generated.TreeChange{X: x, Y: y, Height: height})
+		}
+	})
+	sort.Slice(measured, func(i, j int) bool {
+		if measured[i].Y != measured[j].Y {
+			return measured[i].Y < measured[j].Y
+		}
+		return measured[i].X < measured[j].X
+	})
+
+	result := generated.PointCloudImport{
+		Created: []generated.TreeChange{},
+		Updated: []generated.TreeChange{},
+		Skipped: []generated.TreeChange{},
+	}
+	versions := map[uuid.UUID]int{}
+	for _, change := range measured {
+		tree, ok := standing[[2]int{change.X, change.Y}]
+		if ok && tree.Height == change.Height {
+			result.Unchanged++
+			continue
+		}
+		update := repository.TreeUpdate{EstateId: estateId, Height: &change.Height}
+		if ok {
+			change.Id = &tree.Id
+			change.PreviousHeight = &tree.Height
+			update.Id = tree.Id.String()
+		}
+		err := repo.ValidateTreeUpdate(ctx, update)
+		var invalid *repository.ValidationError
+		if err != nil && !errors.As(err, &invalid) {
+			return generated.PointCloudImport{}, nil, fmt.Errorf("tree at x=%d y=%d: %w", change.X, change.Y, err)
+		}
+		if err != nil {
+			reason := err.Error()
+			change.Reason = &reason
+			result.Skipped = append(result.Skipped, change)
+			continue
+		}
+		if ok {
+			result.Updated = append(result.Updated, change)
+			versions[tree.Id] = tree.Version
+		} else {
+			result.Created = append(result.Created, change)
+		}
+	}
+	return result, versions, nil
+}
+
+// applyPointCloud creates and updates the trees of result with repo, which
+// is expected to be bound to a transaction, and fills in the new ids.
+// Updated trees must still be at the given versions.
+func applyPointCloud(ctx context.Context, repo repository.RepositoryInterface, estateId string, result *generated.PointCloudImport, versions map[uuid.UUID]int) error {
+	for i, change := range result.Created {
+		response, err := insertTree(ctx, repo, repository.TreeRequest{
+			EstateId: estateId, X: change.X, Y: change.Y, Height: change.Height,
+		})
+		if errors.Is(err, repository.ErrPlotOccupied) {
+			return errTreesChanged
+		}
+		if err != nil {
+			return fmt.Errorf("tree at x=%d y=%d: %w", change.X, change.Y, err)
+		}
+		result.Created[i].Id = &response.Id
+	}
+	for _, change := range result.Updated {
+		_, err := repo.UpdateTree(ctx, repository.TreeUpdate{
+			EstateId: estateId,
+			Id:       change.Id.String(),
+			Height:   &change.Height,
+			Version:  versions[*change.Id],
+		})
+		if errors.Is(err, repository.ErrVersionMismatch) || errors.Is(err, repository.ErrTreeNotFound) {
+			return errTreesChanged
+		}
+		if err != nil {
+			return fmt.Errorf("tree %s: %w", change.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// canopyCloud measures a 4x1 estate of 10m plots: a 12m canopy on plot
+// (1,1), 10m on (2,1), 8m on (3,1) and 40m on (4,1).
+const canopyCloud = "x,y,z\n" +
+	"0,0,100\n1,-2,112\n" +
+	"10,0,100\n11,1,110\n" +
+	"20,0,100\n19,2,108\n" +
+	"30,0,100\n30,0,140\n" +
+	"200,0,100\n"
+
+// pointCloudTrees returns the trees standing on (2,1) at the height the cloud
+// measures and on (3,1) lower than it, and the id of the latter.
+func pointCloudTrees(estateId string) ([]repository.TreeData, uuid.UUID) {
+	short := uuid.New()
+	return []repository.TreeData{
+		{Id: uuid.New(), EstateId: estateId, X: 2, Y: 1, Height: 10, Version: 1},
+		{Id: short, EstateId: estateId, X: 3, Y: 1, Height: 5, Version: 4},
+	}, short
+}
+
+func expectHeightLimit(repo *repository.MockRepositoryInterface) {
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, update repository.TreeUpdate) error {
+			if *update.Height > 30 {
+				return &repository.ValidationError{Err: errors.New("height exceeds the maximum allowed value (30)")}
+			}
+			return nil
+		})
+}
+
+func TestPostTreesPointCloudDryRun(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	standing, short := pointCloudTrees(estateId)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+	repo.EXPECT().ListTrees(gomock.Any(), estateId).Return(standing, nil)
+	expectHeightLimit(repo)
+
+	resp, err := s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:     estateId,
+		Params: generated.PostTreesPointCloudParams{DryRun: ptr(true)},
+		Body:   upload(t, "file", "cloud.csv", "text/csv", canopyCloud),
+	})
+	require.NoError(t, err)
+	reason := "height exceeds the maximum allowed value (30)"
+	require.Equal(t, generated.PostTreesPointCloud200JSONResponse{
+		DryRun:        true,
+		Points:        9,
+		OutsidePoints: 1,
+		MeasuredPlots: 4,
+		Created:       []generated.TreeChange{{X: 1, Y: 1, Height: 12}},
+		Updated:       []generated.TreeChange{{Id: &short, X: 3, Y: 1, Height: 8, PreviousHeight: ptr(5)}},
+		Unchanged:     1,
+		Skipped:       []generated.TreeChange{{X: 4, Y: 1, Height: 40, Reason: &reason}},
+	}, resp)
+}
+
+func TestPostTreesPointCloud(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	standing, short := pointCloudTrees(estateId)
+	created := uuid.New()
+	input := repository.TreeRequest{EstateId: estateId, X: 1, Y: 1, Height: 12}
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+	repo.EXPECT().ListTrees(gomock.Any(), estateId).Return(standing, nil)
+	expectHeightLimit(repo)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, input).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), input).Return(repository.TreeResponse{Id: created}, nil)
+	repo.EXPECT().UpdateTree(gomock.Any(), repository.TreeUpdate{
+		EstateId: estateId, Id: short.String(), Height: ptr(8), Version: 4,
+	}).Return(repository.TreeData{}, nil)
+
+	resp, err := s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:   estateId,
+		Body: upload(t, "file", "cloud.csv", "text/csv", canopyCloud),
+	})
+	require.NoError(t, err)
+	body := resp.(generated.PostTreesPointCloud200JSONResponse)
+	require.False(t, body.DryRun)
+	require.Equal(t, []generated.TreeChange{{Id: &created, X: 1, Y: 1, Height: 12}}, body.Created)
+	require.Len(t, body.Updated, 1)
+	require.Len(t, body.Skipped, 1)
+}
+
+func TestPostTreesPointCloudMinHeight(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	standing, _ := pointCloudTrees(estateId)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+	repo.EXPECT().ListTrees(gomock.Any(), estateId).Return(standing, nil)
+	expectHeightLimit(repo)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), gomock.Any()).Return(repository.TreeResponse{Id: uuid.New()}, nil)
+
+	resp, err := s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:     estateId,
+		Params: generated.PostTreesPointCloudParams{MinHeight: ptr(9)},
+		Body:   upload(t, "file", "cloud.csv", "text/csv", canopyCloud),
+	})
+	require.NoError(t, err)
+	body := resp.(generated.PostTreesPointCloud200JSONResponse)
+	require.Len(t, body.Created, 1)
+	require.Empty(t, body.Updated, "the 8m canopy is below min_height")
+	require.Equal(t, 1, body.Unchanged)
+}
+
+func TestPostTreesPointCloudDatabaseError(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	standing, _ := pointCloudTrees(estateId)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+	repo.EXPECT().ListTrees(gomock.Any(), estateId).Return(standing, nil)
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), gomock.Any()).Return(context.DeadlineExceeded)
+
+	resp, err := s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:   estateId,
+		Body: upload(t, "file", "cloud.csv", "text/csv", canopyCloud),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTreesPointCloud500JSONResponse{Message: "internal server error"}, resp)
+}
+
+func TestPostTreesPointCloudConflict(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	standing, _ := pointCloudTrees(estateId)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+	repo.EXPECT().ListTrees(gomock.Any(), estateId).Return(standing, nil)
+	expectHeightLimit(repo)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), gomock.Any()).Return(repository.TreeResponse{Id: uuid.New()}, nil)
+	repo.EXPECT().UpdateTree(gomock.Any(), gomock.Any()).Return(repository.TreeData{}, repository.ErrVersionMismatch)
+
+	resp, err := s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:   estateId,
+		Body: upload(t, "file", "cloud.csv", "text/csv", canopyCloud),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTreesPointCloud409JSONResponse{Message: errTreesChanged.Error()}, resp)
+
+	resp, err = s.PostTreesPointCloud(context.Background(), generated.PostTreesPointCloudRequestObject{
+		Id:     estateId,
+		Params: generated.PostTreesPointCloudParams{MinHeight: ptr(0)},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostTreesPointCloud400JSONResponse{}, resp)
+}
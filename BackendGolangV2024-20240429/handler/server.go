@@ -1,17 +1,75 @@
 package handler
 
-import "github.com/SawitProRecruitment/UserService/repository"
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/SawitProRecruitment/UserService/events"
+	"github.com/SawitProRecruitment/UserService/metrics"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
 
 type Server struct {
 	Repository repository.RepositoryInterface
+	Logger     *slog.Logger
+	Metrics    *metrics.Metrics
+	Drone      DroneOptions
+	Events     *events.Broker
+
+	healthChecks []HealthCheck
+	ready        atomic.Bool
 }
 
 type NewServerOptions struct {
 	Repository repository.RepositoryInterface
+	// Logger defaults to slog.Default() when nil.
+	Logger *slog.Logger
+	// Metrics is optional; planner metrics are not recorded when nil.
+	Metrics *metrics.Metrics
+	// Drone defaults to DefaultDroneOptions when zero.
+	Drone DroneOptions
+	// Events wakes up the event streams. It defaults to a broker nobody
+	// notifies, so streams only see changes when they reconnect.
+	Events *events.Broker
+	// HealthChecks are run by /readyz in addition to the database and
+	// migration checks.
+	HealthChecks []HealthCheck
 }
 
 func NewServer(opts NewServerOptions) *Server {
-	return &Server{
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	drone := opts.Drone
+	if drone == (DroneOptions{}) {
+		drone = DefaultDroneOptions
+	}
+	broker := opts.Events
+	if broker == nil {
+		broker = events.NewBroker()
+	}
+	s := &Server{
 		Repository: opts.Repository,
+		Logger:     logger,
+		Metrics:    opts.Metrics,
+		Drone:      drone,
+		Events:     broker,
 	}
+	s.healthChecks = append(s.defaultHealthChecks(), opts.HealthChecks...)
+	return s
+}
+
+// DroneOptions are the flight parameters of the drone planner.
+type DroneOptions struct {
+	// PlotSizeMeters is the distance flown between two neighbouring plots of
+	// estates without a geo-reference.
+	PlotSizeMeters float64
+	// ClearanceMeters is kept between the drone and the ground or canopy.
+	ClearanceMeters int
+}
+
+var DefaultDroneOptions = DroneOptions{
+	PlotSizeMeters:  10,
+	ClearanceMeters: 1,
 }
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	deletedPurgeInterval = time.Hour
+	errAdminOnly         = "admin token required"
+)
+
+// RestoreRoutes serves POST /estate/{id}:restore and
+// /estate/{id}/tree/{tree_id}:restore, the custom method form of restoring,
+// with the /restore operations. Echo takes a colon in a route for a path
+// parameter, so the spec declares the /restore form and this rewrites the
+// colon form before routing. Register it with Echo.Pre.
+func RestoreRoutes() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if path, ok := strings.CutSuffix(req.URL.Path, ":restore"); ok && req.Method == http.MethodPost {
+				req.URL.Path = path + "/restore"
+				req.URL.RawPath = ""
+			}
+			return next(c)
+		}
+	}
+}
+
+// (POST /estate/{id}/restore)
+func (s *Server) RestoreEstate(ctx context.Context, request generated.RestoreEstateRequestObject) (generated.RestoreEstateResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.RestoreEstate403JSONResponse{Message: errAdminOnly}, nil
+	}
+	estate, err := s.Repository.RestoreEstate(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.RestoreEstate404JSONResponse{Message: "estate not found"}, nil
+	}
+	if errors.Is(err, repository.ErrNotDeleted) {
+		return generated.RestoreEstate409JSONResponse{Message: "estate is not deleted"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "restoring estate", "estate_id", request.Id, "error", err)
+		return generated.RestoreEstate500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.RestoreEstate200JSONResponse{
+		Body:    estateBody(estate),
+		Headers: generated.RestoreEstate200ResponseHeaders{ETag: versionETag(estate.Version)},
+	}, nil
+}
+
+// (POST /estate/{id}/tree/{tree_id}/restore)
+func (s *Server) RestoreTree(ctx context.Context, request generated.RestoreTreeRequestObject) (generated.RestoreTreeResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.RestoreTree403JSONResponse{Message: errAdminOnly}, nil
+	}
+	tree, err := s.Repository.RestoreTree(ctx, request.Id, request.TreeId)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.RestoreTree404JSONResponse{Message: "estate not found"}, nil
+	}
+	if errors.Is(err, repository.ErrTreeNotFound) {
+		return generated.RestoreTree404JSONResponse{Message: "tree not found"}, nil
+	}
+	if errors.Is(err, repository.ErrNotDeleted) {
+		return generated.RestoreTree409JSONResponse{Message: "tree is not deleted"}, nil
+	}
+	if errors.Is(err, repository.ErrPlotOccupied) || errors.Is(err, repository.ErrTreeOutsideEstate) {
+		return generated.RestoreTree409JSONResponse{Message: err.Error()}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "restoring tree", "estate_id", request.Id, "tree_id", request.TreeId, "error", err)
+		return generated.RestoreTree500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.RestoreTree200JSONResponse{
+		Body:    treeBody(tree),
+		Headers: generated.RestoreTree200ResponseHeaders{ETag: versionETag(tree.Version)},
+	}, nil
+}
+
+// RunDeletedPurge hard deletes estates and trees deleted longer than retention
+// ago, every hour until ctx is done.
+func (s *Server) RunDeletedPurge(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(deletedPurgeInterval)
+	defer ticker.Stop()
+	ctx = repository.WithActor(ctx, repository.SystemActor)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.purgeDeleted(ctx, time.Now().Add(-retention))
+	}
+}
+
+func (s *Server) purgeDeleted(ctx context.Context, deletedBefore time.Time) {
+	estates, trees, err := s.Repository.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		s.Logger.WarnContext(ctx, "purging deleted estates and trees", "error", err)
+		return
+	}
+	if estates > 0 || trees > 0 {
+		s.Logger.InfoContext(ctx, "purged deleted estates and trees", "estates", estates, "trees", trees)
+	}
+}
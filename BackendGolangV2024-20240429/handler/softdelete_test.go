@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+var adminCtx = context.WithValue(context.Background(), adminKey{}, true)
+
+func TestAdminMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		token, header string
+		admin         bool
+	}{
+		{"secret", "Bearer secret", true},
+		{"secret", "Bearer wrong", false},
+		{"secret", "secret", false},
+		{"", "Bearer ", false},
+	} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/estate", nil)
+		req.Header.Set(echo.HeaderAuthorization, tc.header)
+		c := e.NewContext(req, httptest.NewRecorder())
+
+		var admin bool
+		err := AdminMiddleware(tc.token)(func(c echo.Context) error {
+			admin = isAdmin(c.Request().Context())
+			return nil
+		})(c)
+		require.NoError(t, err)
+		require.Equal(t, tc.admin, admin, "token %q header %q", tc.token, tc.header)
+	}
+}
+
+func TestIncludeDeletedRequiresAdmin(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	include := true
+
+	resp, err := s.GetEstate(context.Background(), generated.GetEstateRequestObject{
+		Id:     id.String(),
+		Params: generated.GetEstateParams{IncludeDeleted: &include},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetEstate403JSONResponse{}, resp)
+
+	deletedAt := time.Now()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).
+		Return(repository.EstateData{Id: id, Length: 5, Width: 5, Version: 2, DeletedAt: &deletedAt}, nil)
+	resp, err = s.GetEstate(adminCtx, generated.GetEstateRequestObject{
+		Id:     id.String(),
+		Params: generated.GetEstateParams{IncludeDeleted: &include},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &deletedAt, resp.(generated.GetEstate200JSONResponse).Body.DeletedAt)
+}
+
+func TestRestoreEstate(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+
+	resp, err := s.RestoreEstate(context.Background(), generated.RestoreEstateRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.IsType(t, generated.RestoreEstate403JSONResponse{}, resp)
+
+	repo.EXPECT().RestoreEstate(gomock.Any(), id.String()).Return(repository.EstateData{}, repository.ErrNotDeleted)
+	resp, err = s.RestoreEstate(adminCtx, generated.RestoreEstateRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.IsType(t, generated.RestoreEstate409JSONResponse{}, resp)
+
+	repo.EXPECT().RestoreEstate(gomock.Any(), id.String()).
+		Return(repository.EstateData{Id: id, Length: 5, Width: 5, Version: 2}, nil)
+	resp, err = s.RestoreEstate(adminCtx, generated.RestoreEstateRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.Equal(t, `"2"`, resp.(generated.RestoreEstate200JSONResponse).Headers.ETag)
+}
+
+func TestRestoreTreeConflicts(t *testing.T) {
+	s, repo := newTestServer(t)
+	request := generated.RestoreTreeRequestObject{Id: uuid.NewString(), TreeId: uuid.NewString()}
+
+	for _, err := range []error{
+		repository.ErrNotDeleted,
+		fmt.Errorf("x=1 y=1: %w", repository.ErrPlotOccupied),
+		fmt.Errorf("x=9 y=1 in 5x5: %w", repository.ErrTreeOutsideEstate),
+	} {
+		repo.EXPECT().RestoreTree(gomock.Any(), request.Id, request.TreeId).Return(repository.TreeData{}, err)
+		resp, herr := s.RestoreTree(adminCtx, request)
+		require.NoError(t, herr)
+		require.IsType(t, generated.RestoreTree409JSONResponse{}, resp, err)
+	}
+
+	repo.EXPECT().RestoreTree(gomock.Any(), request.Id, request.TreeId).Return(repository.TreeData{}, repository.ErrEstateNotFound)
+	resp, err := s.RestoreTree(adminCtx, request)
+	require.NoError(t, err)
+	require.Equal(t, generated.RestoreTree404JSONResponse{Message: "estate not found"}, resp)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	s, repo := newTestServer(t)
+	cutoff := time.Now().Add(-time.Hour)
+
+	repo.EXPECT().PurgeDeleted(gomock.Any(), cutoff).Return(int64(1), int64(3), nil)
+	s.purgeDeleted(context.Background(), cutoff)
+}
+
+func TestRestoreRoutes(t *testing.T) {
+	e := echo.New()
+	e.Pre(RestoreRoutes())
+	e.POST("/estate/:id/restore", func(c echo.Context) error {
+		return c.String(http.StatusOK, "estate "+c.Param("id"))
+	})
+	e.POST("/estate/:id/tree/:tree_id/restore", func(c echo.Context) error {
+		return c.String(http.StatusOK, "tree "+c.Param("tree_id"))
+	})
+
+	for path, want := range map[string]string{
+		"/estate/e1:restore":         "estate e1",
+		"/estate/e1%3Arestore":       "estate e1",
+		"/estate/e1/restore":         "estate e1",
+		"/estate/e1/tree/t1:restore": "tree t1",
+		"/estate/e1/tree/t1/restore": "tree t1",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		require.Equal(t, want, rec.Body.String(), path)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/estate/e1:restore", nil))
+	require.NotEqual(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// maxSpeciesNameLength matches the species column.
+const maxSpeciesNameLength = 100
+
+// (GET /species)
+func (s *Server) ListSpecies(ctx context.Context, request generated.ListSpeciesRequestObject) (generated.ListSpeciesResponseObject, error) {
+	species, err := s.Repository.ListSpecies(ctx)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing species", "error", err)
+		return generated.ListSpecies500JSONResponse{Message: "internal server error"}, nil
+	}
+	response := generated.ListSpecies200JSONResponse{}
+	for _, s := range species {
+		response = append(response, generated.Species{Name: s.Name, MaxHeight: s.MaxHeight})
+	}
+	return response, nil
+}
+
+// (PUT /species/{name})
+func (s *Server) PutSpecies(ctx context.Context, request generated.PutSpeciesRequestObject) (generated.PutSpeciesResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.PutSpecies403JSONResponse{Message: errAdminOnly}, nil
+	}
+	if request.Body == nil {
+		return generated.PutSpecies400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	if request.Name == "" || len(request.Name) > maxSpeciesNameLength {
+		return generated.PutSpecies400JSONResponse{Message: "species names must have between 1 and 100 characters"}, nil
+	}
+	if request.Body.MaxHeight < 1 {
+		return generated.PutSpecies400JSONResponse{Message: "max_height must be positive"}, nil
+	}
+	species, err := s.Repository.PutSpecies(ctx, repository.Species{Name: request.Name, MaxHeight: request.Body.MaxHeight})
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "storing species", "species", request.Name, "error", err)
+		return generated.PutSpecies500JSONResponse{Message: "internal server error"}, nil
+	}
+	s.Logger.InfoContext(ctx, "stored species", "species", species.Name, "max_height", species.MaxHeight)
+	return generated.PutSpecies200JSONResponse{Name: species.Name, MaxHeight: species.MaxHeight}, nil
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutSpecies(t *testing.T) {
+	s, repo := newTestServer(t)
+	body := &generated.SpeciesRequest{MaxHeight: 25}
+
+	resp, err := s.PutSpecies(context.Background(), generated.PutSpeciesRequestObject{Name: "Cocos nucifera", Body: body})
+	require.NoError(t, err)
+	require.IsType(t, generated.PutSpecies403JSONResponse{}, resp)
+
+	resp, err = s.PutSpecies(adminCtx, generated.PutSpeciesRequestObject{
+		Name: "Cocos nucifera",
+		Body: &generated.SpeciesRequest{MaxHeight: 0},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PutSpecies400JSONResponse{Message: "max_height must be positive"}, resp)
+
+	species := repository.Species{Name: "Cocos nucifera", MaxHeight: 25}
+	repo.EXPECT().PutSpecies(gomock.Any(), species).Return(species, nil)
+	resp, err = s.PutSpecies(adminCtx, generated.PutSpeciesRequestObject{Name: "Cocos nucifera", Body: body})
+	require.NoError(t, err)
+	require.Equal(t, generated.PutSpecies200JSONResponse{Name: "Cocos nucifera", MaxHeight: 25}, resp)
+}
+
+func TestPostTreeDetails(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	planted := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	input := repository.TreeRequest{
+		EstateId: estateId, X: 1, Y: 2, Height: 10,
+		Species: "Elaeis guineensis", Variety: "Tenera", PlantedOn: &planted,
+		Health: repository.HealthDiseased, Attributes: map[string]any{"clone": "DxP"},
+	}
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, input).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), input).Return(repository.TreeResponse{Id: uuid.New()}, nil)
+
+	body := plotTree(1, 2, 10)
+	body.Species, body.Variety = ptr("Elaeis guineensis"), ptr("Tenera")
+	body.PlantedOn = &openapi_types.Date{Time: planted}
+	body.Health = ptr(generated.Diseased)
+	body.Attributes = &map[string]interface{}{"clone": "DxP"}
+	resp, err := s.PostTree(context.Background(), generated.PostTreeRequestObject{Id: estateId, Body: &body})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostTree200JSONResponse{}, resp)
+}
+
+func TestPatchTreeFields(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.New(), uuid.New()
+	request := generated.PatchTreeRequestObject{
+		Id:     estateId.String(),
+		TreeId: treeId.String(),
+		Params: generated.PatchTreeParams{IfMatch: ifMatch(1)},
+		Body:   &generated.TreeUpdateRequest{},
+	}
+	resp, err := s.PatchTree(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, generated.PatchTree400JSONResponse{Message: "no fields to update"}, resp)
+
+	input := repository.TreeUpdate{
+		EstateId: estateId.String(), Id: treeId.String(),
+		Species: ptr(""), Health: ptr(repository.HealthDead), Version: 1,
+	}
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), input).Return(nil)
+	repo.EXPECT().UpdateTree(gomock.Any(), input).Return(repository.TreeData{
+		Id: treeId, EstateId: estateId.String(), X: 1, Y: 1, Height: 9,
+		Variety: "Tenera", Health: repository.HealthDead, Version: 2,
+	}, nil)
+
+	request.Body = &generated.TreeUpdateRequest{Species: ptr(""), Health: ptr(generated.Dead)}
+	resp, err = s.PatchTree(context.Background(), request)
+	require.NoError(t, err)
+	tree := resp.(generated.PatchTree200JSONResponse).Body
+	require.Nil(t, tree.Species, "the species was cleared")
+	require.Equal(t, "Tenera", *tree.Variety)
+	require.Equal(t, generated.Dead, tree.Health)
+}
+
+func TestPatchTreeRaceAgainstSpeciesChange(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, treeId := uuid.NewString(), uuid.NewString()
+	input := repository.TreeUpdate{EstateId: estateId, Id: treeId, Height: ptr(25), Version: repository.AnyVersion}
+	// The tree passed validation as a taller species, which a concurrent
+	// update changed before UpdateTree locked it.
+	repo.EXPECT().ValidateTreeUpdate(gomock.Any(), input).Return(nil)
+	repo.EXPECT().UpdateTree(gomock.Any(), input).Return(repository.TreeData{}, &repository.ValidationError{
+		Err: errors.New("height (25) exceeds the maximum allowed value for dwarf (20)"),
+	})
+
+	wildcard := "*"
+	resp, err := s.PatchTree(context.Background(), generated.PatchTreeRequestObject{
+		Id:     estateId,
+		TreeId: treeId,
+		Params: generated.PatchTreeParams{IfMatch: &wildcard},
+		Body:   &generated.TreeUpdateRequest{Height: ptr(25)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PatchTree400JSONResponse{Message: "height (25) exceeds the maximum allowed value for dwarf (20)"}, resp)
+}
+
+func TestGetStatsGroupBy(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+
+	resp, err := s.GetStats(context.Background(), generated.GetStatsRequestObject{
+		Id:     id,
+		Params: generated.GetStatsParams{GroupBy: &[]generated.GetStatsParamsGroupBy{"age"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.GetStats400JSONResponse{Message: `cannot group by "age"`}, resp)
+
+	repo.EXPECT().GetEstateStats(gomock.Any(), id).
+		Return(repository.EstateStats{Count: 3, MaxHeight: 20, MinHeight: 10, Median: 12}, nil)
+	repo.EXPECT().GetEstateStatsGroups(gomock.Any(), id, []string{"species"}).Return([]repository.EstateStatsGroup{
+		{EstateStats: repository.EstateStats{Count: 1, MaxHeight: 10, MinHeight: 10, Median: 10}},
+		{Species: "Elaeis guineensis", EstateStats: repository.EstateStats{Count: 2, MaxHeight: 20, MinHeight: 12, Median: 16}},
+	}, nil)
+
+	resp, err = s.GetStats(context.Background(), generated.GetStatsRequestObject{
+		Id: id,
+		Params: generated.GetStatsParams{GroupBy: &[]generated.GetStatsParamsGroupBy{
+			generated.GetStatsParamsGroupBySpecies, generated.GetStatsParamsGroupBySpecies,
+		}},
+	})
+	require.NoError(t, err)
+	groups := *resp.(generated.GetStats200JSONResponse).Body.Groups
+	require.Equal(t, []generated.EstateStatsGroup{
+		{Species: ptr(""), Count: 1, Max: 10, Min: 10, Median: 10},
+		{Species: ptr("Elaeis guineensis"), Count: 2, Max: 20, Min: 12, Median: 16},
+	}, groups)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/survey"
+)
+
+// maxSurveyFeatures caps a survey upload. Like a point cloud, a survey covers
+// a whole estate.
+const maxSurveyFeatures = maxPointCloudTrees
+
+// (POST /estate/{id}/trees/survey)
+func (s *Server) PostTreesSurvey(ctx context.Context, request generated.PostTreesSurveyRequestObject) (generated.PostTreesSurveyResponseObject, error) {
+	params := request.Params
+	dryRun := params.DryRun != nil && *params.DryRun
+	heightAttribute := survey.DefaultHeightAttribute
+	if params.HeightAttribute != nil && *params.HeightAttribute != "" {
+		heightAttribute = *params.HeightAttribute
+	}
+
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostTreesSurvey404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.PostTreesSurvey500JSONResponse{Message: "internal server error"}, nil
+	}
+	if estate.GeoReference == nil {
+		return generated.PostTreesSurvey409JSONResponse{Message: errNotGeoReferenced.Error()}, nil
+	}
+
+	file, err := uploadedFile(request.Body)
+	if err != nil {
+		return generated.PostTreesSurvey400JSONResponse{Message: err.Error()}, nil
+	}
+	defer file.Close()
+	features, err := survey.Read(file, heightAttribute, maxSurveyFeatures)
+	if err != nil {
+		return generated.PostTreesSurvey400JSONResponse{Message: err.Error()}, nil
+	}
+
+	placed := placeSurvey(estate, features)
+	var result generated.SurveyImport
+	apply := func(repo repository.RepositoryInterface) error {
+		// The transaction may be retried from the start.
+		result = placed.result
+		result.Created = []generated.SurveyFeature{}
+		return importSurvey(ctx, repo, request.Id, placed.trees, &result, dryRun)
+	}
+	if dryRun {
+		err = apply(s.Repository)
+	} else {
+		err = s.Repository.WithTx(ctx, apply)
+	}
+	if errors.Is(err, errTreesChanged) {
+		return generated.PostTreesSurvey409JSONResponse{Message: err.Error()}, nil
+	}
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostTreesSurvey404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "importing survey", "estate_id", request.Id, "error", err)
+		return generated.PostTreesSurvey500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	result.DryRun = dryRun
+	result.Features = len(features)
+	if !dryRun {
+		s.Logger.InfoContext(ctx, "imported survey", "estate_id", request.Id, "created", len(result.Created))
+	}
+	return generated.PostTreesSurvey200JSONResponse(result), nil
+}
+
+// surveyPlacement is a survey placed on the plots of an estate: the trees to
+// add, and the features skipped before they reach the database.
+type surveyPlacement struct {
+	trees  []generated.SurveyFeature
+	result generated.SurveyImport
+}
+
+// placeSurvey rounds every feature to the nearest plot of the estate. Points
+// outside it and points sharing a plot are skipped.
+func placeSurvey(estate repository.EstateData, features []survey.Feature) surveyPlacement {
+	placement := surveyPlacement{result: generated.SurveyImport{
+		Outside:    []generated.SurveyFeature{},
+		Collisions: []generated.SurveyFeature{},
+		Rejected:   []generated.SurveyFeature{},
+	}}
+	reject := func(feature generated.SurveyFeature, err error) {
+		reason := err.Error()
+		feature.Reason = &reason
+		placement.result.Rejected = append(placement.result.Rejected, feature)
+	}
+
+	var inside []generated.SurveyFeature
+	plots := map[[2]int]int{}
+	for _, f := range features {
+		feature := generated.SurveyFeature{Index: f.Index}
+		if f.Err != nil {
+			reject(feature, f.Err)
+			continue
+		}
+		feature.Latitude, feature.Longitude, feature.Height = &f.Latitude, &f.Longitude, &f.Height
+		x, y, err := treePlot(generated.TreeRequest{Latitude: &f.Latitude, Longitude: &f.Longitude}, estate.GeoReference)
+		if err != nil {
+			reject(feature, err)
+			continue
+		}
+		feature.X, feature.Y = &x, &y
+		if x < 1 || x > estate.Length || y < 1 || y > estate.Width {
+			placement.result.Outside = append(placement.result.Outside, feature)
+			continue
+		}
+		inside = append(inside, feature)
+		plots[[2]int{x, y}]++
+	}
+
+	for _, feature := range inside {
+		if count := plots[[2]int{*feature.X, *feature.Y}]; count > 1 {
+			reason := fmt.Sprintf("%d features stand on plot (%d,%d)", count, *feature.X, *feature.Y)
+			feature.Reason = &reason
+			placement.result.Collisions = append(placement.result.Collisions, feature)
+			continue
+		}
+		placement.trees = append(placement.trees, feature)
+	}
+	return placement
+}
+
+// importSurvey validates every tree like a single insert and, unless it is a
+// dry run, adds the valid ones with repo, which is then expected to be bound
+// to a transaction. Trees on planted plots are collisions. Database errors
+// abort the import.
+func importSurvey(ctx context.Context, repo repository.RepositoryInterface, estateId string, trees []generated.SurveyFeature, result *generated.SurveyImport, dryRun bool) error {
+	for _, tree := range trees {
+		input := repository.TreeRequest{EstateId: estateId, X: *tree.X, Y: *tree.Y, Height: *tree.Height}
+		err := repo.ValidateTreeRequest(ctx, estateId, input)
+		var invalid *repository.ValidationError
+		if err != nil && !errors.As(err, &invalid) {
+			return fmt.Errorf("feature %d: %w", tree.Index, err)
+		}
+		if err != nil {
+			reason := err.Error()
+			tree.Reason = &reason
+			if errors.Is(err, repository.ErrPlotOccupied) {
+				result.Collisions = append(result.Collisions, tree)
+			} else {
+				result.Rejected = append(result.Rejected, tree)
+			}
+			continue
+		}
+		if !dryRun {
+			response, err := repo.InsertTree(ctx, input)
+			if errors.Is(err, repository.ErrPlotOccupied) {
+				return errTreesChanged
+			}
+			if err != nil {
+				return fmt.Errorf("feature %d: %w", tree.Index, err)
+			}
+			tree.Id = &response.Id
+		}
+		result.Created = append(result.Created, tree)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// surveyCollection places GeoJSON points on plots of ref; a height of 0
+// leaves the attribute out.
+func surveyCollection(ref geo.Reference, points ...[3]int) string {
+	features := make([]string, len(points))
+	for i, point := range points {
+		latitude, longitude := ref.ToWGS84(float64(point[0]), float64(point[1]))
+		properties := "{}"
+		if point[2] > 0 {
+			properties = fmt.Sprintf(`{"height": %d}`, point[2])
+		}
+		features[i] = fmt.Sprintf(`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [%v, %v]}, "properties": %s}`,
+			longitude, latitude, properties)
+	}
+	return `{"type": "FeatureCollection", "features": [` + strings.Join(features, ",") + `]}`
+}
+
+func TestPostTreesSurvey(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	ref := geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: 90, PlotSizeMeters: 10}
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).
+		Return(repository.EstateData{Length: 4, Width: 1, GeoReference: &ref}, nil)
+
+	planted := repository.TreeRequest{EstateId: estateId, X: 1, Y: 1, Height: 12}
+	occupied := repository.TreeRequest{EstateId: estateId, X: 2, Y: 1, Height: 9}
+	id := uuid.New()
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, planted).Return(nil)
+	repo.EXPECT().InsertTree(gomock.Any(), planted).Return(repository.TreeResponse{Id: id}, nil)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, occupied).
+		Return(&repository.ValidationError{Err: fmt.Errorf("x=2 y=1: %w", repository.ErrPlotOccupied)})
+
+	collection := surveyCollection(ref,
+		[3]int{1, 1, 12},
+		[3]int{2, 1, 9},
+		[3]int{3, 1, 10},
+		[3]int{3, 1, 11},
+		[3]int{9, 1, 10},
+		[3]int{4, 1, 0},
+	)
+	resp, err := s.PostTreesSurvey(context.Background(), generated.PostTreesSurveyRequestObject{
+		Id:   estateId,
+		Body: upload(t, "file", "trees.geojson", "application/geo+json", collection),
+	})
+	require.NoError(t, err)
+	result := resp.(generated.PostTreesSurvey200JSONResponse)
+	require.Equal(t, 6, result.Features)
+	require.Len(t, result.Created, 1)
+	require.Equal(t, &id, result.Created[0].Id)
+	require.Equal(t, 0, result.Created[0].Index)
+
+	require.Len(t, result.Outside, 1)
+	require.Equal(t, 4, result.Outside[0].Index)
+	require.Equal(t, 9, *result.Outside[0].X)
+
+	require.Len(t, result.Collisions, 3)
+	require.Equal(t, "2 features stand on plot (3,1)", *result.Collisions[0].Reason)
+	require.Equal(t, 3, result.Collisions[1].Index)
+	require.Equal(t, 1, result.Collisions[2].Index, "the plot is planted")
+
+	require.Len(t, result.Rejected, 1)
+	require.Equal(t, "no height", *result.Rejected[0].Reason)
+}
+
+func TestPostTreesSurveyDryRun(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	ref := geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: 0, PlotSizeMeters: 10}
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).
+		Return(repository.EstateData{Length: 4, Width: 1, GeoReference: &ref}, nil)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(nil)
+
+	resp, err := s.PostTreesSurvey(context.Background(), generated.PostTreesSurveyRequestObject{
+		Id:     estateId,
+		Params: generated.PostTreesSurveyParams{DryRun: ptr(true)},
+		Body:   upload(t, "file", "trees.geojson", "application/geo+json", surveyCollection(ref, [3]int{2, 1, 12})),
+	})
+	require.NoError(t, err)
+	result := resp.(generated.PostTreesSurvey200JSONResponse)
+	require.True(t, result.DryRun)
+	require.Len(t, result.Created, 1)
+	require.Nil(t, result.Created[0].Id)
+	require.Equal(t, 2, *result.Created[0].X)
+}
+
+func TestPostTreesSurveyNotGeoReferenced(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId := uuid.NewString()
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{Length: 4, Width: 1}, nil)
+
+	resp, err := s.PostTreesSurvey(context.Background(), generated.PostTreesSurveyRequestObject{Id: estateId})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostTreesSurvey409JSONResponse{Message: errNotGeoReferenced.Error()}, resp)
+}
+
+func TestPostTreesSurveyDatabaseError(t *testing.T) {
+	s, repo := newTestServer(t)
+	expectTx(repo)
+	estateId := uuid.NewString()
+	ref := geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: 0, PlotSizeMeters: 10}
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).
+		Return(repository.EstateData{Length: 4, Width: 1, GeoReference: &ref}, nil)
+	repo.EXPECT().ValidateTreeRequest(gomock.Any(), estateId, gomock.Any()).Return(context.DeadlineExceeded)
+
+	resp, err := s.PostTreesSurvey(context.Background(), generated.PostTreesSurveyRequestObject{
+		Id:   estateId,
+		Body: upload(t, "file", "trees.geojson", "application/geo+json", surveyCollection(ref, [3]int{2, 1, 12})),
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostTreesSurvey500JSONResponse{}, resp, "a failing database rejects no feature")
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/telemetry"
+)
+
+// maxTelemetrySamples caps an upload; longer flights are uploaded in parts.
+const maxTelemetrySamples = 100000
+
+// (POST /estate/{id}/missions/{mission_id}/telemetry)
+func (s *Server) PostMissionTelemetry(ctx context.Context, request generated.PostMissionTelemetryRequestObject) (generated.PostMissionTelemetryResponseObject, error) {
+	mission, err := s.Repository.GetMission(ctx, request.Id, request.MissionId)
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.PostMissionTelemetry404JSONResponse{Message: "mission not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting mission", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.PostMissionTelemetry500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	// Samples logged in WGS84 are placed by the geo-reference of the estate.
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PostMissionTelemetry404JSONResponse{Message: "mission not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.PostMissionTelemetry500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	file, format, err := telemetryFile(request.Body)
+	if err != nil {
+		return generated.PostMissionTelemetry400JSONResponse{Message: err.Error()}, nil
+	}
+	defer file.Close()
+	samples, err := telemetry.Parse(file, format, mission.PlotSizeMeters, estate.GeoReference, maxTelemetrySamples)
+	if err != nil {
+		return generated.PostMissionTelemetry400JSONResponse{Message: err.Error()}, nil
+	}
+
+	stored, err := s.Repository.InsertTelemetry(ctx, request.Id, request.MissionId, samples)
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.PostMissionTelemetry404JSONResponse{Message: "mission not found"}, nil
+	}
+	if errors.Is(err, repository.ErrMissionNotFlown) {
+		return generated.PostMissionTelemetry409JSONResponse{Message: "mission has not taken off"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "storing telemetry", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.PostMissionTelemetry500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PostMissionTelemetry200JSONResponse{Received: len(samples), Stored: int(stored)}, nil
+}
+
+// telemetryFile finds the file part of an upload. Files sent as text/csv or
+// named *.csv are CSV, everything else NDJSON.
+func telemetryFile(form *multipart.Reader) (*multipart.Part, telemetry.Format, error) {
+	part, err := uploadedFile(form)
+	if err != nil {
+		return nil, 0, err
+	}
+	format := telemetry.NDJSON
+	if strings.HasPrefix(part.Header.Get("Content-Type"), "text/csv") ||
+		strings.HasSuffix(strings.ToLower(part.FileName()), ".csv") {
+		format = telemetry.CSV
+	}
+	return part, format, nil
+}
+
+// uploadedFile finds the part of a form named file.
+func uploadedFile(form *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := form.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is required")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// (GET /estate/{id}/missions/{mission_id}/report)
+func (s *Server) GetMissionReport(ctx context.Context, request generated.GetMissionReportRequestObject) (generated.GetMissionReportResponseObject, error) {
+	mission, err := s.Repository.GetMission(ctx, request.Id, request.MissionId)
+	if errors.Is(err, repository.ErrMissionNotFound) {
+		return generated.GetMissionReport404JSONResponse{Message: "mission not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting mission", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.GetMissionReport500JSONResponse{Message: "internal server error"}, nil
+	}
+	samples, err := s.Repository.ListTelemetry(ctx, request.Id, request.MissionId)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing telemetry", "estate_id", request.Id, "mission_id", request.MissionId, "error", err)
+		return generated.GetMissionReport500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	report := telemetry.Compare(mission, samples)
+	body := generated.GetMissionReport200JSONResponse{
+		Samples:                report.Samples,
+		StartedAt:              report.StartedAt,
+		EndedAt:                report.EndedAt,
+		PlannedDistance:        report.PlannedDistance,
+		ActualDistance:         float32(report.ActualDistance),
+		PlannedPlots:           report.PlannedPlots,
+		VisitedPlots:           report.VisitedPlots,
+		MissedPlotCount:        report.MissedPlotCount,
+		MissedPlots:            make([]generated.Plot, len(report.MissedPlots)),
+		AltitudeViolationCount: report.AltitudeViolationCount,
+		AltitudeViolations:     make([]generated.AltitudeViolation, len(report.AltitudeViolations)),
+		OutsideSamples:         report.OutsideSamples,
+	}
+	for i, plot := range report.MissedPlots {
+		body.MissedPlots[i] = generated.Plot{X: plot.X, Y: plot.Y}
+	}
+	for i, violation := range report.AltitudeViolations {
+		body.AltitudeViolations[i] = generated.AltitudeViolation{
+			Time:             violation.Time,
+			X:                violation.Plot.X,
+			Y:                violation.Plot.Y,
+			Altitude:         float32(violation.Altitude),
+			RequiredAltitude: violation.RequiredAltitude,
+		}
+	}
+	if report.BatteryUsed != nil {
+		used := float32(*report.BatteryUsed)
+		body.BatteryUsed = &used
+	}
+	return body, nil
+}
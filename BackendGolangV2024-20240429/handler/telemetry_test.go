@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// upload encodes a single file as a multipart form.
+func upload(t *testing.T, field, filename, contentType, content string) *multipart.Reader {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+	return multipart.NewReader(&body, form.Boundary())
+}
+
+func TestPostMissionTelemetryCSV(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(repository.Mission{PlotSizeMeters: 10}, nil)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{}, nil)
+	repo.EXPECT().InsertTelemetry(gomock.Any(), estateId, missionId, gomock.Len(2)).Return(int64(1), nil)
+
+	log := "time,local_x,local_y,altitude\n2024-05-01T08:00:00Z,0,0,1\n2024-05-01T08:00:01Z,10,0,11\n"
+	resp, err := s.PostMissionTelemetry(context.Background(), generated.PostMissionTelemetryRequestObject{
+		Id:        estateId,
+		MissionId: missionId,
+		Body:      upload(t, "file", "flight.csv", "application/octet-stream", log),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostMissionTelemetry200JSONResponse{Received: 2, Stored: 1}, resp)
+}
+
+func TestPostMissionTelemetryRejected(t *testing.T) {
+	s, repo := newTestServer(t)
+	estateId, missionId := uuid.NewString(), uuid.NewString()
+	repo.EXPECT().GetMission(gomock.Any(), estateId, missionId).Return(repository.Mission{PlotSizeMeters: 10}, nil).Times(3)
+	repo.EXPECT().GetEstateById(gomock.Any(), estateId).Return(repository.EstateData{}, nil).Times(3)
+	log := `{"time":"2024-05-01T08:00:00Z","x":1,"y":1,"altitude":1}`
+
+	resp, err := s.PostMissionTelemetry(context.Background(), generated.PostMissionTelemetryRequestObject{
+		Id: estateId, MissionId: missionId, Body: upload(t, "log", "flight.ndjson", "application/x-ndjson", log),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PostMissionTelemetry400JSONResponse{Message: "file is required"}, resp)
+
+	resp, err = s.PostMissionTelemetry(context.Background(), generated.PostMissionTelemetryRequestObject{
+		Id: estateId, MissionId: missionId, Body: upload(t, "file", "flight.ndjson", "application/x-ndjson", "{"),
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostMissionTelemetry400JSONResponse{}, resp)
+
+	repo.EXPECT().InsertTelemetry(gomock.Any(), estateId, missionId, gomock.Len(1)).Return(int64(0), repository.ErrMissionNotFlown)
+	resp, err = s.PostMissionTelemetry(context.Background(), generated.PostMissionTelemetryRequestObject{
+		Id: estateId, MissionId: missionId, Body: upload(t, "file", "flight.ndjson", "application/x-ndjson", log),
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostMissionTelemetry409JSONResponse{}, resp)
+}
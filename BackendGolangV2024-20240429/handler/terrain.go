@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/terrain"
+)
+
+// maxTerrainCells caps an elevation grid at 2000 by 2000 cells.
+const maxTerrainCells = 4000000
+
+// (PUT /estate/{id}/terrain)
+func (s *Server) PutTerrain(ctx context.Context, request generated.PutTerrainRequestObject) (generated.PutTerrainResponseObject, error) {
+	estate, err := s.Repository.GetEstateById(ctx, request.Id)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PutTerrain404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting estate", "estate_id", request.Id, "error", err)
+		return generated.PutTerrain500JSONResponse{Message: "internal server error"}, nil
+	}
+
+	file, err := uploadedFile(request.Body)
+	if err != nil {
+		return generated.PutTerrain400JSONResponse{Message: err.Error()}, nil
+	}
+	defer file.Close()
+	grid, err := terrain.ParseASCIIGrid(file, maxTerrainCells)
+	if err != nil {
+		return generated.PutTerrain400JSONResponse{Message: err.Error()}, nil
+	}
+	// Growing the estate later leaves its new plots level with plot (1,1),
+	// but the grid has to cover the estate as it is now.
+	if err := terrain.NewModel(grid, s.plotSizeMeters(estate)).Check(estate.Length, estate.Width); err != nil {
+		return generated.PutTerrain400JSONResponse{Message: err.Error()}, nil
+	}
+
+	grid, err = s.Repository.PutTerrain(ctx, request.Id, grid)
+	if errors.Is(err, repository.ErrEstateNotFound) {
+		return generated.PutTerrain404JSONResponse{Message: "estate not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "storing terrain", "estate_id", request.Id, "error", err)
+		return generated.PutTerrain500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.PutTerrain200JSONResponse(terrainBody(grid)), nil
+}
+
+// (GET /estate/{id}/terrain)
+func (s *Server) GetTerrain(ctx context.Context, request generated.GetTerrainRequestObject) (generated.GetTerrainResponseObject, error) {
+	grid, err := s.Repository.GetTerrain(ctx, request.Id)
+	if errors.Is(err, repository.ErrTerrainNotFound) {
+		return generated.GetTerrain404JSONResponse{Message: "terrain not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "getting terrain", "estate_id", request.Id, "error", err)
+		return generated.GetTerrain500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.GetTerrain200JSONResponse(terrainBody(grid)), nil
+}
+
+// (DELETE /estate/{id}/terrain)
+func (s *Server) DeleteTerrain(ctx context.Context, request generated.DeleteTerrainRequestObject) (generated.DeleteTerrainResponseObject, error) {
+	err := s.Repository.DeleteTerrain(ctx, request.Id)
+	if errors.Is(err, repository.ErrTerrainNotFound) {
+		return generated.DeleteTerrain404JSONResponse{Message: "terrain not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "deleting terrain", "estate_id", request.Id, "error", err)
+		return generated.DeleteTerrain500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.DeleteTerrain204Response{}, nil
+}
+
+func terrainBody(grid repository.Terrain) generated.Terrain {
+	body := generated.Terrain{
+		Ncols:      grid.Columns,
+		Nrows:      grid.Rows,
+		XLowerLeft: grid.XLowerLeft,
+		YLowerLeft: grid.YLowerLeft,
+		CellSize:   grid.CellSize,
+		UploadedAt: grid.UploadedAt,
+	}
+	for _, elevation := range grid.Elevations {
+		if math.IsNaN(elevation) {
+			continue
+		}
+		if body.MinElevation == nil || elevation < *body.MinElevation {
+			body.MinElevation = &elevation
+		}
+		if body.MaxElevation == nil || elevation > *body.MaxElevation {
+			body.MaxElevation = &elevation
+		}
+	}
+	return body
+}
+
+// terrainModel returns the ground under the plots of an estate, flat without
+// an uploaded terrain.
+func (s *Server) terrainModel(ctx context.Context, estate repository.EstateData) (terrain.Model, error) {
+	grid, err := s.Repository.GetTerrain(ctx, estate.Id.String())
+	if errors.Is(err, repository.ErrTerrainNotFound) {
+		return terrain.Model{}, nil
+	}
+	if err != nil {
+		return terrain.Model{}, err
+	}
+	return terrain.NewModel(grid, s.plotSizeMeters(estate)), nil
+}
+
+// plotSizeMeters is the width of the plots of an estate: that of its
+// geo-reference, or the planner's.
+func (s *Server) plotSizeMeters(estate repository.EstateData) float64 {
+	if estate.GeoReference != nil {
+		return estate.GeoReference.PlotSizeMeters
+	}
+	return s.Drone.PlotSizeMeters
+}
+
+// droneOptions are the flight parameters of the planner over an estate,
+// whose plots are plotSizeMeters apart.
+func (s *Server) droneOptions(estate repository.EstateData) DroneOptions {
+	drone := s.Drone
+	drone.PlotSizeMeters = s.plotSizeMeters(estate)
+	return drone
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// hillGrid rises 5m after the first plot of a 3x1 estate of 10m plots.
+const hillGrid = "ncols 3\nnrows 1\nxllcorner -5\nyllcorner -5\ncellsize 10\n100 105 105\n"
+
+func hill() repository.Terrain {
+	return repository.Terrain{
+		Columns: 3, Rows: 1, XLowerLeft: -5, YLowerLeft: -5, CellSize: 10,
+		Elevations: []float64{100, 105, 105},
+		UploadedAt: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPutTerrain(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 3, Width: 1}, nil)
+	repo.EXPECT().PutTerrain(gomock.Any(), id.String(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, grid repository.Terrain) (repository.Terrain, error) {
+			grid.UploadedAt = hill().UploadedAt
+			return grid, nil
+		})
+
+	resp, err := s.PutTerrain(context.Background(), generated.PutTerrainRequestObject{
+		Id:   id.String(),
+		Body: upload(t, "file", "estate.asc", "text/plain", hillGrid),
+	})
+	require.NoError(t, err)
+	low, high := 100.0, 105.0
+	require.Equal(t, generated.PutTerrain200JSONResponse{
+		Ncols: 3, Nrows: 1, XLowerLeft: -5, YLowerLeft: -5, CellSize: 10,
+		MinElevation: &low, MaxElevation: &high, UploadedAt: hill().UploadedAt,
+	}, resp)
+
+	// The estate is one plot longer than the grid.
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 4, Width: 1}, nil)
+	resp, err = s.PutTerrain(context.Background(), generated.PutTerrainRequestObject{
+		Id:   id.String(),
+		Body: upload(t, "file", "estate.asc", "text/plain", hillGrid),
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.PutTerrain400JSONResponse{Message: "the grid has no elevation for plot (4,1)"}, resp)
+}
+
+func TestDronePlanOverTerrain(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.New()
+	repo.EXPECT().GetEstateById(gomock.Any(), id.String()).Return(repository.EstateData{Id: id, Length: 3, Width: 1}, nil).AnyTimes()
+	repo.EXPECT().GetTreesByEstateId(gomock.Any(), id.String()).Return(nil, nil).AnyTimes()
+	repo.EXPECT().GetTerrain(gomock.Any(), id.String()).Return(hill(), nil).AnyTimes()
+
+	// Take off to 1m, climb 5m onto the hill, and land 1m down on it.
+	resp, err := s.GetEstateIdDronePlan(context.Background(), generated.GetEstateIdDronePlanRequestObject{Id: id.String()})
+	require.NoError(t, err)
+	require.Equal(t, generated.DropPlanResponse{Distance: 27}, resp.(generated.GetEstateIdDronePlan200JSONResponse).Body)
+
+	landing, err := s.GetEstateIdDronePlanWithMaxDistance(context.Background(), generated.GetEstateIdDronePlanWithMaxDistanceRequestObject{
+		Id:     id.String(),
+		Params: generated.GetEstateIdDronePlanWithMaxDistanceParams{MaxDistance: 16},
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated.LandingPoint{X: 2, Y: 1},
+		landing.(generated.GetEstateIdDronePlanWithMaxDistance200JSONResponse).Body.LandingPoint)
+
+	landing, err = s.GetEstateIdDronePlanWithMaxDistance(context.Background(), generated.GetEstateIdDronePlanWithMaxDistanceRequestObject{
+		Id:     id.String(),
+		Params: generated.GetEstateIdDronePlanWithMaxDistanceParams{MaxDistance: 28},
+	})
+	require.NoError(t, err)
+	require.IsType(t, generated.GetEstateIdDronePlanWithMaxDistance400JSONResponse{}, landing)
+}
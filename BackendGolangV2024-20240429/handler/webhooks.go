@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+const maxWebhookURLLength = 2048
+
+// (GET /webhooks)
+func (s *Server) ListWebhooks(ctx context.Context, request generated.ListWebhooksRequestObject) (generated.ListWebhooksResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.ListWebhooks403JSONResponse{Message: errAdminOnly}, nil
+	}
+	subscriptions, err := s.Repository.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "listing webhooks", "error", err)
+		return generated.ListWebhooks500JSONResponse{Message: "internal server error"}, nil
+	}
+	response := generated.ListWebhooks200JSONResponse{}
+	for _, subscription := range subscriptions {
+		response = append(response, webhookBody(subscription))
+	}
+	return response, nil
+}
+
+// (POST /webhooks)
+func (s *Server) PostWebhook(ctx context.Context, request generated.PostWebhookRequestObject) (generated.PostWebhookResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.PostWebhook403JSONResponse{Message: errAdminOnly}, nil
+	}
+	if request.Body == nil {
+		return generated.PostWebhook400JSONResponse{Message: "Request body is missing"}, nil
+	}
+	if err := validateWebhookURL(request.Body.Url); err != nil {
+		return generated.PostWebhook400JSONResponse{Message: err.Error()}, nil
+	}
+	input := repository.WebhookSubscriptionRequest{
+		Url:      request.Body.Url,
+		EstateId: request.Body.EstateId,
+	}
+	if request.Body.EventTypes != nil {
+		for _, eventType := range *request.Body.EventTypes {
+			if !slices.Contains(repository.EventTypes, string(eventType)) {
+				return generated.PostWebhook400JSONResponse{Message: fmt.Sprintf("unknown event type %q", eventType)}, nil
+			}
+			input.EventTypes = append(input.EventTypes, string(eventType))
+		}
+	}
+	secret, err := webhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	input.Secret = secret
+
+	subscription, err := s.Repository.CreateWebhookSubscription(ctx, input)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "creating webhook", "error", err)
+		return generated.PostWebhook500JSONResponse{Message: "internal server error"}, nil
+	}
+	body := webhookBody(subscription)
+	body.Secret = &subscription.Secret
+	return generated.PostWebhook200JSONResponse(body), nil
+}
+
+// (DELETE /webhooks/{id})
+func (s *Server) DeleteWebhook(ctx context.Context, request generated.DeleteWebhookRequestObject) (generated.DeleteWebhookResponseObject, error) {
+	if !isAdmin(ctx) {
+		return generated.DeleteWebhook403JSONResponse{Message: errAdminOnly}, nil
+	}
+	err := s.Repository.DeleteWebhookSubscription(ctx, request.Id)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		return generated.DeleteWebhook404JSONResponse{Message: "webhook not found"}, nil
+	}
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "deleting webhook", "webhook_id", request.Id, "error", err)
+		return generated.DeleteWebhook500JSONResponse{Message: "internal server error"}, nil
+	}
+	return generated.DeleteWebhook204Response{}, nil
+}
+
+func validateWebhookURL(raw string) error {
+	if len(raw) > maxWebhookURLLength {
+		return fmt.Errorf("url is longer than %d characters", maxWebhookURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// webhookSecret returns a random key for signing the requests of a webhook.
+func webhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func webhookBody(subscription repository.WebhookSubscription) generated.WebhookSubscription {
+	eventTypes := make([]generated.EventType, 0, len(subscription.EventTypes))
+	for _, eventType := range subscription.EventTypes {
+		eventTypes = append(eventTypes, generated.EventType(eventType))
+	}
+	return generated.WebhookSubscription{
+		Id:         subscription.Id,
+		Url:        subscription.Url,
+		EventTypes: eventTypes,
+		EstateId:   subscription.EstateId,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
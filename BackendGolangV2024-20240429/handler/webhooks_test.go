@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostWebhook(t *testing.T) {
+	s, repo := newTestServer(t)
+	body := &generated.PostWebhookJSONRequestBody{
+		Url:        "https://erp.example.com/hooks",
+		EventTypes: &[]generated.EventType{generated.TreeCreated},
+	}
+
+	resp, err := s.PostWebhook(context.Background(), generated.PostWebhookRequestObject{Body: body})
+	require.NoError(t, err)
+	require.IsType(t, generated.PostWebhook403JSONResponse{}, resp)
+
+	repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.WebhookSubscriptionRequest) (repository.WebhookSubscription, error) {
+			require.Equal(t, []string{"tree.created"}, input.EventTypes)
+			require.Len(t, input.Secret, 64)
+			return repository.WebhookSubscription{
+				Id:         uuid.New(),
+				Url:        input.Url,
+				Secret:     input.Secret,
+				EventTypes: input.EventTypes,
+			}, nil
+		})
+	resp, err = s.PostWebhook(adminCtx, generated.PostWebhookRequestObject{Body: body})
+	require.NoError(t, err)
+	created := resp.(generated.PostWebhook200JSONResponse)
+	require.Len(t, *created.Secret, 64)
+	require.Equal(t, []generated.EventType{generated.TreeCreated}, created.EventTypes)
+}
+
+func TestPostWebhookInvalid(t *testing.T) {
+	s, _ := newTestServer(t)
+	for _, body := range []generated.PostWebhookJSONRequestBody{
+		{Url: "ftp://erp.example.com/hooks"},
+		{Url: "/hooks"},
+		{Url: "https://erp.example.com/hooks", EventTypes: &[]generated.EventType{"tree.planted"}},
+	} {
+		resp, err := s.PostWebhook(adminCtx, generated.PostWebhookRequestObject{Body: &body})
+		require.NoError(t, err)
+		require.IsType(t, generated.PostWebhook400JSONResponse{}, resp, body)
+	}
+}
+
+func TestDeleteWebhookNotFound(t *testing.T) {
+	s, repo := newTestServer(t)
+	id := uuid.NewString()
+	repo.EXPECT().DeleteWebhookSubscription(gomock.Any(), id).Return(repository.ErrWebhookNotFound)
+
+	resp, err := s.DeleteWebhook(adminCtx, generated.DeleteWebhookRequestObject{Id: id})
+	require.NoError(t, err)
+	require.IsType(t, generated.DeleteWebhook404JSONResponse{}, resp)
+}
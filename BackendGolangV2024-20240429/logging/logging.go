@@ -0,0 +1,114 @@
+// This file contains the structured logger shared by the handler and
+// repository layers.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the value of attributes that must never reach the logs.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys, compared case-insensitively, whose values
+// are always redacted.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"dsn":           true,
+	"database_url":  true,
+}
+
+type Options struct {
+	// Level is one of debug, info, warn or error. Defaults to info.
+	Level string
+	// Format is either json or text. Defaults to json.
+	Format string
+	Output io.Writer
+}
+
+// New builds a logger that redacts sensitive attributes and adds the request
+// id found in the context to every record.
+func New(opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+		h = slog.NewJSONHandler(opts.Output, handlerOpts)
+	case "text":
+		h = slog.NewTextHandler(opts.Output, handlerOpts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", opts.Format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// redact hides the values of sensitive keys and the password of any URL.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		if u, err := url.Parse(a.Value.String()); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), Redacted)
+				return slog.String(a.Key, u.Redacted())
+			}
+		}
+	}
+	return a
+}
+
+// contextHandler adds request scoped attributes stored in the context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
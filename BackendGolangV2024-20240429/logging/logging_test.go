@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	_, err := New(Options{Level: "loud", Output: &bytes.Buffer{}})
+	require.Error(t, err)
+	_, err = New(Options{Format: "xml", Output: &bytes.Buffer{}})
+	require.Error(t, err)
+}
+
+func TestLoggerRedactsAndAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Options{Level: "debug", Output: &buf})
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "connecting",
+		"password", "hunter2",
+		"url", "postgres://postgres:postgres@db:5432/database",
+	)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "req-1", record["request_id"])
+	require.Equal(t, Redacted, record["password"])
+	require.NotContains(t, record["url"], "postgres:postgres")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(RequestID())
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, RequestIDFromContext(c.Request().Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, "abc", rec.Header().Get(echo.HeaderXRequestID))
+	require.Equal(t, "abc", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+	require.Equal(t, rec.Header().Get(echo.HeaderXRequestID), rec.Body.String())
+}
@@ -0,0 +1,167 @@
+// This file contains the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "estate"
+
+// operationIDKey is the echo context key the strict middleware uses to hand
+// the OpenAPI operation id to the HTTP middleware.
+const operationIDKey = "metrics.operation_id"
+
+// Metrics holds every collector of the service. A nil *Metrics is valid and
+// records nothing, which keeps tests and tools free of metric plumbing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	repositoryDuration  *prometheus.HistogramVec
+	plannerDuration     *prometheus.HistogramVec
+	plannerPlots        *prometheus.HistogramVec
+	plannerEstatePlots  *prometheus.HistogramVec
+}
+
+// New creates the collectors on a dedicated registry together with the Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by operation id, method and status code.",
+		}, []string{"operation", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by operation id, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "method", "status"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_query_duration_seconds",
+			Help:      "Duration of repository calls by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		plannerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "planner_duration_seconds",
+			Help:      "Time spent computing drone plans.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"operation"}),
+		plannerPlots: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "planner_plots_traversed",
+			Help:      "Plots the drone planner walked for a single plan.",
+			Buckets:   prometheus.ExponentialBuckets(1, 10, 8),
+		}, []string{"operation"}),
+		plannerEstatePlots: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "planner_estate_plots",
+			Help:      "Size in plots of the estates drone plans are computed for.",
+			Buckets:   prometheus.ExponentialBuckets(1, 10, 8),
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.repositoryDuration,
+		m.plannerDuration,
+		m.plannerPlots,
+		m.plannerEstatePlots,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// HTTPMiddleware counts requests and measures their latency. Requests served by
+// the generated API are labelled with their operation id, anything else with
+// the route path.
+func (m *Metrics) HTTPMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if m == nil {
+				return next(c)
+			}
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Let echo write the error response so the status is final.
+				// Writing it twice is a no-op once the response is committed.
+				c.Error(err)
+			}
+
+			operation, _ := c.Get(operationIDKey).(string)
+			if operation == "" {
+				operation = c.Path()
+			}
+			if operation == "" {
+				operation = "unmatched"
+			}
+			labels := prometheus.Labels{
+				"operation": operation,
+				"method":    c.Request().Method,
+				"status":    strconv.Itoa(c.Response().Status),
+			}
+			m.httpRequests.With(labels).Inc()
+			m.httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// OperationMiddleware makes the operation id of the strict server available to
+// HTTPMiddleware.
+func (m *Metrics) OperationMiddleware() generated.StrictMiddlewareFunc {
+	return func(next generated.StrictHandlerFunc, operationID string) generated.StrictHandlerFunc {
+		return func(ctx echo.Context, request interface{}) (interface{}, error) {
+			ctx.Set(operationIDKey, operationID)
+			return next(ctx, request)
+		}
+	}
+}
+
+// ObserveRepository records the duration of a repository method call.
+func (m *Metrics) ObserveRepository(method string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.repositoryDuration.WithLabelValues(method).Observe(d.Seconds())
+}
+
+// ObservePlanner records a drone plan computation: how long it took, how many
+// plots were walked and how many plots the estate has.
+func (m *Metrics) ObservePlanner(operation string, d time.Duration, plotsTraversed, estatePlots int) {
+	if m == nil {
+		return
+	}
+	m.plannerDuration.WithLabelValues(operation).Observe(d.Seconds())
+	m.plannerPlots.WithLabelValues(operation).Observe(float64(plotsTraversed))
+	m.plannerEstatePlots.WithLabelValues(operation).Observe(float64(estatePlots))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMiddlewareLabelsOperation(t *testing.T) {
+	m := New()
+	e := echo.New()
+	e.Use(m.HTTPMiddleware())
+	e.GET("/estate/:id/stats", func(c echo.Context) error {
+		handler := m.OperationMiddleware()(func(ctx echo.Context, request interface{}) (interface{}, error) {
+			return nil, nil
+		}, "GetStats")
+		_, _ = handler(c, nil)
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/broken", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot)
+	})
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/estate/1/stats", nil))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GetStats", "GET", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("/broken", "GET", "418")))
+}
+
+func TestHandlerExposesRepositoryAndPlannerMetrics(t *testing.T) {
+	m := New()
+	m.ObserveRepository("GetEstateById", 10*time.Millisecond)
+	m.ObservePlanner("GetEstateIdDronePlan", time.Millisecond, 50, 50)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	require.True(t, strings.Contains(body, `estate_repository_query_duration_seconds_count{method="GetEstateById"} 1`))
+	require.True(t, strings.Contains(body, `estate_planner_plots_traversed_sum{operation="GetEstateIdDronePlan"} 50`))
+}
+
+func TestNilMetricsIsNoop(t *testing.T) {
+	var m *Metrics
+	m.ObserveRepository("GetEstateById", time.Second)
+	m.ObservePlanner("GetEstateIdDronePlan", time.Second, 1, 1)
+	m.RegisterDB(nil, "estate")
+}
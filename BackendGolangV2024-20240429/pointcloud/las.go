@@ -0,0 +1,91 @@
+package pointcloud
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// lasSignature opens every LAS file.
+const lasSignature = "LASF"
+
+// lasHeaderSize is the public header block of LAS 1.0 to 1.2; later versions
+// only append to it.
+const lasHeaderSize = 227
+
+// lasRecordSizes are the smallest point records of point data formats 0 to
+// 10.
+var lasRecordSizes = []int{20, 28, 26, 34, 57, 63, 30, 36, 38, 59, 67}
+
+// readLAS reads the points of an uncompressed LAS 1.0 to 1.4 file. Withheld
+// and noise points are skipped.
+func readLAS(r io.Reader, limit int, add func(Point)) error {
+	header := make([]byte, lasHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return errors.New("the LAS header is incomplete")
+	}
+	le := binary.LittleEndian
+	minor := header[25]
+	headerSize := int(le.Uint16(header[94:]))
+	pointOffset := int64(le.Uint32(header[96:]))
+	format := header[104]
+	recordSize := int(le.Uint16(header[105:]))
+	count := uint64(le.Uint32(header[107:]))
+	float := func(offset int) float64 {
+		return math.Float64frombits(le.Uint64(header[offset:]))
+	}
+	scaleX, scaleY, scaleZ := float(131), float(139), float(147)
+	offsetX, offsetY, offsetZ := float(155), float(163), float(171)
+
+	if format&0x80 != 0 {
+		return errors.New("compressed LAZ files are not supported")
+	}
+	if int(format) >= len(lasRecordSizes) {
+		return fmt.Errorf("unknown LAS point data format %d", format)
+	}
+	if recordSize < lasRecordSizes[format] {
+		return fmt.Errorf("LAS point records of format %d need at least %d bytes, not %d", format, lasRecordSizes[format], recordSize)
+	}
+	if headerSize < lasHeaderSize || pointOffset < int64(headerSize) {
+		return errors.New("the LAS header is malformed")
+	}
+
+	// LAS 1.3 and 1.4 append to the header; 1.4 counts points in 64 bits.
+	extended := make([]byte, headerSize-lasHeaderSize)
+	if _, err := io.ReadFull(r, extended); err != nil {
+		return errors.New("the LAS header is incomplete")
+	}
+	if minor >= 4 && count == 0 && headerSize >= 255 {
+		count = le.Uint64(extended[247-lasHeaderSize:])
+	}
+	// The variable length records before the points are not needed.
+	if _, err := io.CopyN(io.Discard, r, pointOffset-int64(headerSize)); err != nil {
+		return errors.New("the LAS file ends before its points")
+	}
+	if count > uint64(limit) {
+		return fmt.Errorf("%w: at most %d per upload", ErrTooManyPoints, limit)
+	}
+
+	record := make([]byte, recordSize)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return fmt.Errorf("the LAS file ends after %d of %d points", i, count)
+		}
+		class, withheld := int(record[15]&0x1f), record[15]&0x80 != 0
+		if format >= 6 {
+			class, withheld = int(record[16]), record[15]&0x04 != 0
+		}
+		if withheld || noise(class) {
+			continue
+		}
+		add(Point{
+			X:      float64(int32(le.Uint32(record[0:])))*scaleX + offsetX,
+			Y:      float64(int32(le.Uint32(record[4:])))*scaleY + offsetY,
+			Z:      float64(int32(le.Uint32(record[8:])))*scaleZ + offsetZ,
+			Ground: class == classGround,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package pointcloud reads LiDAR point clouds and measures the canopy over
+// the plots of an estate.
+package pointcloud
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrTooManyPoints is returned when a cloud holds more points than allowed.
+var ErrTooManyPoints = errors.New("too many points")
+
+// Point is a return in estate-local meters from the centre of plot (1,1).
+type Point struct {
+	X, Y, Z float64
+	// Ground is set for points classified as ground.
+	Ground bool
+}
+
+// Read reads at most limit points of a LAS file or an x,y,z CSV file and
+// passes them to add. LAS files are told apart by their signature.
+func Read(r io.Reader, limit int, add func(Point)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	signature, err := reader.Peek(len(lasSignature))
+	if err == nil && string(signature) == lasSignature {
+		return readLAS(reader, limit, add)
+	}
+	return readCSV(reader, limit, add)
+}
+
+// readCSV reads points from the x, y and z columns, and the LAS
+// classification column if there is one. The header row is optional; files
+// without one list x, y and z in that order.
+func readCSV(r io.Reader, limit int, add func(Point)) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	columns := map[string]int{"x": 0, "y": 1, "z": 2}
+	points := 0
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		if line == 1 {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64); err != nil {
+				columns, err = csvHeader(fields)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if points == limit {
+			return fmt.Errorf("%w: at most %d per upload", ErrTooManyPoints, limit)
+		}
+		var point Point
+		for _, name := range []string{"x", "y", "z"} {
+			i := columns[name]
+			if i >= len(fields) {
+				return fmt.Errorf("line %d: no %s", line, name)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				return fmt.Errorf("line %d: invalid %s %q", line, name, fields[i])
+			}
+			switch name {
+			case "x":
+				point.X = value
+			case "y":
+				point.Y = value
+			case "z":
+				point.Z = value
+			}
+		}
+		if i, ok := columns["classification"]; ok && i < len(fields) {
+			value := strings.TrimSpace(fields[i])
+			class, err := strconv.Atoi(value)
+			if value != "" && err != nil {
+				return fmt.Errorf("line %d: invalid classification %q", line, value)
+			}
+			if noise(class) {
+				continue
+			}
+			point.Ground = class == classGround
+		}
+		points++
+		add(point)
+	}
+}
+
+func csvHeader(fields []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range fields {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("line 1: the header has no %s column", name)
+		}
+	}
+	return columns, nil
+}
+
+// LAS classifications of ground and noise returns.
+const (
+	classGround    = 2
+	classLowNoise  = 7
+	classHighNoise = 18
+)
+
+func noise(class int) bool {
+	return class == classLowNoise || class == classHighNoise
+}
+
+// Canopy collects the points of a cloud by plot.
+type Canopy struct {
+	length, width  int
+	plotSizeMeters float64
+	plots          map[[2]int]*plotPoints
+	points         int
+	outside        int
+}
+
+type plotPoints struct {
+	lowest, lowestGround, highest float64
+	ground                        bool
+}
+
+// NewCanopy bins points into the plots of a length by width estate,
+// plotSizeMeters apart.
+func NewCanopy(length, width int, plotSizeMeters float64) *Canopy {
+	return &Canopy{
+		length:         length,
+		width:          width,
+		plotSizeMeters: plotSizeMeters,
+		plots:          map[[2]int]*plotPoints{},
+	}
+}
+
+// Add bins a point into the nearest plot. Points outside the estate are only
+// counted.
+func (c *Canopy) Add(point Point) {
+	c.points++
+	x := int(math.Round(point.X/c.plotSizeMeters)) + 1
+	y := int(math.Round(point.Y/c.plotSizeMeters)) + 1
+	if x < 1 || x > c.length || y < 1 || y > c.width {
+		c.outside++
+		return
+	}
+	plot, ok := c.plots[[2]int{x, y}]
+	if !ok {
+		plot = &plotPoints{lowest: point.Z, lowestGround: math.Inf(1), highest: point.Z}
+		c.plots[[2]int{x, y}] = plot
+	}
+	plot.lowest = math.Min(plot.lowest, point.Z)
+	plot.highest = math.Max(plot.highest, point.Z)
+	if point.Ground {
+		plot.ground = true
+		plot.lowestGround = math.Min(plot.lowestGround, point.Z)
+	}
+}
+
+// Points returns how many points were added and how many of them fell
+// outside the estate.
+func (c *Canopy) Points() (points, outside int) {
+	return c.points, c.outside
+}
+
+// Plots returns how many plots have points.
+func (c *Canopy) Plots() int {
+	return len(c.plots)
+}
+
+// Height returns the canopy height of a plot in whole meters: its highest
+// point above the ground, which is its lowest ground point or, in clouds
+// without classification, its lowest point. ok is false for plots without
+// points.
+func (c *Canopy) Height(x, y int) (height int, ok bool) {
+	plot, ok := c.plots[[2]int{x, y}]
+	if !ok {
+		return 0, false
+	}
+	ground := plot.lowest
+	if plot.ground {
+		ground = plot.lowestGround
+	}
+	return int(math.Round(plot.highest - ground)), true
+}
+
+// Each calls fn with the canopy height of every plot with points, in no
+// particular order.
+func (c *Canopy) Each(fn func(x, y, height int)) {
+	for plot := range c.plots {
+		height, _ := c.Height(plot[0], plot[1])
+		fn(plot[0], plot[1], height)
+	}
+}
@@ -0,0 +1,131 @@
+package pointcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lasFile writes points as a LAS 1.2 file of point data format 1 at
+// centimeter precision, with a variable length record before the points.
+func lasFile(points []Point, classes []byte) []byte {
+	const vlrSize = 54
+	le := binary.LittleEndian
+	header := make([]byte, lasHeaderSize)
+	copy(header, lasSignature)
+	header[24], header[25] = 1, 2
+	le.PutUint16(header[94:], lasHeaderSize)
+	le.PutUint32(header[96:], lasHeaderSize+vlrSize)
+	le.PutUint32(header[100:], 1)
+	header[104] = 1
+	le.PutUint16(header[105:], 28)
+	le.PutUint32(header[107:], uint32(len(points)))
+	for _, offset := range []int{131, 139, 147} {
+		le.PutUint64(header[offset:], math.Float64bits(0.01))
+	}
+	le.PutUint64(header[171:], math.Float64bits(100))
+
+	file := bytes.NewBuffer(header)
+	file.Write(make([]byte, vlrSize))
+	for i, point := range points {
+		record := make([]byte, 28)
+		le.PutUint32(record[0:], uint32(int32(math.Round(point.X*100))))
+		le.PutUint32(record[4:], uint32(int32(math.Round(point.Y*100))))
+		le.PutUint32(record[8:], uint32(int32(math.Round((point.Z-100)*100))))
+		record[15] = classes[i]
+		file.Write(record)
+	}
+	return file.Bytes()
+}
+
+func read(t *testing.T, file string, limit int) ([]Point, error) {
+	t.Helper()
+	var points []Point
+	err := Read(strings.NewReader(file), limit, func(point Point) {
+		points = append(points, point)
+	})
+	return points, err
+}
+
+func TestReadLAS(t *testing.T) {
+	file := lasFile([]Point{
+		{X: 0.5, Y: -1.25, Z: 101},
+		{X: 10, Y: 0, Z: 112.5},
+		{X: 10, Y: 0, Z: 150},
+		{X: 10, Y: 0, Z: 99},
+	}, []byte{2, 5, 7, 2 | 0x80})
+
+	points, err := read(t, string(file), 10)
+	require.NoError(t, err)
+	require.Equal(t, []Point{
+		{X: 0.5, Y: -1.25, Z: 101, Ground: true},
+		{X: 10, Y: 0, Z: 112.5},
+	}, points, "noise and withheld points are skipped")
+
+	_, err = read(t, string(file), 3)
+	require.True(t, errors.Is(err, ErrTooManyPoints))
+
+	_, err = read(t, string(file[:len(file)-10]), 10)
+	require.EqualError(t, err, "the LAS file ends after 3 of 4 points")
+
+	file[104] |= 0x80
+	_, err = read(t, string(file), 10)
+	require.EqualError(t, err, "compressed LAZ files are not supported")
+}
+
+func TestReadCSV(t *testing.T) {
+	points, err := read(t, "x,y,z\n0,0,100\n10.5,0,112\n", 10)
+	require.NoError(t, err)
+	require.Equal(t, []Point{{X: 0, Y: 0, Z: 100}, {X: 10.5, Y: 0, Z: 112}}, points)
+
+	points, err = read(t, "0,0,100\n1,2,3\n", 10)
+	require.NoError(t, err)
+	require.Len(t, points, 2, "the header is optional")
+
+	points, err = read(t, "Z,X,Y,Classification\n100,0,0,2\n112,10,0,1\n300,10,0,7\n", 10)
+	require.NoError(t, err)
+	require.Equal(t, []Point{{X: 0, Y: 0, Z: 100, Ground: true}, {X: 10, Y: 0, Z: 112}}, points)
+
+	_, err = read(t, "x,y,z\n0,0,100\n0,0,high\n", 10)
+	require.EqualError(t, err, `line 3: invalid z "high"`)
+
+	_, err = read(t, "x,y,elevation\n0,0,100\n", 10)
+	require.EqualError(t, err, "line 1: the header has no z column")
+
+	_, err = read(t, "0,0,100\n1,2,3\n", 1)
+	require.True(t, errors.Is(err, ErrTooManyPoints))
+}
+
+func TestCanopy(t *testing.T) {
+	canopy := NewCanopy(3, 2, 10)
+	for _, point := range []Point{
+		// Plot (1,1) has a classified ground point under a low return.
+		{X: 1, Y: 1, Z: 100, Ground: true},
+		{X: -2, Y: 3, Z: 99.5},
+		{X: 0, Y: 0, Z: 112.4},
+		// Plot (2,1) is not classified, so its lowest point is the ground.
+		{X: 11, Y: -4, Z: 101},
+		{X: 9, Y: 2, Z: 109.6},
+		{X: 40, Y: 0, Z: 120},
+	} {
+		canopy.Add(point)
+	}
+
+	points, outside := canopy.Points()
+	require.Equal(t, 6, points)
+	require.Equal(t, 1, outside)
+	require.Equal(t, 2, canopy.Plots())
+	height, ok := canopy.Height(1, 1)
+	require.True(t, ok)
+	require.Equal(t, 12, height)
+	height, ok = canopy.Height(2, 1)
+	require.True(t, ok)
+	require.Equal(t, 9, height)
+	_, ok = canopy.Height(3, 2)
+	require.False(t, ok)
+}
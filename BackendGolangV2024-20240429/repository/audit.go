@@ -0,0 +1,144 @@
+// This file contains the append-only audit log written by every mutation.
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	// AnonymousActor is recorded when a change carries no actor.
+	AnonymousActor = "anonymous"
+	// SystemActor is recorded for changes made by background jobs.
+	SystemActor = "system"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context whose changes are attributed to actor in the
+// audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor, or AnonymousActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// auditRecord describes a single change. Before and After are marshalled to
+// JSON; nil is stored as NULL, e.g. Before of a create.
+type auditRecord struct {
+	EstateId uuid.UUID
+	Entity   string
+	EntityId uuid.UUID
+	Action   string
+	Before   any
+	After    any
+}
+
+// audit appends a record to the audit log and publishes its event. Callers
+// run it in the transaction of the change it describes, see inTx.
+func (r *Repository) audit(ctx context.Context, record auditRecord) error {
+	before, err := auditJSON(record.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditJSON(record.After)
+	if err != nil {
+		return err
+	}
+	_, err = r.exec(ctx, "insert_audit_log", `
+		INSERT INTO audit_log (estate_id, entity, entity_id, action, actor, request_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, record.EstateId, record.Entity, record.EntityId, record.Action,
+		ActorFromContext(ctx), nullableRequestID(ctx), before, after)
+	if err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	// Every audited change is also published as an event.
+	return r.publish(ctx, record)
+}
+
+// nullableRequestID returns the request id of ctx, or nil outside requests.
+func nullableRequestID(ctx context.Context) *string {
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		return &id
+	}
+	return nil
+}
+
+// auditJSON marshals v for a JSONB column; nil becomes NULL.
+func auditJSON(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding audit record: %w", err)
+	}
+	s := string(body)
+	return &s, nil
+}
+
+// ListAuditEntries returns the audit entries of an estate and its trees,
+// newest first.
+func (r *Repository) ListAuditEntries(ctx context.Context, input AuditQuery) ([]AuditEntry, error) {
+	ctx, end := r.instrument(ctx, "ListAuditEntries")
+	defer end()
+	if !validIds(input.EstateId) {
+		return nil, ErrEstateNotFound
+	}
+
+	conditions := []string{"estate_id = $1"}
+	args := []any{input.EstateId}
+	if input.From != nil {
+		args = append(args, *input.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if input.To != nil {
+		args = append(args, *input.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if input.BeforeId > 0 {
+		args = append(args, input.BeforeId)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, input.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, estate_id, entity, entity_id, action, actor, COALESCE(request_id, ''), before, after, created_at
+		FROM audit_log
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := r.query(ctx, "select_audit_log", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after []byte
+		err := rows.Scan(&entry.Id, &entry.EstateId, &entry.Entity, &entry.EntityId, &entry.Action,
+			&entry.Actor, &entry.RequestId, &before, &after, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before = json.RawMessage(before)
+		entry.After = json.RawMessage(after)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
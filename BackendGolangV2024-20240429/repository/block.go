@@ -0,0 +1,397 @@
+// This file contains the divisions and blocks an estate is organised in.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const blockColumns = "id, estate_id, division_id, name, x_min, y_min, x_max, y_max, mask, version, created_at"
+
+func scanBlock(scan func(dest ...any) error) (Block, error) {
+	var block Block
+	var divisionId uuid.NullUUID
+	var mask pq.StringArray
+	err := scan(&block.Id, &block.EstateId, &divisionId, &block.Name, &block.XMin, &block.YMin,
+		&block.XMax, &block.YMax, &mask, &block.Version, &block.CreatedAt)
+	if divisionId.Valid {
+		block.DivisionId = &divisionId.UUID
+	}
+	if mask != nil {
+		block.Mask = mask
+	}
+	return block, err
+}
+
+// maskArg stores nil masks as NULL.
+func maskArg(mask []string) any {
+	if mask == nil {
+		return nil
+	}
+	return pq.StringArray(mask)
+}
+
+// InsertDivision adds a division to a live estate. Names are unique within
+// the estate; a taken one yields ErrDivisionExists.
+func (r *Repository) InsertDivision(ctx context.Context, estateId, name string) (Division, error) {
+	ctx, end := r.instrument(ctx, "InsertDivision")
+	defer end()
+	if !validIds(estateId) {
+		return Division{}, ErrEstateNotFound
+	}
+	var division Division
+	err := r.writeRow(ctx, "insert_division", `
+		INSERT INTO division (estate_id, name)
+		SELECT id, $2 FROM estate WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, estate_id, name, created_at
+	`, estateId, name).Scan(&division.Id, &division.EstateId, &division.Name, &division.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Division{}, ErrEstateNotFound
+	}
+	if isUniqueViolation(err) {
+		return Division{}, ErrDivisionExists
+	}
+	if err != nil {
+		return Division{}, err
+	}
+	return division, nil
+}
+
+// ListDivisions returns the divisions of a live estate by name.
+func (r *Repository) ListDivisions(ctx context.Context, estateId string) ([]Division, error) {
+	ctx, end := r.instrument(ctx, "ListDivisions")
+	defer end()
+	if !validIds(estateId) {
+		return nil, ErrEstateNotFound
+	}
+	if err := r.estateExists(ctx, estateId); err != nil {
+		return nil, err
+	}
+	rows, err := r.query(ctx, "select_divisions", `
+		SELECT id, estate_id, name, created_at
+		FROM division
+		WHERE estate_id = $1
+		ORDER BY name
+	`, estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	divisions := []Division{}
+	for rows.Next() {
+		var division Division
+		if err := rows.Scan(&division.Id, &division.EstateId, &division.Name, &division.CreatedAt); err != nil {
+			return nil, err
+		}
+		divisions = append(divisions, division)
+	}
+	return divisions, rows.Err()
+}
+
+// DeleteDivision removes a division without blocks.
+func (r *Repository) DeleteDivision(ctx context.Context, estateId, divisionId string) error {
+	ctx, end := r.instrument(ctx, "DeleteDivision")
+	defer end()
+	if !validIds(estateId, divisionId) {
+		return ErrDivisionNotFound
+	}
+	result, err := r.exec(ctx, "delete_division", `
+		DELETE FROM division WHERE id = $1 AND estate_id = $2
+	`, divisionId, estateId)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrDivisionNotEmpty
+	}
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrDivisionNotFound
+	}
+	return nil
+}
+
+// ValidateBlockRequest checks that a block lies within its estate, has a
+// well-formed mask, a division of the estate if any, and neither the name nor
+// a plot of another block. Invalid blocks are reported as a ValidationError.
+// Callers lock the estate first so concurrent writes cannot slip in between
+// the check and the write.
+func (r *Repository) ValidateBlockRequest(ctx context.Context, input BlockRequest) error {
+	ctx, end := r.instrument(ctx, "ValidateBlockRequest")
+	defer end()
+	if input.Name == "" || len(input.Name) > 100 {
+		return invalidInput(errors.New("block names must have between 1 and 100 characters"))
+	}
+	if input.XMin < 1 || input.YMin < 1 {
+		return invalidInput(errors.New("blocks start at plot (1,1) or beyond"))
+	}
+	if input.XMin > input.XMax || input.YMin > input.YMax {
+		return invalidInput(errors.New("x_min and y_min must not exceed x_max and y_max"))
+	}
+	if err := validateBlockMask(input); err != nil {
+		return invalidInput(err)
+	}
+
+	estate, err := r.GetEstateById(ctx, input.EstateId)
+	if err != nil {
+		return err
+	}
+	if input.XMax > estate.Length || input.YMax > estate.Width {
+		return invalidInput(fmt.Errorf("the block extends beyond the estate (%dx%d)", estate.Length, estate.Width))
+	}
+	if input.DivisionId != nil {
+		var exists bool
+		if validIds(*input.DivisionId) {
+			err := r.queryRow(ctx, "select_division_exists", `
+				SELECT EXISTS (SELECT 1 FROM division WHERE id = $1 AND estate_id = $2)
+			`, *input.DivisionId, input.EstateId).Scan(&exists)
+			if err != nil {
+				return err
+			}
+		}
+		if !exists {
+			return invalidInput(ErrDivisionNotFound)
+		}
+	}
+
+	blocks, err := r.ListBlocks(ctx, input.EstateId, nil)
+	if err != nil {
+		return err
+	}
+	block := Block{XMin: input.XMin, YMin: input.YMin, XMax: input.XMax, YMax: input.YMax, Mask: input.Mask}
+	for _, other := range blocks {
+		if other.Id.String() == input.Id {
+			continue
+		}
+		if other.Name == input.Name {
+			return invalidInput(fmt.Errorf("the estate already has a block named %q", input.Name))
+		}
+		if block.Overlaps(other) {
+			return invalidInput(fmt.Errorf("the block overlaps block %q", other.Name))
+		}
+	}
+	return nil
+}
+
+// validateBlockMask checks that a mask has a row per y of the block, a
+// character per x and at least one plot in the block.
+func validateBlockMask(input BlockRequest) error {
+	if input.Mask == nil {
+		return nil
+	}
+	length, width := input.XMax-input.XMin+1, input.YMax-input.YMin+1
+	if length*width > maxBlockMaskPlots {
+		return fmt.Errorf("masked blocks cover at most %d plots", maxBlockMaskPlots)
+	}
+	if len(input.Mask) != width {
+		return fmt.Errorf("the mask has %d rows, not %d", len(input.Mask), width)
+	}
+	in := 0
+	for i, row := range input.Mask {
+		if len(row) != length {
+			return fmt.Errorf("mask row %d has %d plots, not %d", i, len(row), length)
+		}
+		if strings.Trim(row, string(BlockMaskIn)+string(BlockMaskOut)) != "" {
+			return fmt.Errorf("mask row %d may only hold %q and %q", i, BlockMaskIn, BlockMaskOut)
+		}
+		in += strings.Count(row, string(BlockMaskIn))
+	}
+	if in == 0 {
+		return errors.New("the mask holds no plot")
+	}
+	return nil
+}
+
+// InsertBlock adds a block validated by ValidateBlockRequest.
+func (r *Repository) InsertBlock(ctx context.Context, input BlockRequest) (Block, error) {
+	ctx, end := r.instrument(ctx, "InsertBlock")
+	defer end()
+	if !validIds(input.EstateId) {
+		return Block{}, ErrEstateNotFound
+	}
+	block, err := scanBlock(r.writeRow(ctx, "insert_block", `
+		INSERT INTO block (estate_id, division_id, name, x_min, y_min, x_max, y_max, mask)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8 FROM estate WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+blockColumns,
+		input.EstateId, input.DivisionId, input.Name, input.XMin, input.YMin, input.XMax, input.YMax,
+		maskArg(input.Mask)).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Block{}, ErrEstateNotFound
+	}
+	if err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// GetBlock returns a block of a live estate.
+func (r *Repository) GetBlock(ctx context.Context, estateId, blockId string) (Block, error) {
+	ctx, end := r.instrument(ctx, "GetBlock")
+	defer end()
+	if !validIds(estateId, blockId) {
+		return Block{}, ErrBlockNotFound
+	}
+	block, err := scanBlock(r.queryRow(ctx, "select_block", `
+		SELECT `+blockColumns+`
+		FROM block
+		WHERE id = $1 AND estate_id = $2
+		  AND EXISTS (SELECT 1 FROM estate WHERE id = $2 AND deleted_at IS NULL)
+	`, blockId, estateId).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Block{}, ErrBlockNotFound
+	}
+	if err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// ListBlocks returns the blocks of a live estate, or of one of its divisions,
+// by name.
+func (r *Repository) ListBlocks(ctx context.Context, estateId string, divisionId *string) ([]Block, error) {
+	ctx, end := r.instrument(ctx, "ListBlocks")
+	defer end()
+	if !validIds(estateId) {
+		return nil, ErrEstateNotFound
+	}
+	if divisionId != nil && !validIds(*divisionId) {
+		return []Block{}, nil
+	}
+	if err := r.estateExists(ctx, estateId); err != nil {
+		return nil, err
+	}
+	rows, err := r.query(ctx, "select_blocks", `
+		SELECT `+blockColumns+`
+		FROM block
+		WHERE estate_id = $1 AND ($2::uuid IS NULL OR division_id = $2)
+		ORDER BY name
+	`, estateId, divisionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []Block{}
+	for rows.Next() {
+		block, err := scanBlock(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, rows.Err()
+}
+
+// UpdateBlock redefines a block that is still at the given version, or at any
+// version for AnyVersion, and increments the version.
+func (r *Repository) UpdateBlock(ctx context.Context, input BlockRequest, version int) (Block, error) {
+	ctx, end := r.instrument(ctx, "UpdateBlock")
+	defer end()
+	var block Block
+	err := r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.lockBlock(ctx, input.EstateId, input.Id)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && before.Version != version {
+			return ErrVersionMismatch
+		}
+		block, err = scanBlock(tx.writeRow(ctx, "update_block", `
+			UPDATE block
+			SET division_id = $2, name = $3, x_min = $4, y_min = $5, x_max = $6, y_max = $7, mask = $8,
+				version = version + 1
+			WHERE id = $1
+			RETURNING `+blockColumns,
+			input.Id, input.DivisionId, input.Name, input.XMin, input.YMin, input.XMax, input.YMax,
+			maskArg(input.Mask)).Scan)
+		return err
+	})
+	if err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// DeleteBlock removes a block that is still at the given version. Its trees
+// stay on the estate.
+func (r *Repository) DeleteBlock(ctx context.Context, estateId, blockId string, version int) error {
+	ctx, end := r.instrument(ctx, "DeleteBlock")
+	defer end()
+	return r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.lockBlock(ctx, estateId, blockId)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && before.Version != version {
+			return ErrVersionMismatch
+		}
+		_, err = tx.exec(ctx, "delete_block", "DELETE FROM block WHERE id = $1", blockId)
+		return err
+	})
+}
+
+func (r *Repository) lockBlock(ctx context.Context, estateId, blockId string) (Block, error) {
+	if !validIds(estateId, blockId) {
+		return Block{}, ErrBlockNotFound
+	}
+	block, err := scanBlock(r.queryRow(ctx, "select_block_for_update", `
+		SELECT `+blockColumns+`
+		FROM block
+		WHERE id = $1 AND estate_id = $2
+		  AND EXISTS (SELECT 1 FROM estate WHERE id = $2 AND deleted_at IS NULL)
+		FOR UPDATE
+	`, blockId, estateId).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Block{}, ErrBlockNotFound
+	}
+	if err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// CountBlocksOutside counts the blocks of an estate that extend beyond length
+// and width.
+func (r *Repository) CountBlocksOutside(ctx context.Context, estateId string, length, width int) (int, error) {
+	ctx, end := r.instrument(ctx, "CountBlocksOutside")
+	defer end()
+	var count int
+	err := r.queryRow(ctx, "select_blocks_outside", `
+		SELECT COUNT(*)
+		FROM block
+		WHERE estate_id = $1 AND (x_max > $2 OR y_max > $3)
+	`, estateId, length, width).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// inBlock selects the trees standing on the plots of a block passed as
+// $2 to $6: x_min, y_min, x_max, y_max and the mask.
+const inBlock = `x BETWEEN $2 AND $4 AND y BETWEEN $3 AND $5
+	AND ($6::text[] IS NULL OR substr(($6::text[])[y - $3 + 1], x - $2 + 1, 1) = '#')`
+
+// GetBlockStats returns the statistics of the trees of a block, computed like
+// GetEstateStats.
+func (r *Repository) GetBlockStats(ctx context.Context, estateId, blockId string) (EstateStats, error) {
+	ctx, end := r.instrument(ctx, "GetBlockStats")
+	defer end()
+	block, err := r.GetBlock(ctx, estateId, blockId)
+	if err != nil {
+		return EstateStats{}, err
+	}
+	return r.treeStats(ctx, "estateId = $1 AND "+notDeleted(ctx)+" AND "+inBlock,
+		estateId, block.XMin, block.YMin, block.XMax, block.YMax, maskArg(block.Mask))
+}
@@ -0,0 +1,235 @@
+// This file contains the harvests of trees and the yield of estates.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const harvestColumns = "id, estate_id, tree_id, harvested_on, bunches, weight_kg, harvester, created_at"
+
+func scanHarvest(scan func(dest ...any) error) (Harvest, error) {
+	var harvest Harvest
+	var harvester sql.NullString
+	err := scan(&harvest.Id, &harvest.EstateId, &harvest.TreeId, &harvest.HarvestedOn, &harvest.Bunches,
+		&harvest.WeightKg, &harvester, &harvest.CreatedAt)
+	harvest.Harvester = harvester.String
+	return harvest, err
+}
+
+// InsertHarvest records a harvest of a standing tree.
+func (r *Repository) InsertHarvest(ctx context.Context, input HarvestRequest) (Harvest, error) {
+	ctx, end := r.instrument(ctx, "InsertHarvest")
+	defer end()
+	if !validIds(input.EstateId, input.TreeId) {
+		return Harvest{}, ErrTreeNotFound
+	}
+	harvest, err := scanHarvest(r.writeRow(ctx, "insert_harvest", `
+		INSERT INTO harvest (estate_id, tree_id, harvested_on, bunches, weight_kg, harvester)
+		SELECT estateId::uuid, id, $3, $4, $5, $6
+		FROM tree
+		WHERE id = $1 AND estateId = $2 AND deleted_at IS NULL
+		RETURNING `+harvestColumns,
+		input.TreeId, input.EstateId, input.HarvestedOn, input.Bunches, input.WeightKg,
+		nullString(input.Harvester)).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Harvest{}, ErrTreeNotFound
+	}
+	if err != nil {
+		return Harvest{}, err
+	}
+	return harvest, nil
+}
+
+// ListHarvests returns the harvests of a tree, latest first.
+func (r *Repository) ListHarvests(ctx context.Context, estateId, treeId string) ([]Harvest, error) {
+	ctx, end := r.instrument(ctx, "ListHarvests")
+	defer end()
+	if !validIds(estateId, treeId) {
+		return nil, ErrTreeNotFound
+	}
+	var treeExists bool
+	err := r.queryRow(ctx, "select_tree_exists", `
+		SELECT EXISTS (SELECT 1 FROM tree WHERE id = $1 AND estateId = $2 AND `+notDeleted(ctx)+`)
+	`, treeId, estateId).Scan(&treeExists)
+	if err != nil {
+		return nil, err
+	}
+	if !treeExists {
+		return nil, ErrTreeNotFound
+	}
+
+	rows, err := r.query(ctx, "select_tree_harvests", `
+		SELECT `+harvestColumns+`
+		FROM harvest
+		WHERE tree_id = $1
+		ORDER BY harvested_on DESC, created_at DESC
+	`, treeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	harvests := []Harvest{}
+	for rows.Next() {
+		harvest, err := scanHarvest(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		harvests = append(harvests, harvest)
+	}
+	return harvests, rows.Err()
+}
+
+// GetEstateYield totals the harvests of the trees of an estate per group of
+// input.GroupBy, ordered by period, row and plot. Without columns to group by
+// it returns the estate total as a single group.
+func (r *Repository) GetEstateYield(ctx context.Context, input YieldQuery) ([]YieldGroup, error) {
+	ctx, end := r.instrument(ctx, "GetEstateYield")
+	defer end()
+	if !validIds(input.EstateId) {
+		return nil, ErrEstateNotFound
+	}
+	period := input.Period
+	if period == "" {
+		period = YieldMonth
+	}
+	switch period {
+	case YieldDay, YieldWeek, YieldMonth, YieldYear:
+	default:
+		return nil, fmt.Errorf("unknown yield period %q", period)
+	}
+
+	// Columns not grouped by stay NULL; trees are located by their row too.
+	columns := []string{"NULL::date", "NULL::int", "NULL::uuid", "NULL::int"}
+	for _, group := range input.GroupBy {
+		switch group {
+		case YieldGroupPeriod:
+			columns[0] = "date_trunc('" + period + "', h.harvested_on::timestamp)::date"
+		case YieldGroupRow:
+			columns[1] = "t.y"
+		case YieldGroupTree:
+			columns[1], columns[2], columns[3] = "t.y", "t.id", "t.x"
+		default:
+			return nil, fmt.Errorf("cannot group yield by %q", group)
+		}
+	}
+
+	if err := r.estateExists(ctx, input.EstateId); err != nil {
+		return nil, err
+	}
+	conditions := []string{"h.estate_id = $1", notDeleted(ctx)}
+	args := []any{input.EstateId}
+	if input.From != nil {
+		args = append(args, *input.From)
+		conditions = append(conditions, fmt.Sprintf("h.harvested_on >= $%d", len(args)))
+	}
+	if input.To != nil {
+		args = append(args, *input.To)
+		conditions = append(conditions, fmt.Sprintf("h.harvested_on <= $%d", len(args)))
+	}
+	grouping := ""
+	if len(input.GroupBy) > 0 {
+		grouping = "GROUP BY 1, 2, 3, 4 ORDER BY 1, 2, 4"
+	}
+	query := fmt.Sprintf(`
+		SELECT %s, COUNT(*), COALESCE(SUM(h.bunches), 0), COALESCE(SUM(h.weight_kg), 0)
+		FROM harvest h JOIN tree t ON t.id = h.tree_id
+		WHERE %s
+		%s
+	`, strings.Join(columns, ", "), strings.Join(conditions, " AND "), grouping)
+
+	rows, err := r.query(ctx, "select_estate_yield", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []YieldGroup{}
+	for rows.Next() {
+		var group YieldGroup
+		var row, x sql.NullInt64
+		var treeId uuid.NullUUID
+		var periodStart sql.NullTime
+		if err := rows.Scan(&periodStart, &row, &treeId, &x, &group.Harvests, &group.Bunches, &group.WeightKg); err != nil {
+			return nil, err
+		}
+		if periodStart.Valid {
+			group.PeriodStart = &periodStart.Time
+		}
+		if row.Valid {
+			y := int(row.Int64)
+			group.Row = &y
+		}
+		if treeId.Valid {
+			group.TreeId = &treeId.UUID
+		}
+		if x.Valid {
+			plot := int(x.Int64)
+			group.X = &plot
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// yieldPerTree totals the weight harvested from every harvested tree of an
+// estate, next to its height.
+const yieldPerTree = `
+	WITH per_tree AS (
+		SELECT t.height, SUM(h.weight_kg) AS weight
+		FROM harvest h JOIN tree t ON t.id = h.tree_id
+		WHERE h.estate_id = $1 AND %s
+		GROUP BY t.id, t.height
+	)`
+
+// GetEstateYieldStats relates the weight harvested from the trees of an
+// estate to their current height.
+func (r *Repository) GetEstateYieldStats(ctx context.Context, estateId string) (YieldStats, error) {
+	ctx, end := r.instrument(ctx, "GetEstateYieldStats")
+	defer end()
+	if !validIds(estateId) {
+		return YieldStats{}, ErrEstateNotFound
+	}
+	if err := r.estateExists(ctx, estateId); err != nil {
+		return YieldStats{}, err
+	}
+
+	withTrees := fmt.Sprintf(yieldPerTree, notDeleted(ctx))
+	var stats YieldStats
+	var correlation sql.NullFloat64
+	err := r.queryRow(ctx, "select_yield_height_correlation", withTrees+`
+		SELECT COUNT(*), COALESCE(SUM(weight), 0), CORR(weight, height)
+		FROM per_tree`, estateId).Scan(&stats.HarvestedTrees, &stats.WeightKg, &correlation)
+	if err != nil {
+		return YieldStats{}, err
+	}
+	if correlation.Valid {
+		stats.Correlation = &correlation.Float64
+	}
+
+	rows, err := r.query(ctx, "select_yield_by_height", withTrees+`
+		SELECT height, COUNT(*), AVG(weight)
+		FROM per_tree
+		GROUP BY height
+		ORDER BY height`, estateId)
+	if err != nil {
+		return YieldStats{}, err
+	}
+	defer rows.Close()
+
+	stats.ByHeight = []HeightYield{}
+	for rows.Next() {
+		var height HeightYield
+		if err := rows.Scan(&height.Height, &height.Trees, &height.WeightKg); err != nil {
+			return YieldStats{}, err
+		}
+		stats.ByHeight = append(stats.ByHeight, height)
+	}
+	return stats, rows.Err()
+}
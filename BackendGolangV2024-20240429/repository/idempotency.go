@@ -0,0 +1,112 @@
+// This file contains the storage of idempotency keys and their responses.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ClaimIdempotencyKey claims key for the caller unless an unexpired request
+// already holds it, in which case that request is returned. Expired keys and
+// keys abandoned for longer than the lock timeout are claimed again.
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, input IdempotencyKeyRequest) (IdempotencyKey, error) {
+	ctx, end := r.instrument(ctx, "ClaimIdempotencyKey")
+	defer end()
+
+	var key string
+	err := r.writeRow(ctx, "insert_idempotency_key", `
+		INSERT INTO idempotency_key (key, request_hash, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 second')
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			content_type = NULL,
+			response_headers = NULL,
+			response_body = NULL,
+			created_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at < now()
+			OR (idempotency_key.status_code IS NULL
+				AND idempotency_key.created_at < now() - $4 * interval '1 second')
+		RETURNING key
+	`, input.Key, input.RequestHash, input.TTL.Seconds(), input.LockTimeout.Seconds()).Scan(&key)
+	if err == nil {
+		return IdempotencyKey{Claimed: true, RequestHash: input.RequestHash}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return IdempotencyKey{}, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+
+	var (
+		existing    IdempotencyKey
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+		headers     []byte
+	)
+	err = r.queryRow(ctx, "select_idempotency_key", `
+		SELECT request_hash, status_code, content_type, response_headers, response_body
+		FROM idempotency_key
+		WHERE key = $1
+	`, input.Key).Scan(&existing.RequestHash, &statusCode, &contentType, &headers, &existing.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the two statements; report it as still running so
+		// the client retries.
+		return IdempotencyKey{RequestHash: input.RequestHash}, nil
+	}
+	if err != nil {
+		return IdempotencyKey{}, fmt.Errorf("reading idempotency key: %w", err)
+	}
+	existing.StatusCode = int(statusCode.Int64)
+	existing.ContentType = contentType.String
+	if headers != nil {
+		if err := json.Unmarshal(headers, &existing.Headers); err != nil {
+			return IdempotencyKey{}, fmt.Errorf("reading idempotency key: %w", err)
+		}
+	}
+	return existing, nil
+}
+
+// SaveIdempotentResponse stores the response of the request holding the key,
+// so retries replay it.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, input IdempotentResponse) error {
+	ctx, end := r.instrument(ctx, "SaveIdempotentResponse")
+	defer end()
+	headers, err := json.Marshal(input.Headers)
+	if err != nil {
+		return fmt.Errorf("encoding idempotent response headers: %w", err)
+	}
+	_, err = r.exec(ctx, "update_idempotency_key", `
+		UPDATE idempotency_key
+		SET status_code = $2, content_type = $3, response_headers = $4, response_body = $5
+		WHERE key = $1 AND status_code IS NULL
+	`, input.Key, input.StatusCode, input.ContentType, string(headers), input.Body)
+	if err != nil {
+		return fmt.Errorf("saving idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed, so a retry runs
+// the request again.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, end := r.instrument(ctx, "ReleaseIdempotencyKey")
+	defer end()
+	_, err := r.exec(ctx, "delete_idempotency_key", "DELETE FROM idempotency_key WHERE key = $1 AND status_code IS NULL", key)
+	if err != nil {
+		return fmt.Errorf("releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, end := r.instrument(ctx, "DeleteExpiredIdempotencyKeys")
+	defer end()
+	result, err := r.exec(ctx, "delete_expired_idempotency_keys", "DELETE FROM idempotency_key WHERE expires_at < now()")
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
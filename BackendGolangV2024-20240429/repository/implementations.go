@@ -2,14 +2,32 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
+	"github.com/SawitProRecruitment/UserService/geo"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
 func (r *Repository) GetTestById(ctx context.Context, input GetTestByIdInput) (output GetTestByIdOutput, err error) {
-	err = r.Db.QueryRowContext(ctx, "SELECT name FROM test WHERE id = $1", input.Id).Scan(&output.Name)
+	ctx, end := r.instrument(ctx, "GetTestById")
+	defer end()
+	err = r.queryRow(ctx, "select_test_by_id", "SELECT name FROM test WHERE id = $1", input.Id).Scan(&output.Name)
 	if err != nil {
 		return
 	}
@@ -17,59 +35,184 @@ func (r *Repository) GetTestById(ctx context.Context, input GetTestByIdInput) (o
 }
 
 func (r *Repository) InsertEstate(ctx context.Context, input EstateRequest) (EstateResponse, error) {
-	var id uuid.UUID
-	query := "INSERT INTO estate (length, width) VALUES ($1, $2) RETURNING id"
-	err := r.Db.QueryRowContext(ctx, query, input.Length, input.Width).Scan(&id)
+	ctx, end := r.instrument(ctx, "InsertEstate")
+	defer end()
+	var estate EstateData
+	query := `
+		INSERT INTO estate (length, width, latitude, longitude, bearing, plot_size_meters)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + estateColumns
+	err := r.inTx(ctx, func(tx *Repository) (err error) {
+		latitude, longitude, bearing, plotSize := geoArgs(input.GeoReference)
+		estate, err = scanEstate(tx.writeRow(ctx, "insert_estate", query,
+			input.Length, input.Width, latitude, longitude, bearing, plotSize).Scan)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: estate.Id,
+			Entity:   AuditEntityEstate,
+			EntityId: estate.Id,
+			Action:   AuditActionCreate,
+			After:    estate,
+		})
+	})
 	if err != nil {
-		log.Printf("Error inserting estate: %v\n", err)
+		r.Logger.ErrorContext(ctx, "inserting estate", "error", err)
 		return EstateResponse{}, err // Return an empty EstateResponse and the error
 	}
 
 	response := EstateResponse{
-		Id: id,
+		Id: estate.Id,
 	}
 
 	return response, nil
 }
 
 func (r *Repository) InsertTree(ctx context.Context, input TreeRequest) (TreeResponse, error) {
-	var id uuid.UUID
-	query := "INSERT INTO tree (estateid,x,y,height) VALUES ($1, $2, $3, $4) RETURNING id"
-	err := r.Db.QueryRowContext(ctx, query, input.EstateId, input.X, input.Y, input.Height).Scan(&id)
+	ctx, end := r.instrument(ctx, "InsertTree")
+	defer end()
+	estateId, err := uuid.Parse(input.EstateId)
+	if err != nil {
+		return TreeResponse{}, ErrEstateNotFound
+	}
+	health := input.Health
+	if health == "" {
+		health = HealthHealthy
+	}
+	attributes, err := attributesJSON(input.Attributes)
 	if err != nil {
-		log.Printf("Error inserting Tree: %v\n", err)
+		return TreeResponse{}, err
+	}
+	var tree TreeData
+	query := `
+		INSERT INTO tree (estateid,x,y,height,species,variety,planted_on,health,attributes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + treeColumns
+	err = r.inTx(ctx, func(tx *Repository) error {
+		var err error
+		tree, err = scanTree(tx.writeRow(ctx, "insert_tree", query, input.EstateId, input.X, input.Y, input.Height,
+			nullString(input.Species), nullString(input.Variety), input.PlantedOn, health, attributes).Scan)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: estateId,
+			Entity:   AuditEntityTree,
+			EntityId: tree.Id,
+			Action:   AuditActionCreate,
+			After:    tree,
+		})
+	})
+	if isUniqueViolation(err) {
+		// Another transaction planted this plot after we validated it.
+		return TreeResponse{}, fmt.Errorf("x=%d y=%d: %w", input.X, input.Y, ErrPlotOccupied)
+	}
+	if err != nil {
+		r.Logger.ErrorContext(ctx, "inserting tree", "estate_id", input.EstateId, "error", err)
 		return TreeResponse{}, err // Return an empty EstateResponse and the error
 	}
 
 	response := TreeResponse{
-		Id: id,
+		Id: tree.Id,
 	}
 
 	return response, nil
 }
 
+// ValidateTreeRequest checks a new tree against its estate and plot. Invalid
+// trees are reported as a ValidationError, a missing estate as
+// ErrEstateNotFound.
 func (r *Repository) ValidateTreeRequest(ctx context.Context, estateId string, input TreeRequest) error {
+	ctx, end := r.instrument(ctx, "ValidateTreeRequest")
+	defer end()
+	if !validIds(estateId) {
+		return ErrEstateNotFound
+	}
 	var length, width int
-	query := "SELECT length, width FROM estate WHERE id = $1"
-	err := r.Db.QueryRowContext(ctx, query, estateId).Scan(&length, &width)
+	// Inside WithTx the share lock keeps the estate from being resized until
+	// the tree is inserted; concurrent tree inserts do not block each other.
+	query := "SELECT length, width FROM estate WHERE id = $1 AND deleted_at IS NULL FOR SHARE"
+	err := r.queryRow(ctx, "select_estate_bounds", query, estateId).Scan(&length, &width)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEstateNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("estate not found or database error: %v", err)
+		return fmt.Errorf("checking estate: %w", err)
 	}
 	if input.X > length || input.X <= 0 {
-		return fmt.Errorf("x (%d) exceeds estate length (%d)", input.X, length)
+		return invalidInput(fmt.Errorf("x (%d) exceeds estate length (%d)", input.X, length))
 	}
 	if input.Y > width || input.Y <= 0 {
-		return fmt.Errorf("y (%d) exceeds estate width (%d)", input.Y, width)
+		return invalidInput(fmt.Errorf("y (%d) exceeds estate width (%d)", input.Y, width))
 	}
 
-	if input.Height > 30 {
-		return fmt.Errorf("height (%d) exceeds the maximum allowed value (30)", input.Height)
+	maxHeight, err := r.maxHeight(ctx, input.Species)
+	if errors.Is(err, ErrSpeciesNotFound) {
+		return invalidInput(err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := validateHeight(input.Height, input.Species, maxHeight); err != nil {
+		return invalidInput(err)
+	}
+	if err := validateTreeDetails(input.Variety, input.Health, input.PlantedOn, input.Attributes); err != nil {
+		return invalidInput(err)
+	}
+
+	var occupied bool
+	query = "SELECT EXISTS (SELECT 1 FROM tree WHERE estateId = $1 AND x = $2 AND y = $3 AND deleted_at IS NULL)"
+	err = r.queryRow(ctx, "select_plot_occupied", query, estateId, input.X, input.Y).Scan(&occupied)
+	if err != nil {
+		return fmt.Errorf("checking plot: %w", err)
+	}
+	if occupied {
+		return invalidInput(fmt.Errorf("x=%d y=%d: %w", input.X, input.Y, ErrPlotOccupied))
 	}
 
 	return nil
 }
 
+// validateHeight checks the height of a tree against the maximum of its
+// species, or DefaultMaxHeight for trees without one.
+func validateHeight(height int, species string, maxHeight int) error {
+	if height <= maxHeight {
+		return nil
+	}
+	if species != "" {
+		return fmt.Errorf("height (%d) exceeds the maximum allowed value for %s (%d)", height, species, maxHeight)
+	}
+	return fmt.Errorf("height (%d) exceeds the maximum allowed value (%d)", height, maxHeight)
+}
+
+// validateTreeDetails checks the fields of a tree besides its plot and
+// height. Empty or nil fields are not checked.
+func validateTreeDetails(variety, health string, plantedOn *time.Time, attributes map[string]any) error {
+	if len(variety) > maxVarietyLength {
+		return fmt.Errorf("variety exceeds %d characters", maxVarietyLength)
+	}
+	switch health {
+	case "", HealthHealthy, HealthDiseased, HealthDead:
+	default:
+		return fmt.Errorf("health must be %s, %s or %s", HealthHealthy, HealthDiseased, HealthDead)
+	}
+	if plantedOn != nil && plantedOn.After(time.Now()) {
+		return fmt.Errorf("planted_on (%s) is in the future", plantedOn.Format(time.DateOnly))
+	}
+	encoded, err := attributesJSON(attributes)
+	if err != nil {
+		return fmt.Errorf("attributes: %w", err)
+	}
+	if len(encoded) > maxAttributesBytes {
+		return fmt.Errorf("attributes exceed %d bytes", maxAttributesBytes)
+	}
+	return nil
+}
+
 func (r *Repository) ValidateEstateRequest(ctx context.Context, input EstateRequest) error {
+	ctx, end := r.instrument(ctx, "ValidateEstateRequest")
+	defer end()
 
 	if input.Length <= 0 {
 		return fmt.Errorf("length (%d) can not less than 0 ", input.Length)
@@ -78,26 +221,48 @@ func (r *Repository) ValidateEstateRequest(ctx context.Context, input EstateRequ
 	if input.Width <= 0 {
 		return fmt.Errorf("width (%d) can not less than 0", input.Width)
 	}
+
+	if input.GeoReference != nil {
+		if err := input.GeoReference.Validate(); err != nil {
+			return fmt.Errorf("geo reference: %w", err)
+		}
+	}
 	return nil
 }
 
 func (r *Repository) GetEstateStats(ctx context.Context, estateId string) (EstateStats, error) {
-	var count, max, min int
-	var median float64
+	ctx, end := r.instrument(ctx, "GetEstateStats")
+	defer end()
+
+	// Malformed ids can never match an estate
+	if _, err := uuid.Parse(estateId); err != nil {
+		return EstateStats{}, ErrEstateNotFound
+	}
 
 	// Check if estate exists
 	var estateExists bool
-	err := r.Db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM estate WHERE id = $1)", estateId).Scan(&estateExists)
-	if err != nil || !estateExists {
-		return EstateStats{}, fmt.Errorf("estate not found")
+	query := "SELECT EXISTS (SELECT 1 FROM estate WHERE id = $1 AND " + notDeleted(ctx) + ")"
+	err := r.queryRow(ctx, "select_estate_exists", query, estateId).Scan(&estateExists)
+	if err != nil {
+		return EstateStats{}, err
+	}
+	if !estateExists {
+		return EstateStats{}, ErrEstateNotFound
 	}
 
-	// Query for count, max, and min
-	err = r.Db.QueryRowContext(ctx, `
-		SELECT COUNT(*), MAX(height), MIN(height)
+	return r.treeStats(ctx, "estateId = $1 AND "+notDeleted(ctx), estateId)
+}
+
+// treeStats computes the statistics of the trees matching filter.
+func (r *Repository) treeStats(ctx context.Context, filter string, args ...any) (EstateStats, error) {
+	var count, max, min int
+	var median float64
+
+	// Query for count, max, and min, which are NULL without trees
+	err := r.queryRow(ctx, "select_tree_height_stats", `
+		SELECT COUNT(*), COALESCE(MAX(height), 0), COALESCE(MIN(height), 0)
 		FROM tree
-		WHERE estateId = $1
-	`, estateId).Scan(&count, &max, &min)
+		WHERE `+filter, args...).Scan(&count, &max, &min)
 	if err != nil {
 		return EstateStats{}, err
 	}
@@ -110,12 +275,14 @@ func (r *Repository) GetEstateStats(ctx context.Context, estateId string) (Estat
 		}, nil
 	}
 	// Query for median
-	err = r.Db.QueryRowContext(ctx, `
+	err = r.queryRow(ctx, "select_tree_height_median", `
 		SELECT PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY height)
 		FROM tree
-		WHERE estateId = $1
-	`, estateId).Scan(&median)
+		WHERE `+filter, args...).Scan(&median)
 	if err != nil {
+		if ctx.Err() != nil {
+			return EstateStats{}, ctx.Err()
+		}
 		median = 0.0
 	}
 
@@ -127,22 +294,325 @@ func (r *Repository) GetEstateStats(ctx context.Context, estateId string) (Estat
 	}, nil
 }
 
+// GetEstateStatsGroups returns the statistics of an estate per distinct
+// value of the groupBy columns, StatsGroupSpecies and StatsGroupHealth, in
+// the order of those values.
+func (r *Repository) GetEstateStatsGroups(ctx context.Context, estateId string, groupBy []string) ([]EstateStatsGroup, error) {
+	ctx, end := r.instrument(ctx, "GetEstateStatsGroups")
+	defer end()
+	if !validIds(estateId) {
+		return nil, ErrEstateNotFound
+	}
+	species, health := "''", "''"
+	var columns []string
+	for _, column := range groupBy {
+		switch column {
+		case StatsGroupSpecies:
+			species = "COALESCE(species, '')"
+			columns = append(columns, species)
+		case StatsGroupHealth:
+			health = "health"
+			columns = append(columns, health)
+		default:
+			return nil, fmt.Errorf("cannot group statistics by %q", column)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no columns to group statistics by")
+	}
+
+	if err := r.estateExists(ctx, estateId); err != nil {
+		return nil, err
+	}
+
+	grouped := strings.Join(columns, ", ")
+	rows, err := r.query(ctx, "select_tree_height_stats_by_"+strings.Join(groupBy, "_"), `
+		SELECT `+species+`, `+health+`, COUNT(*), MAX(height), MIN(height),
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY height)
+		FROM tree
+		WHERE estateId = $1 AND `+notDeleted(ctx)+`
+		GROUP BY `+grouped+`
+		ORDER BY `+grouped, estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []EstateStatsGroup
+	for rows.Next() {
+		var group EstateStatsGroup
+		err := rows.Scan(&group.Species, &group.Health, &group.Count, &group.MaxHeight, &group.MinHeight, &group.Median)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// estateExists returns ErrEstateNotFound unless the estate exists, or was
+// deleted and deleted rows are included.
+func (r *Repository) estateExists(ctx context.Context, estateId string) error {
+	var estateExists bool
+	query := "SELECT EXISTS (SELECT 1 FROM estate WHERE id = $1 AND " + notDeleted(ctx) + ")"
+	err := r.queryRow(ctx, "select_estate_exists", query, estateId).Scan(&estateExists)
+	if err != nil {
+		return err
+	}
+	if !estateExists {
+		return ErrEstateNotFound
+	}
+	return nil
+}
+
 func (r *Repository) GetEstateById(ctx context.Context, id string) (EstateData, error) {
+	ctx, end := r.instrument(ctx, "GetEstateById")
+	defer end()
+	if _, err := uuid.Parse(id); err != nil {
+		return EstateData{}, ErrEstateNotFound
+	}
+	query := "SELECT " + estateColumns + " FROM estate WHERE id = $1 AND " + notDeleted(ctx)
+	estate, err := scanEstate(r.queryRow(ctx, "select_estate_by_id", query, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EstateData{}, ErrEstateNotFound
+	}
+	if err != nil {
+		return EstateData{}, err
+	}
+	return estate, nil
+}
+
+// LockEstate reads an estate and locks it against concurrent resizes and tree
+// inserts until the surrounding transaction ends. Outside WithTx the lock is
+// released immediately.
+func (r *Repository) LockEstate(ctx context.Context, id string) (EstateData, error) {
+	ctx, end := r.instrument(ctx, "LockEstate")
+	defer end()
+	if _, err := uuid.Parse(id); err != nil {
+		return EstateData{}, ErrEstateNotFound
+	}
+	query := "SELECT " + estateColumns + " FROM estate WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+	estate, err := scanEstate(r.queryRow(ctx, "select_estate_for_update", query, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EstateData{}, ErrEstateNotFound
+	}
+	if err != nil {
+		return EstateData{}, err
+	}
+	return estate, nil
+}
+
+// CountTreesOutside counts the trees of an estate that would not fit into an
+// estate of the given size.
+func (r *Repository) CountTreesOutside(ctx context.Context, estateId string, length, width int) (int, error) {
+	ctx, end := r.instrument(ctx, "CountTreesOutside")
+	defer end()
+	var count int
+	err := r.queryRow(ctx, "select_trees_outside", `
+		SELECT COUNT(*)
+		FROM tree
+		WHERE estateId = $1 AND deleted_at IS NULL AND (x > $2 OR y > $3)
+	`, estateId, length, width).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateEstate resizes and optionally geo-references an estate if it is still
+// at the given version, or at any version for AnyVersion, and increments the
+// version.
+func (r *Repository) UpdateEstate(ctx context.Context, id string, input EstateRequest, version int) (EstateData, error) {
+	ctx, end := r.instrument(ctx, "UpdateEstate")
+	defer end()
+	var estate EstateData
+	err := r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.LockEstate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && before.Version != version {
+			return ErrVersionMismatch
+		}
+		// A nil geo-reference leaves the current one, set or not, in place.
+		query := `
+			UPDATE estate
+			SET length = $2, width = $3, version = version + 1,
+			    latitude = COALESCE($4, latitude),
+			    longitude = COALESCE($5, longitude),
+			    bearing = COALESCE($6, bearing),
+			    plot_size_meters = COALESCE($7, plot_size_meters)
+			WHERE id = $1
+			RETURNING ` + estateColumns
+		latitude, longitude, bearing, plotSize := geoArgs(input.GeoReference)
+		estate, err = scanEstate(tx.writeRow(ctx, "update_estate", query,
+			id, input.Length, input.Width, latitude, longitude, bearing, plotSize).Scan)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: estate.Id,
+			Entity:   AuditEntityEstate,
+			EntityId: estate.Id,
+			Action:   AuditActionUpdate,
+			Before:   before,
+			After:    estate,
+		})
+	})
+	if err != nil {
+		return EstateData{}, err
+	}
+	return estate, nil
+}
+
+// DeleteEstate soft deletes an estate that is still at the given version
+// together with its standing trees. Until PurgeDeleted removes them,
+// RestoreEstate brings both back.
+func (r *Repository) DeleteEstate(ctx context.Context, id string, version int) error {
+	ctx, end := r.instrument(ctx, "DeleteEstate")
+	defer end()
+	return r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.LockEstate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && before.Version != version {
+			return ErrVersionMismatch
+		}
+		// Trees share the deleted_at of their estate, which tells them apart
+		// from trees deleted earlier on their own when the estate is restored.
+		query := "UPDATE estate SET deleted_at = now() WHERE id = $1"
+		if _, err := tx.exec(ctx, "delete_estate", query, id); err != nil {
+			return err
+		}
+		// Each deleted tree gets its own audit entry.
+		_, err = tx.exec(ctx, "delete_trees_by_estate", `
+			WITH deleted AS (
+				UPDATE tree SET deleted_at = now()
+				WHERE estateId = $1 AND deleted_at IS NULL
+				RETURNING `+treeColumns+`
+			)
+			INSERT INTO audit_log (estate_id, entity, entity_id, action, actor, request_id, before)
+			SELECT $2, $3, id, $4, $5, $6, `+treeAuditJSON+`
+			FROM deleted
+		`, id, before.Id, AuditEntityTree, AuditActionDelete, ActorFromContext(ctx), nullableRequestID(ctx))
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: before.Id,
+			Entity:   AuditEntityEstate,
+			EntityId: before.Id,
+			Action:   AuditActionDelete,
+			Before:   before,
+		})
+	})
+}
+
+// treeColumns are the columns scanTree reads.
+const treeColumns = "id, estateId, x, y, height, species, variety, planted_on, health, attributes, version, deleted_at"
+
+// treeAuditJSON builds the audit JSON of a tree in SQL, for statements that
+// change many trees at once. It matches TreeData.
+const treeAuditJSON = `jsonb_strip_nulls(jsonb_build_object(
+	'id', id, 'estate_id', estateId, 'x', x, 'y', y, 'height', height,
+	'species', species, 'variety', variety, 'planted_on', planted_on, 'health', health,
+	'attributes', NULLIF(attributes, '{}'), 'version', version, 'deleted_at', deleted_at))`
+
+func scanTree(scan func(dest ...any) error) (TreeData, error) {
+	var tree TreeData
+	var species, variety sql.NullString
+	var attributes []byte
+	err := scan(&tree.Id, &tree.EstateId, &tree.X, &tree.Y, &tree.Height, &species, &variety,
+		&tree.PlantedOn, &tree.Health, &attributes, &tree.Version, &tree.DeletedAt)
+	if err != nil {
+		return TreeData{}, err
+	}
+	tree.Species, tree.Variety = species.String, variety.String
+	if err := json.Unmarshal(attributes, &tree.Attributes); err != nil {
+		return TreeData{}, fmt.Errorf("decoding tree attributes: %w", err)
+	}
+	if len(tree.Attributes) == 0 {
+		tree.Attributes = nil
+	}
+	return tree, nil
+}
+
+// updated returns the tree with the fields of an update applied.
+func (tree TreeData) updated(input TreeUpdate) TreeData {
+	if input.Height != nil {
+		tree.Height = *input.Height
+	}
+	if input.Species != nil {
+		tree.Species = *input.Species
+	}
+	if input.Variety != nil {
+		tree.Variety = *input.Variety
+	}
+	if input.PlantedOn != nil {
+		tree.PlantedOn = input.PlantedOn
+	}
+	if input.Health != nil {
+		tree.Health = *input.Health
+	}
+	if input.Attributes != nil {
+		tree.Attributes = input.Attributes
+	}
+	return tree
+}
+
+// attributesJSON encodes the attributes of a tree for its JSONB column.
+func attributesJSON(attributes map[string]any) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(attributes)
+	return string(encoded), err
+}
+
+// nullString maps empty strings to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// estateColumns are the columns scanEstate reads.
+const estateColumns = "id, length, width, version, latitude, longitude, bearing, plot_size_meters, deleted_at"
+
+func scanEstate(scan func(dest ...any) error) (EstateData, error) {
 	var estate EstateData
-	err := r.Db.QueryRowContext(ctx, "SELECT id, length, width FROM estate WHERE id = $1", id).
-		Scan(&estate.Id, &estate.Length, &estate.Width)
+	var latitude, longitude, bearing, plotSize sql.NullFloat64
+	err := scan(&estate.Id, &estate.Length, &estate.Width, &estate.Version,
+		&latitude, &longitude, &bearing, &plotSize, &estate.DeletedAt)
 	if err != nil {
-		return EstateData{}, fmt.Errorf("estate not found")
+		return EstateData{}, err
+	}
+	// The schema sets all four columns or none.
+	if latitude.Valid {
+		estate.GeoReference = &geo.Reference{
+			Latitude:       latitude.Float64,
+			Longitude:      longitude.Float64,
+			Bearing:        bearing.Float64,
+			PlotSizeMeters: plotSize.Float64,
+		}
 	}
 	return estate, nil
 }
 
+// geoArgs returns the column values of ref, all NULL for nil.
+func geoArgs(ref *geo.Reference) (latitude, longitude, bearing, plotSize any) {
+	if ref == nil {
+		return nil, nil, nil, nil
+	}
+	return ref.Latitude, ref.Longitude, ref.Bearing, ref.PlotSizeMeters
+}
+
 func (r *Repository) GetTreesByEstateId(ctx context.Context, estateId string) ([]Tree, error) {
-	rows, err := r.Db.QueryContext(ctx, `
+	ctx, end := r.instrument(ctx, "GetTreesByEstateId")
+	defer end()
+	rows, err := r.query(ctx, "select_trees_by_estate", `
 		SELECT x, y, height
 		FROM tree
-		WHERE estateId = $1
-	`, estateId)
+		WHERE estateId = $1 AND `+notDeleted(ctx), estateId)
 	if err != nil {
 		return nil, err
 	}
@@ -156,5 +626,238 @@ func (r *Repository) GetTreesByEstateId(ctx context.Context, estateId string) ([
 		}
 		trees = append(trees, tree)
 	}
-	return trees, nil
+	return trees, rows.Err()
+}
+
+// ListTrees returns the live trees of an estate with their ids and versions.
+func (r *Repository) ListTrees(ctx context.Context, estateId string) ([]TreeData, error) {
+	ctx, end := r.instrument(ctx, "ListTrees")
+	defer end()
+	if !validIds(estateId) {
+		return nil, nil
+	}
+	rows, err := r.query(ctx, "select_tree_data_by_estate", `
+		SELECT `+treeColumns+`
+		FROM tree
+		WHERE estateId = $1 AND deleted_at IS NULL
+		ORDER BY y, x`, estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trees []TreeData
+	for rows.Next() {
+		tree, err := scanTree(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, rows.Err()
+}
+
+func (r *Repository) GetTreeById(ctx context.Context, estateId, treeId string) (TreeData, error) {
+	ctx, end := r.instrument(ctx, "GetTreeById")
+	defer end()
+	if !validIds(estateId, treeId) {
+		return TreeData{}, ErrTreeNotFound
+	}
+	tree, err := scanTree(r.queryRow(ctx, "select_tree_by_id", `
+		SELECT `+treeColumns+`
+		FROM tree
+		WHERE id = $1 AND estateId = $2 AND `+notDeleted(ctx), treeId, estateId).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TreeData{}, ErrTreeNotFound
+	}
+	if err != nil {
+		return TreeData{}, err
+	}
+	return tree, nil
+}
+
+// ValidateTreeUpdate checks the changed fields of a tree. A new height or
+// species is checked against the maximum height of the species the tree will
+// have; updates without an Id are checked like new trees. Invalid updates are
+// reported as a ValidationError. UpdateTree checks the height again against
+// the locked tree, which concurrent updates may have changed since.
+func (r *Repository) ValidateTreeUpdate(ctx context.Context, input TreeUpdate) error {
+	ctx, end := r.instrument(ctx, "ValidateTreeUpdate")
+	defer end()
+	var variety, health string
+	if input.Variety != nil {
+		variety = *input.Variety
+	}
+	if input.Health != nil {
+		if health = *input.Health; health == "" {
+			return invalidInput(errors.New("health must not be empty"))
+		}
+	}
+	if err := validateTreeDetails(variety, health, input.PlantedOn, input.Attributes); err != nil {
+		return invalidInput(err)
+	}
+	if input.Height == nil && input.Species == nil {
+		return nil
+	}
+
+	var height int
+	var species string
+	if input.Id != "" && validIds(input.EstateId, input.Id) {
+		err := r.queryRow(ctx, "select_tree_height", `
+			SELECT height, COALESCE(species, '')
+			FROM tree
+			WHERE id = $1 AND estateId = $2 AND deleted_at IS NULL
+		`, input.Id, input.EstateId).Scan(&height, &species)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // UpdateTree reports the missing tree
+		}
+		if err != nil {
+			return fmt.Errorf("checking tree: %w", err)
+		}
+	}
+	if input.Height != nil {
+		height = *input.Height
+	}
+	if input.Species != nil {
+		species = *input.Species
+	}
+	return r.checkHeight(ctx, height, species)
+}
+
+// checkHeight checks a height against the maximum height of a species.
+func (r *Repository) checkHeight(ctx context.Context, height int, species string) error {
+	maxHeight, err := r.maxHeight(ctx, species)
+	if errors.Is(err, ErrSpeciesNotFound) {
+		return invalidInput(err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := validateHeight(height, species, maxHeight); err != nil {
+		return invalidInput(err)
+	}
+	return nil
+}
+
+// lockTree reads a tree and locks it until the surrounding transaction ends.
+func (r *Repository) lockTree(ctx context.Context, estateId, treeId string) (TreeData, error) {
+	if !validIds(estateId, treeId) {
+		return TreeData{}, ErrTreeNotFound
+	}
+	tree, err := scanTree(r.queryRow(ctx, "select_tree_for_update", `
+		SELECT `+treeColumns+`
+		FROM tree
+		WHERE id = $1 AND estateId = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, treeId, estateId).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TreeData{}, ErrTreeNotFound
+	}
+	if err != nil {
+		return TreeData{}, err
+	}
+	return tree, nil
+}
+
+// UpdateTree changes the given fields of a tree that is still at
+// input.Version and increments its version. A new height or species is
+// checked against the locked tree and reported as a ValidationError.
+func (r *Repository) UpdateTree(ctx context.Context, input TreeUpdate) (TreeData, error) {
+	ctx, end := r.instrument(ctx, "UpdateTree")
+	defer end()
+	var tree TreeData
+	err := r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.lockTree(ctx, input.EstateId, input.Id)
+		if err != nil {
+			return err
+		}
+		if input.Version != AnyVersion && before.Version != input.Version {
+			return ErrVersionMismatch
+		}
+		after := before.updated(input)
+		if input.Height != nil || input.Species != nil {
+			if err := tx.checkHeight(ctx, after.Height, after.Species); err != nil {
+				return err
+			}
+		}
+		attributes, err := attributesJSON(after.Attributes)
+		if err != nil {
+			return err
+		}
+		tree, err = scanTree(tx.writeRow(ctx, "update_tree", `
+			UPDATE tree
+			SET height = $2, species = $3, variety = $4, planted_on = $5, health = $6, attributes = $7,
+				version = version + 1
+			WHERE id = $1
+			RETURNING `+treeColumns,
+			input.Id, after.Height, nullString(after.Species), nullString(after.Variety), after.PlantedOn,
+			after.Health, attributes).Scan)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: uuid.MustParse(tree.EstateId),
+			Entity:   AuditEntityTree,
+			EntityId: tree.Id,
+			Action:   AuditActionUpdate,
+			Before:   before,
+			After:    tree,
+		})
+	})
+	if err != nil {
+		return TreeData{}, err
+	}
+	return tree, nil
+}
+
+// DeleteTree soft deletes a tree that is still at the given version.
+func (r *Repository) DeleteTree(ctx context.Context, estateId, treeId string, version int) error {
+	ctx, end := r.instrument(ctx, "DeleteTree")
+	defer end()
+	return r.inTx(ctx, func(tx *Repository) error {
+		before, err := tx.lockTree(ctx, estateId, treeId)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && before.Version != version {
+			return ErrVersionMismatch
+		}
+		if _, err := tx.exec(ctx, "delete_tree", "UPDATE tree SET deleted_at = now() WHERE id = $1", treeId); err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: uuid.MustParse(before.EstateId),
+			Entity:   AuditEntityTree,
+			EntityId: before.Id,
+			Action:   AuditActionDelete,
+			Before:   before,
+		})
+	})
+}
+
+// validIds reports whether every id is a UUID; malformed ids never match a row.
+func validIds(ids ...string) bool {
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	ctx, end := r.instrument(ctx, "Ping")
+	defer end()
+	return r.Db.PingContext(ctx)
+}
+
+func (r *Repository) GetSchemaVersion(ctx context.Context) (int, error) {
+	ctx, end := r.instrument(ctx, "GetSchemaVersion")
+	defer end()
+	var version int
+	err := r.queryRow(ctx, "select_schema_version", "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return version, nil
 }
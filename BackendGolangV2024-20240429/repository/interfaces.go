@@ -6,6 +6,7 @@ package repository
 
 import (
 	"context"
+	"time"
 )
 
 type RepositoryInterface interface {
@@ -15,6 +16,64 @@ type RepositoryInterface interface {
 	ValidateEstateRequest(ctx context.Context, input EstateRequest) (err error)
 	ValidateTreeRequest(ctx context.Context, estateId string, input TreeRequest) (err error)
 	GetEstateStats(ctx context.Context, estateId string) (EstateStats, error)
+	GetEstateStatsGroups(ctx context.Context, estateId string, groupBy []string) ([]EstateStatsGroup, error)
 	GetEstateById(ctx context.Context, id string) (EstateData, error)
+	LockEstate(ctx context.Context, id string) (EstateData, error)
+	UpdateEstate(ctx context.Context, id string, input EstateRequest, version int) (EstateData, error)
+	DeleteEstate(ctx context.Context, id string, version int) (err error)
+	CountTreesOutside(ctx context.Context, estateId string, length, width int) (count int, err error)
 	GetTreesByEstateId(ctx context.Context, estateId string) ([]Tree, error)
+	ListTrees(ctx context.Context, estateId string) ([]TreeData, error)
+	GetTreeById(ctx context.Context, estateId, treeId string) (TreeData, error)
+	ValidateTreeUpdate(ctx context.Context, input TreeUpdate) (err error)
+	UpdateTree(ctx context.Context, input TreeUpdate) (TreeData, error)
+	DeleteTree(ctx context.Context, estateId, treeId string, version int) (err error)
+	RestoreEstate(ctx context.Context, id string) (EstateData, error)
+	RestoreTree(ctx context.Context, estateId, treeId string) (TreeData, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (estates, trees int64, err error)
+	Ping(ctx context.Context) (err error)
+	GetSchemaVersion(ctx context.Context) (version int, err error)
+	ListAuditEntries(ctx context.Context, input AuditQuery) ([]AuditEntry, error)
+	ClaimIdempotencyKey(ctx context.Context, input IdempotencyKeyRequest) (IdempotencyKey, error)
+	SaveIdempotentResponse(ctx context.Context, input IdempotentResponse) (err error)
+	ReleaseIdempotencyKey(ctx context.Context, key string) (err error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (deleted int64, err error)
+	ListEvents(ctx context.Context, estateId string, after EventPosition, limit int) (events []Event, pending bool, err error)
+	LastEventPosition(ctx context.Context, estateId string) (position EventPosition, err error)
+	CreateWebhookSubscription(ctx context.Context, input WebhookSubscriptionRequest) (WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id string) (err error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, id int64) (err error)
+	RescheduleWebhookDelivery(ctx context.Context, id int64, next time.Time, lastError string) (err error)
+	AbandonWebhookDelivery(ctx context.Context, id int64, lastError string) (err error)
+	DeleteEventsBefore(ctx context.Context, before time.Time) (deleted int64, err error)
+	CreateMission(ctx context.Context, input MissionRequest) (Mission, error)
+	GetMission(ctx context.Context, estateId, missionId string) (Mission, error)
+	ListMissions(ctx context.Context, input MissionQuery) ([]Mission, error)
+	UpdateMissionStatus(ctx context.Context, estateId, missionId, status string) (Mission, error)
+	InsertTelemetry(ctx context.Context, estateId, missionId string, samples []TelemetrySample) (stored int64, err error)
+	ListTelemetry(ctx context.Context, estateId, missionId string) ([]TelemetrySample, error)
+	PutTerrain(ctx context.Context, estateId string, terrain Terrain) (Terrain, error)
+	GetTerrain(ctx context.Context, estateId string) (Terrain, error)
+	DeleteTerrain(ctx context.Context, estateId string) (err error)
+	ListSpecies(ctx context.Context) ([]Species, error)
+	PutSpecies(ctx context.Context, species Species) (Species, error)
+	InsertHarvest(ctx context.Context, input HarvestRequest) (Harvest, error)
+	ListHarvests(ctx context.Context, estateId, treeId string) ([]Harvest, error)
+	GetEstateYield(ctx context.Context, input YieldQuery) ([]YieldGroup, error)
+	GetEstateYieldStats(ctx context.Context, estateId string) (YieldStats, error)
+	InsertDivision(ctx context.Context, estateId, name string) (Division, error)
+	ListDivisions(ctx context.Context, estateId string) ([]Division, error)
+	DeleteDivision(ctx context.Context, estateId, divisionId string) (err error)
+	ValidateBlockRequest(ctx context.Context, input BlockRequest) (err error)
+	InsertBlock(ctx context.Context, input BlockRequest) (Block, error)
+	GetBlock(ctx context.Context, estateId, blockId string) (Block, error)
+	ListBlocks(ctx context.Context, estateId string, divisionId *string) ([]Block, error)
+	UpdateBlock(ctx context.Context, input BlockRequest, version int) (Block, error)
+	DeleteBlock(ctx context.Context, estateId, blockId string, version int) (err error)
+	CountBlocksOutside(ctx context.Context, estateId string, length, width int) (count int, err error)
+	GetBlockStats(ctx context.Context, estateId, blockId string) (EstateStats, error)
+	// WithTx runs fn with a repository bound to a single transaction.
+	WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) (err error)
 }
@@ -7,6 +7,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,6 +35,388 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// AbandonWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) AbandonWebhookDelivery(ctx context.Context, id int64, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbandonWebhookDelivery", ctx, id, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbandonWebhookDelivery indicates an expected call of AbandonWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) AbandonWebhookDelivery(ctx, id, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbandonWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).AbandonWebhookDelivery), ctx, id, lastError)
+}
+
+// ClaimIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ClaimIdempotencyKey(ctx context.Context, input IdempotencyKeyRequest) (IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimIdempotencyKey", ctx, input)
+	ret0, _ := ret[0].(IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimIdempotencyKey indicates an expected call of ClaimIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimIdempotencyKey(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimIdempotencyKey), ctx, input)
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, limit, lease)
+	ret0, _ := ret[0].([]WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimWebhookDeliveries(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimWebhookDeliveries), ctx, limit, lease)
+}
+
+// CompleteWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) CompleteWebhookDelivery(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteWebhookDelivery", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteWebhookDelivery indicates an expected call of CompleteWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteWebhookDelivery(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteWebhookDelivery), ctx, id)
+}
+
+// CountBlocksOutside mocks base method.
+func (m *MockRepositoryInterface) CountBlocksOutside(ctx context.Context, estateId string, length, width int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountBlocksOutside", ctx, estateId, length, width)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountBlocksOutside indicates an expected call of CountBlocksOutside.
+func (mr *MockRepositoryInterfaceMockRecorder) CountBlocksOutside(ctx, estateId, length, width interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountBlocksOutside", reflect.TypeOf((*MockRepositoryInterface)(nil).CountBlocksOutside), ctx, estateId, length, width)
+}
+
+// CountTreesOutside mocks base method.
+func (m *MockRepositoryInterface) CountTreesOutside(ctx context.Context, estateId string, length, width int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTreesOutside", ctx, estateId, length, width)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTreesOutside indicates an expected call of CountTreesOutside.
+func (mr *MockRepositoryInterfaceMockRecorder) CountTreesOutside(ctx, estateId, length, width interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTreesOutside", reflect.TypeOf((*MockRepositoryInterface)(nil).CountTreesOutside), ctx, estateId, length, width)
+}
+
+// CreateMission mocks base method.
+func (m *MockRepositoryInterface) CreateMission(ctx context.Context, input MissionRequest) (Mission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMission", ctx, input)
+	ret0, _ := ret[0].(Mission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMission indicates an expected call of CreateMission.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateMission(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMission", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateMission), ctx, input)
+}
+
+// CreateWebhookSubscription mocks base method.
+func (m *MockRepositoryInterface) CreateWebhookSubscription(ctx context.Context, input WebhookSubscriptionRequest) (WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", ctx, input)
+	ret0, _ := ret[0].(WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateWebhookSubscription(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateWebhookSubscription), ctx, input)
+}
+
+// DeleteBlock mocks base method.
+func (m *MockRepositoryInterface) DeleteBlock(ctx context.Context, estateId, blockId string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBlock", ctx, estateId, blockId, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBlock indicates an expected call of DeleteBlock.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteBlock(ctx, estateId, blockId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBlock", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteBlock), ctx, estateId, blockId, version)
+}
+
+// DeleteDivision mocks base method.
+func (m *MockRepositoryInterface) DeleteDivision(ctx context.Context, estateId, divisionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDivision", ctx, estateId, divisionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDivision indicates an expected call of DeleteDivision.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteDivision(ctx, estateId, divisionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDivision", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteDivision), ctx, estateId, divisionId)
+}
+
+// DeleteEstate mocks base method.
+func (m *MockRepositoryInterface) DeleteEstate(ctx context.Context, id string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEstate", ctx, id, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEstate indicates an expected call of DeleteEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteEstate(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteEstate), ctx, id, version)
+}
+
+// DeleteEventsBefore mocks base method.
+func (m *MockRepositoryInterface) DeleteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEventsBefore", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteEventsBefore indicates an expected call of DeleteEventsBefore.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteEventsBefore(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEventsBefore", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteEventsBefore), ctx, before)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockRepositoryInterface) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteExpiredIdempotencyKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteExpiredIdempotencyKeys), ctx)
+}
+
+// DeleteTerrain mocks base method.
+func (m *MockRepositoryInterface) DeleteTerrain(ctx context.Context, estateId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTerrain", ctx, estateId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTerrain indicates an expected call of DeleteTerrain.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteTerrain(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTerrain", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteTerrain), ctx, estateId)
+}
+
+// DeleteTree mocks base method.
+func (m *MockRepositoryInterface) DeleteTree(ctx context.Context, estateId, treeId string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTree", ctx, estateId, treeId, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTree indicates an expected call of DeleteTree.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteTree(ctx, estateId, treeId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteTree), ctx, estateId, treeId, version)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockRepositoryInterface) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteWebhookSubscription(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteWebhookSubscription), ctx, id)
+}
+
+// GetBlock mocks base method.
+func (m *MockRepositoryInterface) GetBlock(ctx context.Context, estateId, blockId string) (Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlock", ctx, estateId, blockId)
+	ret0, _ := ret[0].(Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlock indicates an expected call of GetBlock.
+func (mr *MockRepositoryInterfaceMockRecorder) GetBlock(ctx, estateId, blockId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlock", reflect.TypeOf((*MockRepositoryInterface)(nil).GetBlock), ctx, estateId, blockId)
+}
+
+// GetBlockStats mocks base method.
+func (m *MockRepositoryInterface) GetBlockStats(ctx context.Context, estateId, blockId string) (EstateStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockStats", ctx, estateId, blockId)
+	ret0, _ := ret[0].(EstateStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockStats indicates an expected call of GetBlockStats.
+func (mr *MockRepositoryInterfaceMockRecorder) GetBlockStats(ctx, estateId, blockId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockStats", reflect.TypeOf((*MockRepositoryInterface)(nil).GetBlockStats), ctx, estateId, blockId)
+}
+
+// GetEstateById mocks base method.
+func (m *MockRepositoryInterface) GetEstateById(ctx context.Context, id string) (EstateData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateById", ctx, id)
+	ret0, _ := ret[0].(EstateData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateById indicates an expected call of GetEstateById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateById), ctx, id)
+}
+
+// GetEstateStats mocks base method.
+func (m *MockRepositoryInterface) GetEstateStats(ctx context.Context, estateId string) (EstateStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateStats", ctx, estateId)
+	ret0, _ := ret[0].(EstateStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateStats indicates an expected call of GetEstateStats.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateStats(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateStats", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateStats), ctx, estateId)
+}
+
+// GetEstateStatsGroups mocks base method.
+func (m *MockRepositoryInterface) GetEstateStatsGroups(ctx context.Context, estateId string, groupBy []string) ([]EstateStatsGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateStatsGroups", ctx, estateId, groupBy)
+	ret0, _ := ret[0].([]EstateStatsGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateStatsGroups indicates an expected call of GetEstateStatsGroups.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateStatsGroups(ctx, estateId, groupBy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateStatsGroups", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateStatsGroups), ctx, estateId, groupBy)
+}
+
+// GetEstateYield mocks base method.
+func (m *MockRepositoryInterface) GetEstateYield(ctx context.Context, input YieldQuery) ([]YieldGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateYield", ctx, input)
+	ret0, _ := ret[0].([]YieldGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateYield indicates an expected call of GetEstateYield.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateYield(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateYield", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateYield), ctx, input)
+}
+
+// GetEstateYieldStats mocks base method.
+func (m *MockRepositoryInterface) GetEstateYieldStats(ctx context.Context, estateId string) (YieldStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateYieldStats", ctx, estateId)
+	ret0, _ := ret[0].(YieldStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateYieldStats indicates an expected call of GetEstateYieldStats.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateYieldStats(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateYieldStats", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateYieldStats), ctx, estateId)
+}
+
+// GetMission mocks base method.
+func (m *MockRepositoryInterface) GetMission(ctx context.Context, estateId, missionId string) (Mission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMission", ctx, estateId, missionId)
+	ret0, _ := ret[0].(Mission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMission indicates an expected call of GetMission.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMission(ctx, estateId, missionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMission", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMission), ctx, estateId, missionId)
+}
+
+// GetSchemaVersion mocks base method.
+func (m *MockRepositoryInterface) GetSchemaVersion(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchemaVersion", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchemaVersion indicates an expected call of GetSchemaVersion.
+func (mr *MockRepositoryInterfaceMockRecorder) GetSchemaVersion(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchemaVersion", reflect.TypeOf((*MockRepositoryInterface)(nil).GetSchemaVersion), ctx)
+}
+
+// GetTerrain mocks base method.
+func (m *MockRepositoryInterface) GetTerrain(ctx context.Context, estateId string) (Terrain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTerrain", ctx, estateId)
+	ret0, _ := ret[0].(Terrain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTerrain indicates an expected call of GetTerrain.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTerrain(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTerrain", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTerrain), ctx, estateId)
+}
+
 // GetTestById mocks base method.
 func (m *MockRepositoryInterface) GetTestById(ctx context.Context, input GetTestByIdInput) (GetTestByIdOutput, error) {
 	m.ctrl.T.Helper()
@@ -48,3 +431,566 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetTestById(ctx, input interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTestById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTestById), ctx, input)
 }
+
+// GetTreeById mocks base method.
+func (m *MockRepositoryInterface) GetTreeById(ctx context.Context, estateId, treeId string) (TreeData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreeById", ctx, estateId, treeId)
+	ret0, _ := ret[0].(TreeData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreeById indicates an expected call of GetTreeById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTreeById(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreeById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTreeById), ctx, estateId, treeId)
+}
+
+// GetTreesByEstateId mocks base method.
+func (m *MockRepositoryInterface) GetTreesByEstateId(ctx context.Context, estateId string) ([]Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreesByEstateId", ctx, estateId)
+	ret0, _ := ret[0].([]Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreesByEstateId indicates an expected call of GetTreesByEstateId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTreesByEstateId(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreesByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTreesByEstateId), ctx, estateId)
+}
+
+// InsertBlock mocks base method.
+func (m *MockRepositoryInterface) InsertBlock(ctx context.Context, input BlockRequest) (Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertBlock", ctx, input)
+	ret0, _ := ret[0].(Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertBlock indicates an expected call of InsertBlock.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertBlock(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertBlock", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertBlock), ctx, input)
+}
+
+// InsertDivision mocks base method.
+func (m *MockRepositoryInterface) InsertDivision(ctx context.Context, estateId, name string) (Division, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDivision", ctx, estateId, name)
+	ret0, _ := ret[0].(Division)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertDivision indicates an expected call of InsertDivision.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertDivision(ctx, estateId, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDivision", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertDivision), ctx, estateId, name)
+}
+
+// InsertEstate mocks base method.
+func (m *MockRepositoryInterface) InsertEstate(ctx context.Context, input EstateRequest) (EstateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertEstate", ctx, input)
+	ret0, _ := ret[0].(EstateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertEstate indicates an expected call of InsertEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertEstate(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertEstate), ctx, input)
+}
+
+// InsertHarvest mocks base method.
+func (m *MockRepositoryInterface) InsertHarvest(ctx context.Context, input HarvestRequest) (Harvest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertHarvest", ctx, input)
+	ret0, _ := ret[0].(Harvest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertHarvest indicates an expected call of InsertHarvest.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertHarvest(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertHarvest", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertHarvest), ctx, input)
+}
+
+// InsertTelemetry mocks base method.
+func (m *MockRepositoryInterface) InsertTelemetry(ctx context.Context, estateId, missionId string, samples []TelemetrySample) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertTelemetry", ctx, estateId, missionId, samples)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertTelemetry indicates an expected call of InsertTelemetry.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertTelemetry(ctx, estateId, missionId, samples interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertTelemetry", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertTelemetry), ctx, estateId, missionId, samples)
+}
+
+// InsertTree mocks base method.
+func (m *MockRepositoryInterface) InsertTree(ctx context.Context, input TreeRequest) (TreeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertTree", ctx, input)
+	ret0, _ := ret[0].(TreeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertTree indicates an expected call of InsertTree.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertTree(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertTree", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertTree), ctx, input)
+}
+
+// LastEventPosition mocks base method.
+func (m *MockRepositoryInterface) LastEventPosition(ctx context.Context, estateId string) (EventPosition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastEventPosition", ctx, estateId)
+	ret0, _ := ret[0].(EventPosition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastEventPosition indicates an expected call of LastEventPosition.
+func (mr *MockRepositoryInterfaceMockRecorder) LastEventPosition(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastEventPosition", reflect.TypeOf((*MockRepositoryInterface)(nil).LastEventPosition), ctx, estateId)
+}
+
+// ListAuditEntries mocks base method.
+func (m *MockRepositoryInterface) ListAuditEntries(ctx context.Context, input AuditQuery) ([]AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEntries", ctx, input)
+	ret0, _ := ret[0].([]AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEntries indicates an expected call of ListAuditEntries.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAuditEntries(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEntries", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAuditEntries), ctx, input)
+}
+
+// ListBlocks mocks base method.
+func (m *MockRepositoryInterface) ListBlocks(ctx context.Context, estateId string, divisionId *string) ([]Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBlocks", ctx, estateId, divisionId)
+	ret0, _ := ret[0].([]Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBlocks indicates an expected call of ListBlocks.
+func (mr *MockRepositoryInterfaceMockRecorder) ListBlocks(ctx, estateId, divisionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBlocks", reflect.TypeOf((*MockRepositoryInterface)(nil).ListBlocks), ctx, estateId, divisionId)
+}
+
+// ListDivisions mocks base method.
+func (m *MockRepositoryInterface) ListDivisions(ctx context.Context, estateId string) ([]Division, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDivisions", ctx, estateId)
+	ret0, _ := ret[0].([]Division)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDivisions indicates an expected call of ListDivisions.
+func (mr *MockRepositoryInterfaceMockRecorder) ListDivisions(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDivisions", reflect.TypeOf((*MockRepositoryInterface)(nil).ListDivisions), ctx, estateId)
+}
+
+// ListEvents mocks base method.
+func (m *MockRepositoryInterface) ListEvents(ctx context.Context, estateId string, after EventPosition, limit int) ([]Event, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvents", ctx, estateId, after, limit)
+	ret0, _ := ret[0].([]Event)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListEvents indicates an expected call of ListEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) ListEvents(ctx, estateId, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).ListEvents), ctx, estateId, after, limit)
+}
+
+// ListHarvests mocks base method.
+func (m *MockRepositoryInterface) ListHarvests(ctx context.Context, estateId, treeId string) ([]Harvest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListHarvests", ctx, estateId, treeId)
+	ret0, _ := ret[0].([]Harvest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListHarvests indicates an expected call of ListHarvests.
+func (mr *MockRepositoryInterfaceMockRecorder) ListHarvests(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHarvests", reflect.TypeOf((*MockRepositoryInterface)(nil).ListHarvests), ctx, estateId, treeId)
+}
+
+// ListMissions mocks base method.
+func (m *MockRepositoryInterface) ListMissions(ctx context.Context, input MissionQuery) ([]Mission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMissions", ctx, input)
+	ret0, _ := ret[0].([]Mission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMissions indicates an expected call of ListMissions.
+func (mr *MockRepositoryInterfaceMockRecorder) ListMissions(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMissions", reflect.TypeOf((*MockRepositoryInterface)(nil).ListMissions), ctx, input)
+}
+
+// ListSpecies mocks base method.
+func (m *MockRepositoryInterface) ListSpecies(ctx context.Context) ([]Species, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSpecies", ctx)
+	ret0, _ := ret[0].([]Species)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSpecies indicates an expected call of ListSpecies.
+func (mr *MockRepositoryInterfaceMockRecorder) ListSpecies(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSpecies", reflect.TypeOf((*MockRepositoryInterface)(nil).ListSpecies), ctx)
+}
+
+// ListTelemetry mocks base method.
+func (m *MockRepositoryInterface) ListTelemetry(ctx context.Context, estateId, missionId string) ([]TelemetrySample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTelemetry", ctx, estateId, missionId)
+	ret0, _ := ret[0].([]TelemetrySample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTelemetry indicates an expected call of ListTelemetry.
+func (mr *MockRepositoryInterfaceMockRecorder) ListTelemetry(ctx, estateId, missionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTelemetry", reflect.TypeOf((*MockRepositoryInterface)(nil).ListTelemetry), ctx, estateId, missionId)
+}
+
+// ListTrees mocks base method.
+func (m *MockRepositoryInterface) ListTrees(ctx context.Context, estateId string) ([]TreeData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrees", ctx, estateId)
+	ret0, _ := ret[0].([]TreeData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrees indicates an expected call of ListTrees.
+func (mr *MockRepositoryInterfaceMockRecorder) ListTrees(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrees", reflect.TypeOf((*MockRepositoryInterface)(nil).ListTrees), ctx, estateId)
+}
+
+// ListWebhookSubscriptions mocks base method.
+func (m *MockRepositoryInterface) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookSubscriptions", ctx)
+	ret0, _ := ret[0].([]WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookSubscriptions indicates an expected call of ListWebhookSubscriptions.
+func (mr *MockRepositoryInterfaceMockRecorder) ListWebhookSubscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookSubscriptions", reflect.TypeOf((*MockRepositoryInterface)(nil).ListWebhookSubscriptions), ctx)
+}
+
+// LockEstate mocks base method.
+func (m *MockRepositoryInterface) LockEstate(ctx context.Context, id string) (EstateData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockEstate", ctx, id)
+	ret0, _ := ret[0].(EstateData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockEstate indicates an expected call of LockEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) LockEstate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).LockEstate), ctx, id)
+}
+
+// Ping mocks base method.
+func (m *MockRepositoryInterface) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockRepositoryInterfaceMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRepositoryInterface)(nil).Ping), ctx)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockRepositoryInterface) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, deletedBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeDeleted(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeDeleted), ctx, deletedBefore)
+}
+
+// PutSpecies mocks base method.
+func (m *MockRepositoryInterface) PutSpecies(ctx context.Context, species Species) (Species, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutSpecies", ctx, species)
+	ret0, _ := ret[0].(Species)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutSpecies indicates an expected call of PutSpecies.
+func (mr *MockRepositoryInterfaceMockRecorder) PutSpecies(ctx, species interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutSpecies", reflect.TypeOf((*MockRepositoryInterface)(nil).PutSpecies), ctx, species)
+}
+
+// PutTerrain mocks base method.
+func (m *MockRepositoryInterface) PutTerrain(ctx context.Context, estateId string, terrain Terrain) (Terrain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutTerrain", ctx, estateId, terrain)
+	ret0, _ := ret[0].(Terrain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutTerrain indicates an expected call of PutTerrain.
+func (mr *MockRepositoryInterfaceMockRecorder) PutTerrain(ctx, estateId, terrain interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutTerrain", reflect.TypeOf((*MockRepositoryInterface)(nil).PutTerrain), ctx, estateId, terrain)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ReleaseIdempotencyKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ReleaseIdempotencyKey), ctx, key)
+}
+
+// RescheduleWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) RescheduleWebhookDelivery(ctx context.Context, id int64, next time.Time, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RescheduleWebhookDelivery", ctx, id, next, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RescheduleWebhookDelivery indicates an expected call of RescheduleWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) RescheduleWebhookDelivery(ctx, id, next, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RescheduleWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).RescheduleWebhookDelivery), ctx, id, next, lastError)
+}
+
+// RestoreEstate mocks base method.
+func (m *MockRepositoryInterface) RestoreEstate(ctx context.Context, id string) (EstateData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreEstate", ctx, id)
+	ret0, _ := ret[0].(EstateData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreEstate indicates an expected call of RestoreEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) RestoreEstate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).RestoreEstate), ctx, id)
+}
+
+// RestoreTree mocks base method.
+func (m *MockRepositoryInterface) RestoreTree(ctx context.Context, estateId, treeId string) (TreeData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTree", ctx, estateId, treeId)
+	ret0, _ := ret[0].(TreeData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreTree indicates an expected call of RestoreTree.
+func (mr *MockRepositoryInterfaceMockRecorder) RestoreTree(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTree", reflect.TypeOf((*MockRepositoryInterface)(nil).RestoreTree), ctx, estateId, treeId)
+}
+
+// SaveIdempotentResponse mocks base method.
+func (m *MockRepositoryInterface) SaveIdempotentResponse(ctx context.Context, input IdempotentResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotentResponse", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotentResponse indicates an expected call of SaveIdempotentResponse.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveIdempotentResponse(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotentResponse", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveIdempotentResponse), ctx, input)
+}
+
+// UpdateBlock mocks base method.
+func (m *MockRepositoryInterface) UpdateBlock(ctx context.Context, input BlockRequest, version int) (Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBlock", ctx, input, version)
+	ret0, _ := ret[0].(Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateBlock indicates an expected call of UpdateBlock.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateBlock(ctx, input, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBlock", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateBlock), ctx, input, version)
+}
+
+// UpdateEstate mocks base method.
+func (m *MockRepositoryInterface) UpdateEstate(ctx context.Context, id string, input EstateRequest, version int) (EstateData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEstate", ctx, id, input, version)
+	ret0, _ := ret[0].(EstateData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateEstate indicates an expected call of UpdateEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateEstate(ctx, id, input, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateEstate), ctx, id, input, version)
+}
+
+// UpdateMissionStatus mocks base method.
+func (m *MockRepositoryInterface) UpdateMissionStatus(ctx context.Context, estateId, missionId, status string) (Mission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMissionStatus", ctx, estateId, missionId, status)
+	ret0, _ := ret[0].(Mission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMissionStatus indicates an expected call of UpdateMissionStatus.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateMissionStatus(ctx, estateId, missionId, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMissionStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateMissionStatus), ctx, estateId, missionId, status)
+}
+
+// UpdateTree mocks base method.
+func (m *MockRepositoryInterface) UpdateTree(ctx context.Context, input TreeUpdate) (TreeData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTree", ctx, input)
+	ret0, _ := ret[0].(TreeData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTree indicates an expected call of UpdateTree.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateTree(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTree", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateTree), ctx, input)
+}
+
+// ValidateBlockRequest mocks base method.
+func (m *MockRepositoryInterface) ValidateBlockRequest(ctx context.Context, input BlockRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateBlockRequest", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateBlockRequest indicates an expected call of ValidateBlockRequest.
+func (mr *MockRepositoryInterfaceMockRecorder) ValidateBlockRequest(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateBlockRequest", reflect.TypeOf((*MockRepositoryInterface)(nil).ValidateBlockRequest), ctx, input)
+}
+
+// ValidateEstateRequest mocks base method.
+func (m *MockRepositoryInterface) ValidateEstateRequest(ctx context.Context, input EstateRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateEstateRequest", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateEstateRequest indicates an expected call of ValidateEstateRequest.
+func (mr *MockRepositoryInterfaceMockRecorder) ValidateEstateRequest(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateEstateRequest", reflect.TypeOf((*MockRepositoryInterface)(nil).ValidateEstateRequest), ctx, input)
+}
+
+// ValidateTreeRequest mocks base method.
+func (m *MockRepositoryInterface) ValidateTreeRequest(ctx context.Context, estateId string, input TreeRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateTreeRequest", ctx, estateId, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateTreeRequest indicates an expected call of ValidateTreeRequest.
+func (mr *MockRepositoryInterfaceMockRecorder) ValidateTreeRequest(ctx, estateId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateTreeRequest", reflect.TypeOf((*MockRepositoryInterface)(nil).ValidateTreeRequest), ctx, estateId, input)
+}
+
+// ValidateTreeUpdate mocks base method.
+func (m *MockRepositoryInterface) ValidateTreeUpdate(ctx context.Context, input TreeUpdate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateTreeUpdate", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateTreeUpdate indicates an expected call of ValidateTreeUpdate.
+func (mr *MockRepositoryInterfaceMockRecorder) ValidateTreeUpdate(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateTreeUpdate", reflect.TypeOf((*MockRepositoryInterface)(nil).ValidateTreeUpdate), ctx, input)
+}
+
+// WithTx mocks base method.
+func (m *MockRepositoryInterface) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryInterfaceMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepositoryInterface)(nil).WithTx), ctx, fn)
+}
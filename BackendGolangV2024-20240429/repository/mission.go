@@ -0,0 +1,191 @@
+// This file contains the persisted drone missions of an estate.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// missionTransitions lists the statuses a mission may move to from each
+// status, and the column recording when it did.
+var missionTransitions = map[string]struct {
+	from   []string
+	column string
+}{
+	MissionInFlight:  {[]string{MissionPlanned}, "started_at"},
+	MissionCompleted: {[]string{MissionInFlight}, "completed_at"},
+	MissionAborted:   {[]string{MissionPlanned, MissionInFlight}, "aborted_at"},
+}
+
+const missionColumns = `id, estate_id, status, max_distance, plot_size_meters, clearance_meters,
+	distance, landing_x, landing_y, trees_hash, created_at, started_at, completed_at, aborted_at`
+
+// CreateMission stores a planned mission of a live estate.
+func (r *Repository) CreateMission(ctx context.Context, input MissionRequest) (Mission, error) {
+	ctx, end := r.instrument(ctx, "CreateMission")
+	defer end()
+	if !validIds(input.EstateId) {
+		return Mission{}, ErrEstateNotFound
+	}
+	path, err := json.Marshal(input.Path)
+	if err != nil {
+		return Mission{}, err
+	}
+	row := r.writeRow(ctx, "insert_mission", `
+		INSERT INTO mission (estate_id, max_distance, plot_size_meters, clearance_meters,
+			distance, landing_x, landing_y, trees_hash, path)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8, $9
+		FROM estate
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+missionColumns,
+		input.EstateId, input.MaxDistance, input.PlotSizeMeters, input.ClearanceMeters,
+		input.Distance, input.LandingX, input.LandingY, input.TreesHash, path)
+	mission, err := scanMission(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Mission{}, ErrEstateNotFound
+	}
+	if err != nil {
+		return Mission{}, err
+	}
+	mission.Path = input.Path
+	return mission, nil
+}
+
+// GetMission returns a mission of a live estate including its path.
+func (r *Repository) GetMission(ctx context.Context, estateId, missionId string) (Mission, error) {
+	ctx, end := r.instrument(ctx, "GetMission")
+	defer end()
+	if !validIds(estateId, missionId) {
+		return Mission{}, ErrMissionNotFound
+	}
+	var path []byte
+	row := r.queryRow(ctx, "select_mission", `
+		SELECT `+missionColumns+`, path
+		FROM mission
+		WHERE id = $1 AND estate_id = $2
+		  AND EXISTS (SELECT 1 FROM estate WHERE id = $2 AND deleted_at IS NULL)
+	`, missionId, estateId)
+	mission, err := scanMission(func(dest ...any) error {
+		return row.Scan(append(dest, &path)...)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return Mission{}, ErrMissionNotFound
+	}
+	if err != nil {
+		return Mission{}, err
+	}
+	if err := json.Unmarshal(path, &mission.Path); err != nil {
+		return Mission{}, err
+	}
+	return mission, nil
+}
+
+// ListMissions returns the missions of an estate without their paths, newest
+// first.
+func (r *Repository) ListMissions(ctx context.Context, input MissionQuery) ([]Mission, error) {
+	ctx, end := r.instrument(ctx, "ListMissions")
+	defer end()
+	if !validIds(input.EstateId) {
+		return nil, ErrEstateNotFound
+	}
+
+	conditions := []string{"estate_id = $1"}
+	args := []any{input.EstateId}
+	if input.Status != nil {
+		args = append(args, *input.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if input.After != nil {
+		args = append(args, input.After.CreatedAt, input.After.Id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, input.Limit)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM mission
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, missionColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := r.query(ctx, "select_missions", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missions := []Mission{}
+	for rows.Next() {
+		mission, err := scanMission(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		missions = append(missions, mission)
+	}
+	return missions, rows.Err()
+}
+
+// UpdateMissionStatus moves a mission to status and records when it did. It
+// returns ErrMissionTransition when the current status does not allow it.
+func (r *Repository) UpdateMissionStatus(ctx context.Context, estateId, missionId, status string) (Mission, error) {
+	ctx, end := r.instrument(ctx, "UpdateMissionStatus")
+	defer end()
+	transition, ok := missionTransitions[status]
+	if !ok {
+		return Mission{}, ErrMissionTransition
+	}
+	if !validIds(estateId, missionId) {
+		return Mission{}, ErrMissionNotFound
+	}
+	row := r.writeRow(ctx, "update_mission_status", `
+		UPDATE mission SET status = $3, `+transition.column+` = now()
+		WHERE id = $1 AND estate_id = $2 AND status = ANY($4)
+		  AND EXISTS (SELECT 1 FROM estate WHERE id = $2 AND deleted_at IS NULL)
+		RETURNING `+missionColumns,
+		missionId, estateId, status, pq.Array(transition.from))
+	mission, err := scanMission(row.Scan)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return mission, err
+	}
+
+	// Nothing was updated: tell a missing mission from one in the wrong status.
+	var exists bool
+	err = r.queryRow(ctx, "select_mission_exists", `
+		SELECT EXISTS (
+			SELECT 1 FROM mission
+			WHERE id = $1 AND estate_id = $2
+			  AND EXISTS (SELECT 1 FROM estate WHERE id = $2 AND deleted_at IS NULL)
+		)
+	`, missionId, estateId).Scan(&exists)
+	if err != nil {
+		return Mission{}, err
+	}
+	if !exists {
+		return Mission{}, ErrMissionNotFound
+	}
+	return Mission{}, ErrMissionTransition
+}
+
+// scanMission reads the missionColumns of a row.
+func scanMission(scan func(dest ...any) error) (Mission, error) {
+	var mission Mission
+	var maxDistance sql.NullInt64
+	err := scan(&mission.Id, &mission.EstateId, &mission.Status, &maxDistance,
+		&mission.PlotSizeMeters, &mission.ClearanceMeters, &mission.Distance,
+		&mission.LandingX, &mission.LandingY, &mission.TreesHash,
+		&mission.CreatedAt, &mission.StartedAt, &mission.CompletedAt, &mission.AbortedAt)
+	if err != nil {
+		return Mission{}, err
+	}
+	if maxDistance.Valid {
+		d := int(maxDistance.Int64)
+		mission.MaxDistance = &d
+	}
+	return mission, nil
+}
@@ -0,0 +1,286 @@
+// This file contains the transactional outbox of domain events and the
+// webhook subscriptions and deliveries fed from it.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/events"
+	"github.com/lib/pq"
+)
+
+// eventActions names the event published for each audit action.
+var eventActions = map[string]string{
+	AuditActionCreate:  "created",
+	AuditActionUpdate:  "updated",
+	AuditActionDelete:  "deleted",
+	AuditActionRestore: "restored",
+}
+
+// publish writes the event of an audited change to the outbox, queues a
+// delivery for every webhook subscribed to it and notifies event streams, in
+// the transaction of the change. Changes without an event, such as purges, are
+// skipped.
+func (r *Repository) publish(ctx context.Context, record auditRecord) error {
+	action, ok := eventActions[record.Action]
+	if !ok {
+		return nil
+	}
+	data := record.After
+	if data == nil {
+		data = record.Before
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	// The notification carries the estate id to the event streams of every
+	// instance once the transaction commits.
+	_, err = r.exec(ctx, "insert_outbox_event", `
+		WITH event AS (
+			INSERT INTO outbox_event (estate_id, type, data) VALUES ($1, $2, $3)
+			RETURNING id
+		), deliveries AS (
+			INSERT INTO webhook_delivery (subscription_id, event_id)
+			SELECT s.id, event.id
+			FROM webhook_subscription s, event
+			WHERE (s.estate_id IS NULL OR s.estate_id = $1)
+				AND (cardinality(s.event_types) = 0 OR $2 = ANY (s.event_types))
+		)
+		SELECT pg_notify($4, $1::text) FROM event
+	`, record.EstateId, record.Entity+"."+action, string(payload), events.Channel)
+	if err != nil {
+		return fmt.Errorf("writing outbox event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns up to limit events of an estate published after the
+// given position, in position order. Events of transactions at or after the
+// oldest open transaction are held back, as that one may still publish events
+// before them; pending reports whether any were.
+func (r *Repository) ListEvents(ctx context.Context, estateId string, after EventPosition, limit int) ([]Event, bool, error) {
+	ctx, end := r.instrument(ctx, "ListEvents")
+	defer end()
+	if !validIds(estateId) {
+		return nil, false, ErrEstateNotFound
+	}
+	rows, err := r.query(ctx, "select_events", `
+		SELECT id, type, estate_id, data, created_at, xid::text::bigint,
+			xid < pg_snapshot_xmin(pg_current_snapshot())
+		FROM outbox_event
+		WHERE estate_id = $1 AND (xid, id) > ($2::text::xid8, $3)
+		ORDER BY xid, id
+		LIMIT $4
+	`, estateId, after.Xid, after.Id, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var list []Event
+	for rows.Next() {
+		var event Event
+		var data []byte
+		var settled bool
+		err := rows.Scan(&event.Id, &event.Type, &event.EstateId, &data, &event.OccurredAt, &event.Position.Xid, &settled)
+		if err != nil {
+			return nil, false, err
+		}
+		if !settled {
+			// Every later event is of a transaction at least as new.
+			return list, true, rows.Err()
+		}
+		event.Data = json.RawMessage(data)
+		event.Position.Id = event.Id
+		list = append(list, event)
+	}
+	return list, false, rows.Err()
+}
+
+// LastEventPosition returns the position of the latest event of an estate
+// that ListEvents hands out, the zero position without events.
+func (r *Repository) LastEventPosition(ctx context.Context, estateId string) (EventPosition, error) {
+	ctx, end := r.instrument(ctx, "LastEventPosition")
+	defer end()
+	if !validIds(estateId) {
+		return EventPosition{}, ErrEstateNotFound
+	}
+	var position EventPosition
+	err := r.queryRow(ctx, "select_last_event_position", `
+		SELECT xid::text::bigint, id
+		FROM outbox_event
+		WHERE estate_id = $1 AND xid < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY xid DESC, id DESC
+		LIMIT 1
+	`, estateId).Scan(&position.Xid, &position.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EventPosition{}, nil
+	}
+	if err != nil {
+		return EventPosition{}, err
+	}
+	return position, nil
+}
+
+func (r *Repository) CreateWebhookSubscription(ctx context.Context, input WebhookSubscriptionRequest) (WebhookSubscription, error) {
+	ctx, end := r.instrument(ctx, "CreateWebhookSubscription")
+	defer end()
+	eventTypes := input.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	subscription := WebhookSubscription{
+		Url:        input.Url,
+		Secret:     input.Secret,
+		EventTypes: eventTypes,
+		EstateId:   input.EstateId,
+	}
+	err := r.writeRow(ctx, "insert_webhook_subscription", `
+		INSERT INTO webhook_subscription (url, secret, event_types, estate_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, input.Url, input.Secret, pq.Array(eventTypes), input.EstateId).Scan(&subscription.Id, &subscription.CreatedAt)
+	if err != nil {
+		return WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// ListWebhookSubscriptions returns every subscription, oldest first. Secrets
+// are left empty.
+func (r *Repository) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	ctx, end := r.instrument(ctx, "ListWebhookSubscriptions")
+	defer end()
+	rows, err := r.query(ctx, "select_webhook_subscriptions", `
+		SELECT id, url, event_types, estate_id, created_at
+		FROM webhook_subscription
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []WebhookSubscription{}
+	for rows.Next() {
+		var subscription WebhookSubscription
+		err := rows.Scan(&subscription.Id, &subscription.Url, pq.Array(&subscription.EventTypes),
+			&subscription.EstateId, &subscription.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// DeleteWebhookSubscription removes a subscription and its pending deliveries.
+func (r *Repository) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	ctx, end := r.instrument(ctx, "DeleteWebhookSubscription")
+	defer end()
+	if !validIds(id) {
+		return ErrWebhookNotFound
+	}
+	result, err := r.exec(ctx, "delete_webhook_subscription", "DELETE FROM webhook_subscription WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit deliveries that are due and
+// counts the attempt. Claimed deliveries are not handed out again for lease,
+// so several dispatchers can share the queue; a delivery whose dispatcher
+// crashed is retried once the lease runs out.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	ctx, end := r.instrument(ctx, "ClaimWebhookDeliveries")
+	defer end()
+	// Retrying the claim like a read is harmless: at worst a delivery is
+	// leased without being handed out and goes out after the lease.
+	rows, err := r.query(ctx, "claim_webhook_deliveries", `
+		WITH due AS (
+			SELECT id
+			FROM webhook_delivery
+			WHERE delivered_at IS NULL AND abandoned_at IS NULL AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_delivery d
+		SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+		FROM due, webhook_subscription s, outbox_event e
+		WHERE d.id = due.id AND s.id = d.subscription_id AND e.id = d.event_id
+		RETURNING d.id, s.url, s.secret, d.attempts, e.id, e.type, e.estate_id, e.data, e.created_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var data []byte
+		err := rows.Scan(&delivery.Id, &delivery.Url, &delivery.Secret, &delivery.Attempts,
+			&delivery.Event.Id, &delivery.Event.Type, &delivery.Event.EstateId, &data, &delivery.Event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Event.Data = json.RawMessage(data)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// CompleteWebhookDelivery records a successful delivery.
+func (r *Repository) CompleteWebhookDelivery(ctx context.Context, id int64) error {
+	ctx, end := r.instrument(ctx, "CompleteWebhookDelivery")
+	defer end()
+	_, err := r.exec(ctx, "complete_webhook_delivery",
+		"UPDATE webhook_delivery SET delivered_at = now(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+// RescheduleWebhookDelivery records a failed attempt and when to try again.
+func (r *Repository) RescheduleWebhookDelivery(ctx context.Context, id int64, next time.Time, lastError string) error {
+	ctx, end := r.instrument(ctx, "RescheduleWebhookDelivery")
+	defer end()
+	_, err := r.exec(ctx, "reschedule_webhook_delivery",
+		"UPDATE webhook_delivery SET next_attempt_at = $2, last_error = $3 WHERE id = $1", id, next, lastError)
+	return err
+}
+
+// AbandonWebhookDelivery records the last failed attempt of a delivery that
+// is not retried any more.
+func (r *Repository) AbandonWebhookDelivery(ctx context.Context, id int64, lastError string) error {
+	ctx, end := r.instrument(ctx, "AbandonWebhookDelivery")
+	defer end()
+	_, err := r.exec(ctx, "abandon_webhook_delivery",
+		"UPDATE webhook_delivery SET abandoned_at = now(), last_error = $2 WHERE id = $1", id, lastError)
+	return err
+}
+
+// DeleteEventsBefore removes events published before the given time together
+// with their deliveries.
+func (r *Repository) DeleteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, end := r.instrument(ctx, "DeleteEventsBefore")
+	defer end()
+	result, err := r.exec(ctx, "delete_outbox_events", "DELETE FROM outbox_event WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -2,26 +2,173 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/SawitProRecruitment/UserService/metrics"
+	"github.com/SawitProRecruitment/UserService/tracing"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Repository struct {
-	Db *sql.DB
+	Db      *sql.DB
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+
+	maxRetries int
+	// tx is set on the copy handed to WithTx callbacks; statements then run
+	// inside that transaction.
+	tx *sql.Tx
+}
+
+// querier is the part of *sql.DB and *sql.Tx the repository uses.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// conn returns the transaction the repository is bound to, or the pool.
+func (r *Repository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.Db
 }
 
 type NewRepositoryOptions struct {
 	Dsn string
+	// Logger defaults to slog.Default() when nil.
+	Logger *slog.Logger
+	// Metrics is optional; query durations are not recorded when nil.
+	Metrics *metrics.Metrics
+	// MaxOpenConns and MaxIdleConns size the connection pool, 0 keeps the
+	// database/sql defaults.
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime and ConnMaxIdleTime recycle connections, 0 keeps them
+	// forever.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// MaxRetries is how often a statement failing with a transient error is
+	// retried, 0 disables retries.
+	MaxRetries int
 }
 
-func NewRepository(opts NewRepositoryOptions) *Repository {
+// NewRepository prepares the connection pool. It does not connect; call
+// Connect to wait for the database.
+func NewRepository(opts NewRepositoryOptions) (*Repository, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 	db, err := sql.Open("postgres", opts.Dsn)
 	if err != nil {
-		log.Fatalf("Unable to open database: %v\n", err)
+		return nil, fmt.Errorf("opening database: %w", err)
 	}
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	opts.Metrics.RegisterDB(db, "estate")
 	return &Repository{
-		Db: db,
+		Db:         db,
+		Logger:     logger,
+		Metrics:    opts.Metrics,
+		maxRetries: opts.MaxRetries,
+	}, nil
+}
+
+var tracer = tracing.Tracer("repository")
+
+// instrument starts the span of a repository method. The returned function
+// records the method duration and ends the span:
+//
+//	ctx, end := r.instrument(ctx, "Method")
+//	defer end()
+func (r *Repository) instrument(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "repository."+method, trace.WithAttributes(semconv.DBSystemPostgreSQL))
+	return ctx, func() {
+		r.Metrics.ObserveRepository(method, time.Since(start))
+		span.End()
 	}
 }
+
+// startStatement starts a client span for a single SQL statement. The name
+// identifies the statement in traces without exposing its arguments.
+func startStatement(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func endStatement(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// queryRow runs a named read statement that returns at most one row.
+func (r *Repository) queryRow(ctx context.Context, name, query string, args ...any) *sql.Row {
+	return r.row(ctx, name, false, query, args...)
+}
+
+// writeRow runs a named statement that modifies data and returns at most one
+// row, such as INSERT ... RETURNING.
+func (r *Repository) writeRow(ctx context.Context, name, query string, args ...any) *sql.Row {
+	return r.row(ctx, name, true, query, args...)
+}
+
+func (r *Repository) row(ctx context.Context, name string, write bool, query string, args ...any) *sql.Row {
+	var row *sql.Row
+	_ = r.retry(ctx, name, write, func() error {
+		ctx, span := startStatement(ctx, name, query)
+		row = r.conn().QueryRowContext(ctx, query, args...)
+		endStatement(span, row.Err())
+		return row.Err()
+	})
+	return row
+}
+
+// query runs a named read statement that returns rows.
+func (r *Repository) query(ctx context.Context, name, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := r.retry(ctx, name, false, func() (err error) {
+		ctx, span := startStatement(ctx, name, query)
+		rows, err = r.conn().QueryContext(ctx, query, args...)
+		endStatement(span, err)
+		return err
+	})
+	return rows, err
+}
+
+// exec runs a named statement that modifies data and returns no rows.
+func (r *Repository) exec(ctx context.Context, name, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := r.retry(ctx, name, true, func() (err error) {
+		ctx, span := startStatement(ctx, name, query)
+		result, err = r.conn().ExecContext(ctx, query, args...)
+		endStatement(span, err)
+		return err
+	})
+	return result, err
+}
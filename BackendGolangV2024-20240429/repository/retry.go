@@ -0,0 +1,122 @@
+// This file contains the retry policy for transient database errors.
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	retryBaseDelay      = 50 * time.Millisecond
+	retryMaxDelay       = 2 * time.Second
+	connectBaseDelay    = 200 * time.Millisecond
+	connectMaxDelay     = 5 * time.Second
+	serializationFailed = "40001"
+	deadlockDetected    = "40P01"
+	tooManyConnections  = "53300"
+	adminShutdown       = "57P01"
+	cannotConnectNow    = "57P03"
+)
+
+// retryable reports whether err is worth retrying. Writes are only retried
+// when the error guarantees the statement did not commit: Postgres rolled it
+// back, or the connection was refused. Reads are also retried when the
+// connection broke mid-flight. driver.ErrBadConn counts as broken mid-flight,
+// since lib/pq also reports an EOF after the statement was sent with it;
+// database/sql already retries it on a fresh connection when nothing was sent.
+func retryable(err error, write bool) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return !write
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case serializationFailed, deadlockDetected, tooManyConnections, cannotConnectNow:
+			return true
+		case adminShutdown:
+			return !write
+		}
+		return !write && pqErr.Code.Class() == "08" // connection_exception
+	}
+	if write {
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// backoff returns the delay before the given retry attempt (starting at 1):
+// exponential growth capped at max, with full jitter.
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retry runs fn until it succeeds, fails with a permanent error or the retry
+// budget is used up. Statements inside a transaction run once: a failed
+// statement aborts the transaction, so WithTx retries it as a whole instead.
+func (r *Repository) retry(ctx context.Context, name string, write bool, fn func() error) error {
+	err := fn()
+	if r.tx != nil {
+		return err
+	}
+	for attempt := 1; attempt <= r.maxRetries && retryable(err, write); attempt++ {
+		if ctx.Err() != nil {
+			return err
+		}
+		delay := backoff(attempt, retryBaseDelay, retryMaxDelay)
+		r.Logger.WarnContext(ctx, "retrying statement", "statement", name, "attempt", attempt, "delay", delay, "error", err)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// Connect pings the database until it answers, backing off between attempts.
+// It gives up when ctx is done, so the caller decides how long startup may
+// wait for Postgres.
+func (r *Repository) Connect(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		err := r.Db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		delay := backoff(attempt, connectBaseDelay, connectMaxDelay)
+		r.Logger.WarnContext(ctx, "database not reachable yet", "attempt", attempt, "delay", delay, "error", err)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return errors.Join(errors.New("database not reachable"), err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		read  bool
+		write bool
+	}{
+		{"nil", nil, false, false},
+		{"bad connection", driver.ErrBadConn, true, false},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true, true},
+		{"serialization failure", &pq.Error{Code: "40001"}, true, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true, true},
+		{"connection exception", &pq.Error{Code: "08006"}, true, false},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true, false},
+		{"unexpected eof", io.ErrUnexpectedEOF, true, false},
+		{"unique violation", &pq.Error{Code: "23505"}, false, false},
+		{"other", errors.New("boom"), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.read, retryable(tc.err, false))
+			require.Equal(t, tc.write, retryable(tc.err, true))
+		})
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	for attempt := 1; attempt < 100; attempt++ {
+		d := backoff(attempt, 50*time.Millisecond, time.Second)
+		require.Greater(t, d, time.Duration(0))
+		require.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r := &Repository{Logger: slog.Default(), maxRetries: 2}
+
+	calls := 0
+	err := r.retry(context.Background(), "select", false, func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	for _, sent := range []error{io.ErrUnexpectedEOF, driver.ErrBadConn} {
+		calls = 0
+		err = r.retry(context.Background(), "insert", true, func() error {
+			calls++
+			return sent
+		})
+		require.ErrorIs(t, err, sent)
+		require.Equal(t, 1, calls, "a write that may have committed runs once")
+	}
+}
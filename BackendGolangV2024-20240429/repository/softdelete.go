@@ -0,0 +1,198 @@
+// This file contains restoring and purging of soft deleted estates and trees.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type withDeletedKey struct{}
+
+// WithDeleted returns a context whose reads (GetEstateById, GetTreeById,
+// GetTreesByEstateId and GetEstateStats) also return soft deleted rows.
+// Writes never see deleted rows.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+// notDeleted is the condition that hides deleted rows from the reads of ctx.
+func notDeleted(ctx context.Context) string {
+	if include, _ := ctx.Value(withDeletedKey{}).(bool); include {
+		return "TRUE"
+	}
+	return "deleted_at IS NULL"
+}
+
+// RestoreEstate undoes DeleteEstate, bringing back the trees that were deleted
+// with the estate but not those deleted on their own before.
+func (r *Repository) RestoreEstate(ctx context.Context, id string) (EstateData, error) {
+	ctx, end := r.instrument(ctx, "RestoreEstate")
+	defer end()
+	if !validIds(id) {
+		return EstateData{}, ErrEstateNotFound
+	}
+	var estate EstateData
+	err := r.inTx(ctx, func(tx *Repository) error {
+		before, err := scanEstate(tx.queryRow(ctx, "select_deleted_estate_for_update",
+			"SELECT "+estateColumns+" FROM estate WHERE id = $1 FOR UPDATE", id).Scan)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEstateNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return ErrNotDeleted
+		}
+
+		estate, err = scanEstate(tx.writeRow(ctx, "restore_estate",
+			"UPDATE estate SET deleted_at = NULL WHERE id = $1 RETURNING "+estateColumns, id).Scan)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, "restore_trees_by_estate", `
+			WITH restored AS (
+				UPDATE tree SET deleted_at = NULL
+				WHERE estateId = $1 AND deleted_at = $2
+				RETURNING `+treeColumns+`
+			)
+			INSERT INTO audit_log (estate_id, entity, entity_id, action, actor, request_id, after)
+			SELECT $3, $4, id, $5, $6, $7, `+treeAuditJSON+`
+			FROM restored
+		`, id, *before.DeletedAt, before.Id, AuditEntityTree, AuditActionRestore,
+			ActorFromContext(ctx), nullableRequestID(ctx))
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: estate.Id,
+			Entity:   AuditEntityEstate,
+			EntityId: estate.Id,
+			Action:   AuditActionRestore,
+			Before:   before,
+			After:    estate,
+		})
+	})
+	if err != nil {
+		return EstateData{}, err
+	}
+	return estate, nil
+}
+
+// RestoreTree undoes DeleteTree. The estate must not be deleted, the tree must
+// still fit into it and its plot must be free.
+func (r *Repository) RestoreTree(ctx context.Context, estateId, treeId string) (TreeData, error) {
+	ctx, end := r.instrument(ctx, "RestoreTree")
+	defer end()
+	if !validIds(estateId, treeId) {
+		return TreeData{}, ErrTreeNotFound
+	}
+	var tree TreeData
+	err := r.inTx(ctx, func(tx *Repository) error {
+		// Like ValidateTreeRequest, keep the estate from being resized or
+		// deleted until the tree is back.
+		var length, width int
+		err := tx.queryRow(ctx, "select_estate_bounds",
+			"SELECT length, width FROM estate WHERE id = $1 AND deleted_at IS NULL FOR SHARE", estateId).
+			Scan(&length, &width)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEstateNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		before, err := scanTree(tx.queryRow(ctx, "select_deleted_tree_for_update", `
+			SELECT `+treeColumns+`
+			FROM tree
+			WHERE id = $1 AND estateId = $2
+			FOR UPDATE
+		`, treeId, estateId).Scan)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTreeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return ErrNotDeleted
+		}
+		if before.X > length || before.Y > width {
+			return fmt.Errorf("x=%d y=%d in %dx%d: %w", before.X, before.Y, length, width, ErrTreeOutsideEstate)
+		}
+
+		tree, err = scanTree(tx.writeRow(ctx, "restore_tree", `
+			UPDATE tree SET deleted_at = NULL
+			WHERE id = $1
+			RETURNING `+treeColumns, treeId).Scan)
+		if isUniqueViolation(err) {
+			// A tree was planted on the plot after this one was deleted.
+			return fmt.Errorf("x=%d y=%d: %w", before.X, before.Y, ErrPlotOccupied)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, auditRecord{
+			EstateId: uuid.MustParse(tree.EstateId),
+			Entity:   AuditEntityTree,
+			EntityId: tree.Id,
+			Action:   AuditActionRestore,
+			Before:   before,
+			After:    tree,
+		})
+	})
+	if err != nil {
+		return TreeData{}, err
+	}
+	return tree, nil
+}
+
+// PurgeDeleted hard deletes the estates and trees deleted before the given
+// time and reports how many of each were removed. Trees of a purged estate
+// were deleted no later than the estate, so they go with it.
+func (r *Repository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (estates, trees int64, err error) {
+	ctx, end := r.instrument(ctx, "PurgeDeleted")
+	defer end()
+	err = r.inTx(ctx, func(tx *Repository) error {
+		result, err := tx.exec(ctx, "purge_trees", `
+			WITH purged AS (
+				DELETE FROM tree WHERE deleted_at < $1
+				RETURNING `+treeColumns+`
+			)
+			INSERT INTO audit_log (estate_id, entity, entity_id, action, actor, request_id, before)
+			SELECT estateId::uuid, $2, id, $3, $4, $5, `+treeAuditJSON+`
+			FROM purged
+		`, deletedBefore, AuditEntityTree, AuditActionPurge, ActorFromContext(ctx), nullableRequestID(ctx))
+		if err != nil {
+			return err
+		}
+		if trees, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		result, err = tx.exec(ctx, "purge_estates", `
+			WITH purged AS (
+				DELETE FROM estate WHERE deleted_at < $1
+				RETURNING id, length, width, version, deleted_at
+			)
+			INSERT INTO audit_log (estate_id, entity, entity_id, action, actor, request_id, before)
+			SELECT id, $2, id, $3, $4, $5, jsonb_build_object(
+				'id', id, 'length', length, 'width', width, 'version', version, 'deleted_at', deleted_at)
+			FROM purged
+		`, deletedBefore, AuditEntityEstate, AuditActionPurge, ActorFromContext(ctx), nullableRequestID(ctx))
+		if err != nil {
+			return err
+		}
+		estates, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("purging deleted rows: %w", err)
+	}
+	return estates, trees, nil
+}
@@ -0,0 +1,64 @@
+// This file contains the catalogue of tree species.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ListSpecies returns the catalogued species by name.
+func (r *Repository) ListSpecies(ctx context.Context) ([]Species, error) {
+	ctx, end := r.instrument(ctx, "ListSpecies")
+	defer end()
+	rows, err := r.query(ctx, "select_species", `SELECT name, max_height FROM species ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var species []Species
+	for rows.Next() {
+		var s Species
+		if err := rows.Scan(&s.Name, &s.MaxHeight); err != nil {
+			return nil, err
+		}
+		species = append(species, s)
+	}
+	return species, rows.Err()
+}
+
+// PutSpecies adds a species to the catalogue or changes its maximum height.
+// Existing trees are not checked against the new maximum.
+func (r *Repository) PutSpecies(ctx context.Context, species Species) (Species, error) {
+	ctx, end := r.instrument(ctx, "PutSpecies")
+	defer end()
+	_, err := r.exec(ctx, "upsert_species", `
+		INSERT INTO species (name, max_height) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET max_height = excluded.max_height
+	`, species.Name, species.MaxHeight)
+	if err != nil {
+		return Species{}, err
+	}
+	return species, nil
+}
+
+// maxHeight returns the height trees of a species may grow to, or
+// DefaultMaxHeight for an empty species.
+func (r *Repository) maxHeight(ctx context.Context, species string) (int, error) {
+	if species == "" {
+		return DefaultMaxHeight, nil
+	}
+	var maxHeight int
+	err := r.queryRow(ctx, "select_species_max_height", `
+		SELECT max_height FROM species WHERE name = $1
+	`, species).Scan(&maxHeight)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %q", ErrSpeciesNotFound, species)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checking species: %w", err)
+	}
+	return maxHeight, nil
+}
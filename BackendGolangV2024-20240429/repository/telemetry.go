@@ -0,0 +1,119 @@
+// This file contains the telemetry logged by drones during missions.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// InsertTelemetry stores the samples of a mission that has taken off and
+// returns how many were new. Samples for a time already stored are skipped.
+// The samples are copied in bulk rather than inserted one by one.
+func (r *Repository) InsertTelemetry(ctx context.Context, estateId, missionId string, samples []TelemetrySample) (int64, error) {
+	ctx, end := r.instrument(ctx, "InsertTelemetry")
+	defer end()
+	if !validIds(estateId, missionId) {
+		return 0, ErrMissionNotFound
+	}
+	var stored int64
+	err := r.inTx(ctx, func(tx *Repository) error {
+		var status string
+		err := tx.queryRow(ctx, "select_mission_status", `
+			SELECT mission.status
+			FROM mission JOIN estate ON estate.id = mission.estate_id
+			WHERE mission.id = $1 AND mission.estate_id = $2 AND estate.deleted_at IS NULL
+			FOR SHARE OF mission
+		`, missionId, estateId).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMissionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status == MissionPlanned {
+			return ErrMissionNotFlown
+		}
+
+		_, err = tx.exec(ctx, "create_telemetry_upload", `
+			CREATE TEMP TABLE telemetry_upload (
+				recorded_at TIMESTAMPTZ, x REAL, y REAL, altitude REAL, battery REAL
+			) ON COMMIT DROP
+		`)
+		if err != nil {
+			return err
+		}
+		if err := tx.copyTelemetry(ctx, samples); err != nil {
+			return err
+		}
+		result, err := tx.exec(ctx, "insert_telemetry", `
+			INSERT INTO mission_telemetry (mission_id, recorded_at, x, y, altitude, battery)
+			SELECT $1, recorded_at, x, y, altitude, battery FROM telemetry_upload
+			ON CONFLICT DO NOTHING
+		`, missionId)
+		if err != nil {
+			return err
+		}
+		stored, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
+
+// copyTelemetry copies samples into the telemetry_upload table of the
+// transaction r is bound to.
+func (r *Repository) copyTelemetry(ctx context.Context, samples []TelemetrySample) (err error) {
+	query := pq.CopyIn("telemetry_upload", "recorded_at", "x", "y", "altitude", "battery")
+	ctx, span := startStatement(ctx, "copy_telemetry_upload", query)
+	defer func() { endStatement(span, err) }()
+
+	stmt, err := r.tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, sample := range samples {
+		_, err := stmt.ExecContext(ctx, sample.Time, sample.X, sample.Y, sample.Altitude, sample.Battery)
+		if err != nil {
+			return err
+		}
+	}
+	// Executing without arguments flushes the copied rows.
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// ListTelemetry returns the samples of a mission in time order.
+func (r *Repository) ListTelemetry(ctx context.Context, estateId, missionId string) ([]TelemetrySample, error) {
+	ctx, end := r.instrument(ctx, "ListTelemetry")
+	defer end()
+	if !validIds(estateId, missionId) {
+		return nil, ErrMissionNotFound
+	}
+	rows, err := r.query(ctx, "select_telemetry", `
+		SELECT recorded_at, x, y, altitude, battery
+		FROM mission_telemetry
+		WHERE mission_id = $1
+		  AND EXISTS (SELECT 1 FROM mission WHERE id = $1 AND estate_id = $2)
+		ORDER BY recorded_at
+	`, missionId, estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []TelemetrySample{}
+	for rows.Next() {
+		var sample TelemetrySample
+		if err := rows.Scan(&sample.Time, &sample.X, &sample.Y, &sample.Altitude, &sample.Battery); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
@@ -0,0 +1,90 @@
+// This file contains the ground elevation models of estates.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PutTerrain stores the terrain of an estate, replacing any earlier one, and
+// returns it with its upload time.
+func (r *Repository) PutTerrain(ctx context.Context, estateId string, terrain Terrain) (Terrain, error) {
+	ctx, end := r.instrument(ctx, "PutTerrain")
+	defer end()
+	if !validIds(estateId) {
+		return Terrain{}, ErrEstateNotFound
+	}
+	err := r.writeRow(ctx, "upsert_estate_terrain", `
+		INSERT INTO estate_terrain (estate_id, ncols, nrows, x_lower_left, y_lower_left, cell_size, elevations)
+		SELECT id, $2, $3, $4, $5, $6, $7 FROM estate WHERE id = $1 AND deleted_at IS NULL
+		ON CONFLICT (estate_id) DO UPDATE SET
+			ncols = excluded.ncols,
+			nrows = excluded.nrows,
+			x_lower_left = excluded.x_lower_left,
+			y_lower_left = excluded.y_lower_left,
+			cell_size = excluded.cell_size,
+			elevations = excluded.elevations,
+			uploaded_at = now()
+		RETURNING uploaded_at
+	`, estateId, terrain.Columns, terrain.Rows, terrain.XLowerLeft, terrain.YLowerLeft, terrain.CellSize,
+		pq.Float64Array(terrain.Elevations)).Scan(&terrain.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Terrain{}, ErrEstateNotFound
+	}
+	if err != nil {
+		return Terrain{}, err
+	}
+	return terrain, nil
+}
+
+// GetTerrain returns the terrain of an estate.
+func (r *Repository) GetTerrain(ctx context.Context, estateId string) (Terrain, error) {
+	ctx, end := r.instrument(ctx, "GetTerrain")
+	defer end()
+	if !validIds(estateId) {
+		return Terrain{}, ErrTerrainNotFound
+	}
+	var terrain Terrain
+	var elevations pq.Float64Array
+	err := r.queryRow(ctx, "select_estate_terrain", `
+		SELECT t.ncols, t.nrows, t.x_lower_left, t.y_lower_left, t.cell_size, t.elevations, t.uploaded_at
+		FROM estate_terrain t JOIN estate ON estate.id = t.estate_id
+		WHERE t.estate_id = $1 AND estate.deleted_at IS NULL
+	`, estateId).Scan(&terrain.Columns, &terrain.Rows, &terrain.XLowerLeft, &terrain.YLowerLeft,
+		&terrain.CellSize, &elevations, &terrain.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Terrain{}, ErrTerrainNotFound
+	}
+	if err != nil {
+		return Terrain{}, err
+	}
+	terrain.Elevations = elevations
+	return terrain, nil
+}
+
+// DeleteTerrain removes the terrain of an estate, which is flat again.
+func (r *Repository) DeleteTerrain(ctx context.Context, estateId string) error {
+	ctx, end := r.instrument(ctx, "DeleteTerrain")
+	defer end()
+	if !validIds(estateId) {
+		return ErrTerrainNotFound
+	}
+	result, err := r.exec(ctx, "delete_estate_terrain", `
+		DELETE FROM estate_terrain t USING estate
+		WHERE t.estate_id = $1 AND estate.id = t.estate_id AND estate.deleted_at IS NULL
+	`, estateId)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrTerrainNotFound
+	}
+	return nil
+}
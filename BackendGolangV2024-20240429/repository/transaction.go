@@ -0,0 +1,66 @@
+// This file contains the unit of work used by validate-then-write flows.
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithTx runs fn inside a database transaction. The repository passed to fn
+// is bound to the transaction, so everything it reads and writes commits or
+// rolls back together; fn returning an error rolls back. Methods that lock
+// rows (ValidateTreeRequest, LockEstate) hold the locks until the end of the
+// transaction.
+//
+// A transaction that fails with a serialization failure or deadlock is rolled
+// back by Postgres and retried from the start, so fn must not have side
+// effects outside the repository. Calling WithTx on a repository that is
+// already bound to a transaction runs fn in that transaction.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	ctx, end := r.instrument(ctx, "WithTx")
+	defer end()
+	return r.inTx(ctx, func(tx *Repository) error {
+		return fn(tx)
+	})
+}
+
+// inTx runs fn in the transaction r is bound to, or in a new one that is
+// retried like WithTx. Mutations use it to write their audit entry atomically
+// whether or not the caller opened a transaction.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return r.retry(ctx, "transaction", true, func() error {
+		return r.runTx(ctx, fn)
+	})
+}
+
+func (r *Repository) runTx(ctx context.Context, fn func(tx *Repository) error) error {
+	ctx, span := startStatement(ctx, "transaction", "BEGIN")
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		endStatement(span, err)
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback()
+
+	bound := &Repository{
+		Db:         r.Db,
+		Logger:     r.Logger,
+		Metrics:    r.Metrics,
+		maxRetries: r.maxRetries,
+		tx:         tx,
+	}
+	if err := fn(bound); err != nil {
+		endStatement(span, err)
+		return err
+	}
+	err = tx.Commit()
+	endStatement(span, err)
+	return err
+}
@@ -1,7 +1,105 @@
 // This file contains types that are used in the repository layer.
 package repository
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the database.sql version this build requires. Bump it
+// together with the schema_migrations row inserted by database.sql.
+const SchemaVersion = 17
+
+// ErrEstateNotFound is returned when the requested estate does not exist.
+var ErrEstateNotFound = errors.New("estate not found")
+
+// ErrTreeNotFound is returned when the requested tree does not exist in the
+// estate.
+var ErrTreeNotFound = errors.New("tree not found")
+
+// ErrVersionMismatch is returned when a row was changed by someone else since
+// the version the caller based its change on.
+var ErrVersionMismatch = errors.New("version does not match")
+
+// AnyVersion skips the version check of an update or delete.
+const AnyVersion = 0
+
+// ErrPlotOccupied is returned when a tree is planted on a plot that already
+// has one.
+var ErrPlotOccupied = errors.New("plot already has a tree")
+
+// ErrWebhookNotFound is returned when the requested webhook subscription does
+// not exist.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrMissionNotFound is returned when the requested mission does not exist in
+// the estate.
+var ErrMissionNotFound = errors.New("mission not found")
+
+// ErrMissionTransition is returned when a mission can not move from its
+// current status to the requested one.
+var ErrMissionTransition = errors.New("invalid mission status transition")
+
+// ErrMissionNotFlown is returned when telemetry is uploaded for a mission that
+// has not taken off.
+var ErrMissionNotFlown = errors.New("mission has not taken off")
+
+// ErrTerrainNotFound is returned when an estate has no terrain model.
+var ErrTerrainNotFound = errors.New("terrain not found")
+
+// ErrDivisionNotFound is returned when the requested division does not exist
+// in the estate.
+var ErrDivisionNotFound = errors.New("division not found")
+
+// ErrDivisionExists is returned when the estate already has a division of the
+// same name.
+var ErrDivisionExists = errors.New("division already exists")
+
+// ErrDivisionNotEmpty is returned when deleting a division that still has
+// blocks.
+var ErrDivisionNotEmpty = errors.New("division still has blocks")
+
+// ErrBlockNotFound is returned when the requested block does not exist in the
+// estate.
+var ErrBlockNotFound = errors.New("block not found")
+
+// ValidationError is returned by the Validate methods when the input is
+// invalid, as opposed to errors of the database, which are returned as is.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalidInput marks err as a ValidationError.
+func invalidInput(err error) error {
+	return &ValidationError{Err: err}
+}
+
+// ErrSpeciesNotFound is returned when the requested species is not in the
+// catalogue.
+var ErrSpeciesNotFound = errors.New("species not found")
+
+// ErrNotDeleted is returned when restoring an estate or tree that is not
+// deleted.
+var ErrNotDeleted = errors.New("not deleted")
+
+// ErrTreeOutsideEstate is returned when restoring a tree that no longer fits
+// into its resized estate.
+var ErrTreeOutsideEstate = errors.New("tree stands outside the estate")
 
 type GetTestByIdInput struct {
 	Id string
@@ -14,6 +112,9 @@ type GetTestByIdOutput struct {
 type EstateRequest struct {
 	Length int
 	Width  int
+	// GeoReference places the estate on the globe. UpdateEstate keeps the
+	// current one when it is nil.
+	GeoReference *geo.Reference
 }
 
 type EstateResponse struct {
@@ -25,6 +126,36 @@ type TreeRequest struct {
 	Height   int
 	X        int
 	Y        int
+	// Species and Variety are empty when unknown; Species must be in the
+	// catalogue.
+	Species   string
+	Variety   string
+	PlantedOn *time.Time
+	// Health defaults to HealthHealthy.
+	Health     string
+	Attributes map[string]any
+}
+
+// Tree health statuses.
+const (
+	HealthHealthy  = "healthy"
+	HealthDiseased = "diseased"
+	HealthDead     = "dead"
+)
+
+// DefaultMaxHeight is the height trees without a species may grow to.
+const DefaultMaxHeight = 30
+
+// maxVarietyLength matches the variety column.
+const maxVarietyLength = 100
+
+// maxAttributesBytes caps the free-form attributes of a tree as JSON.
+const maxAttributesBytes = 4096
+
+// Species is a catalogued tree species and the height its trees may grow to.
+type Species struct {
+	Name      string `json:"name"`
+	MaxHeight int    `json:"max_height"`
 }
 
 type TreeResponse struct {
@@ -37,6 +168,21 @@ type EstateStats struct {
 	Median    float64 `json:"median"`
 }
 
+// Columns estate statistics can be grouped by.
+const (
+	StatsGroupSpecies = "species"
+	StatsGroupHealth  = "health"
+)
+
+// EstateStatsGroup holds the statistics of the trees sharing a species and
+// health. Fields not grouped by are empty, as is the species of trees without
+// one.
+type EstateStatsGroup struct {
+	Species string
+	Health  string
+	EstateStats
+}
+
 type Tree struct {
 	X      int
 	Y      int
@@ -44,7 +190,462 @@ type Tree struct {
 }
 
 type EstateData struct {
-	Id     uuid.UUID
-	Length int
-	Width  int
+	Id      uuid.UUID `json:"id"`
+	Length  int       `json:"length"`
+	Width   int       `json:"width"`
+	Version int       `json:"version"`
+	// GeoReference is nil for estates that are not placed on the globe.
+	GeoReference *geo.Reference `json:"geo_reference,omitempty"`
+	// DeletedAt is set on deleted estates, which are only returned to
+	// contexts from WithDeleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type TreeData struct {
+	Id         uuid.UUID      `json:"id"`
+	EstateId   string         `json:"estate_id"`
+	X          int            `json:"x"`
+	Y          int            `json:"y"`
+	Height     int            `json:"height"`
+	Species    string         `json:"species,omitempty"`
+	Variety    string         `json:"variety,omitempty"`
+	PlantedOn  *time.Time     `json:"planted_on,omitempty"`
+	Health     string         `json:"health"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Version    int            `json:"version"`
+	DeletedAt  *time.Time     `json:"deleted_at,omitempty"`
+}
+
+// TreeUpdate changes the fields that are not nil. Empty Species and Variety
+// clear them, and Attributes replace the current ones.
+type TreeUpdate struct {
+	EstateId   string
+	Id         string
+	Height     *int
+	Species    *string
+	Variety    *string
+	PlantedOn  *time.Time
+	Health     *string
+	Attributes map[string]any
+	// Version is the version the update is based on, or AnyVersion.
+	Version int
+}
+
+type IdempotencyKeyRequest struct {
+	Key         string
+	RequestHash string
+	// TTL is how long the key and its response are kept.
+	TTL time.Duration
+	// LockTimeout is how long a claimed key without a response blocks retries
+	// before it is considered abandoned, e.g. after a crash.
+	LockTimeout time.Duration
+}
+
+type IdempotencyKey struct {
+	// Claimed is true when the caller now owns the key and must run the
+	// request. Otherwise the fields below describe the earlier request.
+	Claimed     bool
+	RequestHash string
+	// StatusCode is 0 while the request holding the key is still running.
+	StatusCode  int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+}
+
+type IdempotentResponse struct {
+	Key         string
+	StatusCode  int
+	ContentType string
+	// Headers are the response headers replayed besides the content type.
+	Headers map[string]string
+	Body    []byte
+}
+
+// Audited entities and actions.
+const (
+	AuditEntityEstate = "estate"
+	AuditEntityTree   = "tree"
+
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// AuditActionRestore undoes a delete; AuditActionPurge removes a deleted
+	// row for good after the retention period.
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+type AuditQuery struct {
+	EstateId string
+	// From and To limit the entries to [From, To) when set.
+	From *time.Time
+	To   *time.Time
+	// BeforeId continues a listing below the last id of the previous page.
+	BeforeId int64
+	Limit    int
+}
+
+type AuditEntry struct {
+	Id        int64
+	EstateId  uuid.UUID
+	Entity    string
+	EntityId  uuid.UUID
+	Action    string
+	Actor     string
+	RequestId string
+	// Before and After are the entity as JSON; Before is nil for creates and
+	// After for deletes.
+	Before    json.RawMessage
+	After     json.RawMessage
+	CreatedAt time.Time
+}
+
+// Event types published for audited changes, "<entity>.<action>".
+const (
+	EventEstateCreated  = "estate.created"
+	EventEstateUpdated  = "estate.updated"
+	EventEstateDeleted  = "estate.deleted"
+	EventEstateRestored = "estate.restored"
+	EventTreeCreated    = "tree.created"
+	EventTreeUpdated    = "tree.updated"
+	EventTreeDeleted    = "tree.deleted"
+	EventTreeRestored   = "tree.restored"
+)
+
+// EventTypes lists every event type that can be subscribed to.
+var EventTypes = []string{
+	EventEstateCreated, EventEstateUpdated, EventEstateDeleted, EventEstateRestored,
+	EventTreeCreated, EventTreeUpdated, EventTreeDeleted, EventTreeRestored,
+}
+
+type Event struct {
+	Id       int64     `json:"id"`
+	Type     string    `json:"type"`
+	EstateId uuid.UUID `json:"estate_id"`
+	// Data is the estate or tree after the change, or before it for deletes.
+	Data       json.RawMessage `json:"data"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	// Position orders the events of event streams.
+	Position EventPosition `json:"-"`
+}
+
+// EventPosition is the place of an event in commit order: the transaction
+// that published it, then its id. Ids alone are handed out before commit, so
+// an event may become visible after events with higher ids.
+type EventPosition struct {
+	Xid int64
+	Id  int64
+}
+
+// String formats the position as "<xid>-<id>", the id of server-sent events.
+func (p EventPosition) String() string {
+	return fmt.Sprintf("%d-%d", p.Xid, p.Id)
+}
+
+// ParseEventPosition parses a position formatted by String.
+func ParseEventPosition(s string) (EventPosition, error) {
+	xid, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return EventPosition{}, errors.New("invalid event position")
+	}
+	var p EventPosition
+	var err error
+	if p.Xid, err = strconv.ParseInt(xid, 10, 64); err != nil || p.Xid < 0 {
+		return EventPosition{}, errors.New("invalid event position")
+	}
+	if p.Id, err = strconv.ParseInt(id, 10, 64); err != nil || p.Id < 0 {
+		return EventPosition{}, errors.New("invalid event position")
+	}
+	return p, nil
+}
+
+type WebhookSubscriptionRequest struct {
+	Url    string
+	Secret string
+	// EventTypes and EstateId narrow the events delivered; empty and nil
+	// match every event.
+	EventTypes []string
+	EstateId   *uuid.UUID
+}
+
+type WebhookSubscription struct {
+	Id         uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	EstateId   *uuid.UUID
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is an event due to be sent to a subscription.
+type WebhookDelivery struct {
+	Id     int64
+	Url    string
+	Secret string
+	// Attempts counts the attempts including the current one.
+	Attempts int
+	Event    Event
+}
+
+// Mission statuses. Missions start planned, go in flight and end completed or
+// aborted; planned missions can also be aborted before take-off.
+const (
+	MissionPlanned   = "planned"
+	MissionInFlight  = "in_flight"
+	MissionCompleted = "completed"
+	MissionAborted   = "aborted"
+)
+
+// Waypoint is a point of a flight path: a plot and the altitude above ground
+// the drone holds over it, in meters.
+type Waypoint struct {
+	X        int `json:"x"`
+	Y        int `json:"y"`
+	Altitude int `json:"altitude"`
+}
+
+type MissionRequest struct {
+	EstateId string
+	// MaxDistance is the battery range the mission was planned with, or nil
+	// when it covers the whole estate.
+	MaxDistance     *int
+	PlotSizeMeters  float64
+	ClearanceMeters int
+	Distance        int
+	LandingX        int
+	LandingY        int
+	TreesHash       string
+	Path            []Waypoint
+}
+
+type Mission struct {
+	Id              uuid.UUID
+	EstateId        uuid.UUID
+	Status          string
+	MaxDistance     *int
+	PlotSizeMeters  float64
+	ClearanceMeters int
+	Distance        int
+	LandingX        int
+	LandingY        int
+	TreesHash       string
+	// Path is nil in listings.
+	Path        []Waypoint
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	AbortedAt   *time.Time
+}
+
+type MissionQuery struct {
+	EstateId string
+	// Status only lists missions in this status when set.
+	Status *string
+	// After continues a listing below the last mission of the previous page.
+	After *MissionCursor
+	Limit int
+}
+
+// MissionCursor is the position of a mission in a listing, newest first.
+type MissionCursor struct {
+	CreatedAt time.Time
+	Id        uuid.UUID
+}
+
+// TelemetrySample is a position logged by the drone during a mission. X and Y
+// are in plots and may be fractional between plot centres.
+type TelemetrySample struct {
+	Time     time.Time
+	X        float64
+	Y        float64
+	Altitude float64
+	// Battery is the charge level in percent, if logged.
+	Battery *float64
+}
+
+// Terrain is the ground elevation grid of an estate. Positions are
+// estate-local meters from the centre of plot (1,1), x along the x axis of the
+// plots and y along the y axis.
+type Terrain struct {
+	Columns int
+	Rows    int
+	// XLowerLeft and YLowerLeft are the outer corner of the lower left cell.
+	XLowerLeft float64
+	YLowerLeft float64
+	CellSize   float64
+	// Elevations are in meters, row by row from the top row, NaN where the
+	// grid has no data.
+	Elevations []float64
+	UploadedAt time.Time
+}
+
+// Harvest is a cut of fresh fruit bunches from a tree.
+type Harvest struct {
+	Id          uuid.UUID
+	EstateId    uuid.UUID
+	TreeId      uuid.UUID
+	HarvestedOn time.Time
+	Bunches     int
+	WeightKg    float64
+	// Harvester is empty when not recorded.
+	Harvester string
+	CreatedAt time.Time
+}
+
+type HarvestRequest struct {
+	EstateId    string
+	TreeId      string
+	HarvestedOn time.Time
+	Bunches     int
+	WeightKg    float64
+	Harvester   string
+}
+
+// Yield periods, the granularity GetEstateYield groups harvest dates by.
+const (
+	YieldDay   = "day"
+	YieldWeek  = "week"
+	YieldMonth = "month"
+	YieldYear  = "year"
+)
+
+// Columns yield can be grouped by.
+const (
+	YieldGroupPeriod = "period"
+	YieldGroupRow    = "row"
+	YieldGroupTree   = "tree"
+)
+
+type YieldQuery struct {
+	EstateId string
+	// GroupBy lists YieldGroupPeriod, YieldGroupRow and YieldGroupTree; no
+	// columns total the whole estate.
+	GroupBy []string
+	// Period is the length of the periods, YieldMonth by default.
+	Period string
+	// From and To bound the harvest dates, both inclusive, when set.
+	From *time.Time
+	To   *time.Time
+}
+
+// YieldGroup totals the harvests of a group. Only the fields grouped by are
+// set.
+type YieldGroup struct {
+	// PeriodStart is the first day of the period.
+	PeriodStart *time.Time
+	// Row is the y of the trees.
+	Row      *int
+	TreeId   *uuid.UUID
+	X        *int
+	Harvests int
+	Bunches  int
+	WeightKg float64
+}
+
+// YieldStats relates the yield of the harvested trees of an estate to their
+// height.
+type YieldStats struct {
+	HarvestedTrees int
+	WeightKg       float64
+	// Correlation is the Pearson correlation of the height of a tree and the
+	// weight harvested from it, nil with fewer than two distinct heights.
+	Correlation *float64
+	ByHeight    []HeightYield
+}
+
+// HeightYield is the yield of the harvested trees of one height.
+type HeightYield struct {
+	Height int
+	Trees  int
+	// WeightKg is the mean weight harvested per tree.
+	WeightKg float64
+}
+
+type Division struct {
+	Id        uuid.UUID
+	EstateId  uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+// BlockMaskIn and BlockMaskOut mark the plots of a block mask.
+const (
+	BlockMaskIn  = '#'
+	BlockMaskOut = '.'
+)
+
+// maxBlockMaskPlots caps the plots a block mask covers.
+const maxBlockMaskPlots = 1 << 20
+
+type BlockRequest struct {
+	EstateId string
+	// Id is set when updating a block, which it is not checked against.
+	Id         string
+	DivisionId *string
+	Name       string
+	XMin       int
+	YMin       int
+	XMax       int
+	YMax       int
+	// Mask is nil for rectangular blocks.
+	Mask []string
+}
+
+// Block is a named range of plots of an estate: the rectangle from (XMin,
+// YMin) to (XMax, YMax), or the plots of it marked BlockMaskIn in Mask, one
+// row per y from YMin.
+type Block struct {
+	Id         uuid.UUID
+	EstateId   uuid.UUID
+	DivisionId *uuid.UUID
+	Name       string
+	XMin       int
+	YMin       int
+	XMax       int
+	YMax       int
+	Mask       []string
+	Version    int
+	CreatedAt  time.Time
+}
+
+// Contains reports whether plot (x, y) belongs to the block.
+func (b Block) Contains(x, y int) bool {
+	if x < b.XMin || x > b.XMax || y < b.YMin || y > b.YMax {
+		return false
+	}
+	return b.Mask == nil || b.Mask[y-b.YMin][x-b.XMin] == BlockMaskIn
+}
+
+// Overlaps reports whether the blocks share a plot. Only the plots of the
+// shared rectangle are visited, and only when a block is masked.
+func (b Block) Overlaps(other Block) bool {
+	xMin, xMax := max(b.XMin, other.XMin), min(b.XMax, other.XMax)
+	yMin, yMax := max(b.YMin, other.YMin), min(b.YMax, other.YMax)
+	if xMin > xMax || yMin > yMax {
+		return false
+	}
+	if b.Mask == nil && other.Mask == nil {
+		return true
+	}
+	for y := yMin; y <= yMax; y++ {
+		for x := xMin; x <= xMax; x++ {
+			if b.Contains(x, y) && other.Contains(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Plots counts the plots of the block.
+func (b Block) Plots() int {
+	if b.Mask == nil {
+		return (b.XMax - b.XMin + 1) * (b.YMax - b.YMin + 1)
+	}
+	plots := 0
+	for _, row := range b.Mask {
+		plots += strings.Count(row, string(BlockMaskIn))
+	}
+	return plots
 }
@@ -2,7 +2,6 @@
 make init                      # Initializes 
 make                           # Builds the binary
 make test                      # Runs unit tests with coverage 
-docker compose up --build -d   # Runs the docker to start the API and database.
-sleep 30                       # Wait until API runs
+docker compose up --build -d --wait  # Starts the API and database, waits until /readyz passes.
 make test_api                  # Runs the API testing with data.
 docker compose down --volumes  # Stops the docker containers and removes the volumes.
\ No newline at end of file
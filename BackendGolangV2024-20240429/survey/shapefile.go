@@ -0,0 +1,218 @@
+package survey
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+)
+
+// zipSignature opens the local file headers of zip archives.
+const zipSignature = "PK\x03\x04"
+
+// maxArchiveBytes caps zipped shapefiles, which are read into memory.
+const maxArchiveBytes = 64 << 20
+
+// Shape types of points; the Z and M variants add fields after X and Y.
+const (
+	shapeNull   = 0
+	shapePoint  = 1
+	shapePointZ = 11
+	shapePointM = 21
+)
+
+// readShapefile reads the points of the .shp file of an archive and their
+// attributes from the .dbf file beside it.
+func readShapefile(r io.Reader, heightAttribute string, limit int) ([]Feature, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveBytes {
+		return nil, fmt.Errorf("the archive is larger than %d MiB", maxArchiveBytes>>20)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	files := map[string]*zip.File{}
+	var base string
+	for _, file := range archive.File {
+		name := strings.ToLower(file.Name)
+		extension := path.Ext(name)
+		files[name] = file
+		if extension == ".shp" {
+			if base != "" {
+				return nil, errors.New("the archive holds more than one shapefile")
+			}
+			base = strings.TrimSuffix(name, extension)
+		}
+	}
+	if base == "" {
+		return nil, errors.New("the archive holds no .shp file")
+	}
+	if files[base+".dbf"] == nil {
+		return nil, errors.New("the shapefile has no .dbf file with its attributes")
+	}
+	if prj := files[base+".prj"]; prj != nil {
+		projection, err := readZipped(prj)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(strings.TrimSpace(string(projection)), "GEOGCS") {
+			return nil, errors.New("the shapefile is projected, export it in WGS84 longitude and latitude")
+		}
+	}
+
+	shp, err := readZipped(files[base+".shp"])
+	if err != nil {
+		return nil, err
+	}
+	features, err := shapePoints(shp, limit)
+	if err != nil {
+		return nil, err
+	}
+	dbf, err := readZipped(files[base+".dbf"])
+	if err != nil {
+		return nil, err
+	}
+	heights, err := dbfColumn(dbf, heightAttribute)
+	if err != nil {
+		return nil, err
+	}
+	if len(heights) != len(features) {
+		return nil, fmt.Errorf("the .shp file has %d records and the .dbf file %d", len(features), len(heights))
+	}
+
+	live := features[:0]
+	for i, feature := range features {
+		value := heights[i]
+		if value == nil {
+			continue // deleted in the .dbf file
+		}
+		if feature.Err == nil {
+			feature.Height, feature.Err = height(*value, heightAttribute)
+		}
+		live = append(live, feature)
+	}
+	return live, nil
+}
+
+func readZipped(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxArchiveBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name, err)
+	}
+	if len(data) > maxArchiveBytes {
+		return nil, fmt.Errorf("%s is larger than %d MiB", file.Name, maxArchiveBytes>>20)
+	}
+	return data, nil
+}
+
+// shapePoints reads the records of a .shp file. Its header and record
+// headers are big-endian, the shapes little-endian.
+func shapePoints(shp []byte, limit int) ([]Feature, error) {
+	if len(shp) < 100 || binary.BigEndian.Uint32(shp) != 9994 {
+		return nil, errors.New("the .shp file is malformed")
+	}
+	switch shapeType := binary.LittleEndian.Uint32(shp[32:]); shapeType {
+	case shapePoint, shapePointZ, shapePointM:
+	default:
+		return nil, fmt.Errorf("the shapefile holds shapes of type %d, not points", shapeType)
+	}
+
+	var features []Feature
+	for offset := 100; offset < len(shp); {
+		index := len(features)
+		if offset+12 > len(shp) {
+			return nil, fmt.Errorf("feature %d: the .shp file ends in its record", index)
+		}
+		length := 2 * int(binary.BigEndian.Uint32(shp[offset+4:]))
+		content := shp[offset+8:]
+		if length < 4 || length > len(content) {
+			return nil, fmt.Errorf("feature %d: the .shp file ends in its record", index)
+		}
+		content, offset = content[:length], offset+8+length
+		if index == limit {
+			return nil, fmt.Errorf("%w: at most %d per upload", ErrTooManyFeatures, limit)
+		}
+
+		feature := Feature{Index: index}
+		switch binary.LittleEndian.Uint32(content) {
+		case shapeNull:
+			feature.Err = errors.New("no location")
+		case shapePoint, shapePointZ, shapePointM:
+			if length < 20 {
+				return nil, fmt.Errorf("feature %d: the point is incomplete", index)
+			}
+			feature.Longitude = math.Float64frombits(binary.LittleEndian.Uint64(content[4:]))
+			feature.Latitude = math.Float64frombits(binary.LittleEndian.Uint64(content[12:]))
+		default:
+			feature.Err = errors.New("not a point")
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// dbfColumn reads a column of every record of a dBASE file, matching its
+// name without regard to case. Deleted records are nil.
+func dbfColumn(dbf []byte, name string) ([]*string, error) {
+	if len(dbf) < 32 {
+		return nil, errors.New("the .dbf file is malformed")
+	}
+	records := int(binary.LittleEndian.Uint32(dbf[4:]))
+	headerSize := int(binary.LittleEndian.Uint16(dbf[8:]))
+	recordSize := int(binary.LittleEndian.Uint16(dbf[10:]))
+	if headerSize > len(dbf) || recordSize < 1 || records > (len(dbf)-headerSize)/recordSize {
+		return nil, errors.New("the .dbf file is malformed")
+	}
+
+	// Field descriptors follow the header, up to a 0x0D terminator; record
+	// fields follow a deletion flag.
+	found, start, width := false, 0, 0
+	for offset, fieldStart := 32, 1; offset+32 <= headerSize && dbf[offset] != 0x0d; offset += 32 {
+		fieldWidth := int(dbf[offset+16])
+		if strings.EqualFold(trimField(dbf[offset:offset+11]), name) {
+			found, start, width = true, fieldStart, fieldWidth
+		}
+		fieldStart += fieldWidth
+	}
+	if !found {
+		return nil, fmt.Errorf("the .dbf file has no %s attribute", name)
+	}
+	if start+width > recordSize {
+		return nil, errors.New("the .dbf file is malformed")
+	}
+
+	values := make([]*string, records)
+	for i := range values {
+		record := dbf[headerSize+i*recordSize:][:recordSize]
+		if record[0] == '*' {
+			continue
+		}
+		value := trimField(record[start : start+width])
+		values[i] = &value
+	}
+	return values, nil
+}
+
+// trimField returns a fixed width text field without its padding, which is
+// spaces or a NUL terminator.
+func trimField(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	return string(bytes.TrimSpace(field))
+}
@@ -0,0 +1,144 @@
+// Package survey reads tree locations delivered by GIS teams as GeoJSON or
+// zipped shapefiles.
+package survey
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrTooManyFeatures is returned when a survey holds more features than
+// allowed.
+var ErrTooManyFeatures = errors.New("too many features")
+
+// DefaultHeightAttribute names the attribute holding tree heights.
+const DefaultHeightAttribute = "height"
+
+// Feature is a surveyed tree. Features that cannot be placed, or have no
+// height, carry the reason in Err.
+type Feature struct {
+	// Index is the position of the feature in the file, from 0.
+	Index     int
+	Latitude  float64
+	Longitude float64
+	// Height is in whole meters.
+	Height int
+	Err    error
+}
+
+// Read reads at most limit features of a GeoJSON file or a zip archive of a
+// shapefile in WGS84. Heights are read from heightAttribute, matched without
+// regard to case, and rounded to whole meters. Errors name the offending
+// feature.
+func Read(r io.Reader, heightAttribute string, limit int) ([]Feature, error) {
+	reader := bufio.NewReader(r)
+	signature, err := reader.Peek(len(zipSignature))
+	if err == nil && string(signature) == zipSignature {
+		return readShapefile(reader, heightAttribute, limit)
+	}
+	return readGeoJSON(reader, heightAttribute, limit)
+}
+
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// maxGeoJSONBytes caps GeoJSON files, which are decoded in memory.
+const maxGeoJSONBytes = 64 << 20
+
+// readGeoJSON reads the point features of a FeatureCollection or of a single
+// Feature.
+func readGeoJSON(r io.Reader, heightAttribute string, limit int) ([]Feature, error) {
+	var document struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+		geoJSONFeature
+	}
+	limited := &io.LimitedReader{R: r, N: maxGeoJSONBytes + 1}
+	if err := json.NewDecoder(limited).Decode(&document); err != nil {
+		if limited.N <= 0 {
+			return nil, fmt.Errorf("the GeoJSON file is larger than %d MiB", maxGeoJSONBytes>>20)
+		}
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	var features []geoJSONFeature
+	switch document.Type {
+	case "FeatureCollection":
+		features = document.Features
+	case "Feature":
+		features = []geoJSONFeature{document.geoJSONFeature}
+	default:
+		return nil, errors.New("GeoJSON must be a FeatureCollection or a Feature")
+	}
+	if len(features) > limit {
+		return nil, fmt.Errorf("%w: at most %d per upload", ErrTooManyFeatures, limit)
+	}
+
+	result := make([]Feature, len(features))
+	for i, f := range features {
+		result[i] = Feature{Index: i}
+		if f.Geometry == nil || f.Geometry.Type != "Point" {
+			result[i].Err = errors.New("not a point")
+			continue
+		}
+		var position []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &position); err != nil || len(position) < 2 {
+			result[i].Err = errors.New("invalid coordinates")
+			continue
+		}
+		// GeoJSON positions are longitude first.
+		result[i].Longitude, result[i].Latitude = position[0], position[1]
+		result[i].Height, result[i].Err = height(property(f.Properties, heightAttribute), heightAttribute)
+	}
+	return result, nil
+}
+
+// property returns the value of the first attribute named like name.
+func property(properties map[string]any, name string) any {
+	if value, ok := properties[name]; ok {
+		return value
+	}
+	for key, value := range properties {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// height reads a height attribute, given as a number or a numeric string.
+func height(value any, attribute string) (int, error) {
+	var meters float64
+	switch v := value.(type) {
+	case float64:
+		meters = v
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			if strings.TrimSpace(v) == "" {
+				return 0, fmt.Errorf("no %s", attribute)
+			}
+			return 0, fmt.Errorf("invalid %s %q", attribute, v)
+		}
+		meters = parsed
+	case nil:
+		return 0, fmt.Errorf("no %s", attribute)
+	default:
+		return 0, fmt.Errorf("invalid %s %v", attribute, v)
+	}
+	if math.IsNaN(meters) || math.Abs(meters) > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid %s %v", attribute, meters)
+	}
+	return int(math.Round(meters)), nil
+}
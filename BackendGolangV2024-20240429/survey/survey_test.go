@@ -0,0 +1,145 @@
+package survey
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const collection = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "geometry": {"type": "Point", "coordinates": [101.25, 1.5]}, "properties": {"Height": 12.4}},
+    {"type": "Feature", "geometry": {"type": "Point", "coordinates": [101.26, 1.51, 30]}, "properties": {"height": "9"}},
+    {"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}, "properties": {}},
+    {"type": "Feature", "geometry": {"type": "Point", "coordinates": [101.27, 1.52]}, "properties": {"name": "palm"}}
+  ]
+}`
+
+func TestReadGeoJSON(t *testing.T) {
+	features, err := Read(strings.NewReader(collection), DefaultHeightAttribute, 10)
+	require.NoError(t, err)
+	require.Len(t, features, 4)
+	require.Equal(t, Feature{Index: 0, Latitude: 1.5, Longitude: 101.25, Height: 12}, features[0])
+	require.Equal(t, Feature{Index: 1, Latitude: 1.51, Longitude: 101.26, Height: 9}, features[1])
+	require.EqualError(t, features[2].Err, "not a point")
+	require.EqualError(t, features[3].Err, "no height")
+
+	features, err = Read(strings.NewReader(collection), "name", 10)
+	require.NoError(t, err)
+	require.EqualError(t, features[3].Err, `invalid name "palm"`)
+
+	_, err = Read(strings.NewReader(collection), DefaultHeightAttribute, 3)
+	require.True(t, errors.Is(err, ErrTooManyFeatures))
+
+	_, err = Read(strings.NewReader(`{"type": "Point", "coordinates": [0, 0]}`), DefaultHeightAttribute, 10)
+	require.EqualError(t, err, "GeoJSON must be a FeatureCollection or a Feature")
+}
+
+// whitespace is an endless stream of spaces.
+type whitespace struct{}
+
+func (whitespace) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
+
+func TestReadGeoJSONTooLarge(t *testing.T) {
+	r := io.MultiReader(strings.NewReader(`{"type": "FeatureCollection", "features": [`), whitespace{})
+	_, err := Read(r, DefaultHeightAttribute, 10)
+	require.EqualError(t, err, "the GeoJSON file is larger than 64 MiB")
+}
+
+// shapefile zips a point shapefile of longitude and latitude pairs with a
+// HEIGHT attribute; the record of a deleted height is flagged as deleted.
+func shapefile(t *testing.T, points [][2]float64, heights []string, deleted int, prj string) []byte {
+	t.Helper()
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	shp := make([]byte, 100)
+	be.PutUint32(shp, 9994)
+	be.PutUint32(shp[24:], uint32((100+len(points)*28)/2))
+	le.PutUint32(shp[28:], 1000)
+	le.PutUint32(shp[32:], shapePoint)
+	for i, point := range points {
+		record := make([]byte, 28)
+		be.PutUint32(record, uint32(i+1))
+		be.PutUint32(record[4:], 10)
+		le.PutUint32(record[8:], shapePoint)
+		le.PutUint64(record[12:], math.Float64bits(point[0]))
+		le.PutUint64(record[20:], math.Float64bits(point[1]))
+		shp = append(shp, record...)
+	}
+
+	// One NAME field of 10 characters and one HEIGHT field of 6.
+	dbf := make([]byte, 32)
+	dbf[0] = 3
+	le.PutUint32(dbf[4:], uint32(len(heights)))
+	le.PutUint16(dbf[8:], 32+2*32+1)
+	le.PutUint16(dbf[10:], 1+10+6)
+	for _, field := range []struct {
+		name  string
+		kind  byte
+		width byte
+	}{{"NAME", 'C', 10}, {"HEIGHT", 'N', 6}} {
+		descriptor := make([]byte, 32)
+		copy(descriptor, field.name)
+		descriptor[11], descriptor[16] = field.kind, field.width
+		dbf = append(dbf, descriptor...)
+	}
+	dbf = append(dbf, 0x0d)
+	for i, height := range heights {
+		flag := " "
+		if i == deleted {
+			flag = "*"
+		}
+		dbf = append(dbf, fmt.Sprintf("%s%-10s%6s", flag, "palm", height)...)
+	}
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	files := map[string][]byte{"survey/Trees.SHP": shp, "survey/Trees.dbf": dbf}
+	if prj != "" {
+		files["survey/Trees.prj"] = []byte(prj)
+	}
+	for name, content := range files {
+		file, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = file.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return archive.Bytes()
+}
+
+func TestReadShapefile(t *testing.T) {
+	const wgs84 = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+	archive := shapefile(t, [][2]float64{{101.25, 1.5}, {101.26, 1.51}, {101.27, 1.52}}, []string{"12.4", "8", ""}, 1, wgs84)
+
+	features, err := Read(bytes.NewReader(archive), DefaultHeightAttribute, 10)
+	require.NoError(t, err)
+	require.Len(t, features, 2, "deleted records are skipped")
+	require.Equal(t, Feature{Index: 0, Latitude: 1.5, Longitude: 101.25, Height: 12}, features[0])
+	require.Equal(t, 2, features[1].Index)
+	require.EqualError(t, features[1].Err, "no height")
+
+	_, err = Read(bytes.NewReader(archive), "hgt", 10)
+	require.EqualError(t, err, "the .dbf file has no hgt attribute")
+
+	_, err = Read(bytes.NewReader(archive), DefaultHeightAttribute, 2)
+	require.True(t, errors.Is(err, ErrTooManyFeatures))
+
+	projected := shapefile(t, [][2]float64{{500000, 160000}}, []string{"10"}, -1, `PROJCS["WGS_1984_UTM_Zone_47N",GEOGCS["GCS_WGS_1984"]]`)
+	_, err = Read(bytes.NewReader(projected), DefaultHeightAttribute, 10)
+	require.EqualError(t, err, "the shapefile is projected, export it in WGS84 longitude and latitude")
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"math"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// MaxListed caps the missed plots and altitude violations listed in a report;
+// the counts are always complete.
+const MaxListed = 1000
+
+type Plot struct {
+	X, Y int
+}
+
+type AltitudeViolation struct {
+	Time             time.Time
+	Plot             Plot
+	Altitude         float64
+	RequiredAltitude int
+}
+
+// Report compares the telemetry of a mission against its plan.
+type Report struct {
+	Samples   int
+	StartedAt *time.Time
+	EndedAt   *time.Time
+	// PlannedDistance is the distance of the mission; ActualDistance adds up
+	// the horizontal moves and the climbs and descents between samples the
+	// same way.
+	PlannedDistance int
+	ActualDistance  float64
+	PlannedPlots    int
+	VisitedPlots    int
+	// MissedPlots are in flight order and AltitudeViolations in time order.
+	MissedPlotCount        int
+	MissedPlots            []Plot
+	AltitudeViolationCount int
+	AltitudeViolations     []AltitudeViolation
+	OutsideSamples         int
+	BatteryUsed            *float64
+}
+
+// Compare reports the planned plots no sample was logged over, the samples
+// below the canopy plus clearance of their plot, and the distance flown.
+// Samples are assigned to the nearest plot centre and must be in time order.
+func Compare(mission repository.Mission, samples []repository.TelemetrySample) Report {
+	plots, altitudes := PlannedPlots(mission.Path)
+	report := Report{
+		Samples:            len(samples),
+		PlannedDistance:    mission.Distance,
+		PlannedPlots:       len(plots),
+		MissedPlots:        []Plot{},
+		AltitudeViolations: []AltitudeViolation{},
+	}
+
+	visited := map[Plot]bool{}
+	for i, sample := range samples {
+		plot := Plot{int(math.Round(sample.X)), int(math.Round(sample.Y))}
+		required, planned := altitudes[plot]
+		if !planned {
+			report.OutsideSamples++
+		} else {
+			visited[plot] = true
+			if sample.Altitude < float64(required) {
+				report.AltitudeViolationCount++
+				if len(report.AltitudeViolations) < MaxListed {
+					report.AltitudeViolations = append(report.AltitudeViolations, AltitudeViolation{
+						Time:             sample.Time,
+						Plot:             plot,
+						Altitude:         sample.Altitude,
+						RequiredAltitude: required,
+					})
+				}
+			}
+		}
+		if i > 0 {
+			previous := samples[i-1]
+			dx := (sample.X - previous.X) * mission.PlotSizeMeters
+			dy := (sample.Y - previous.Y) * mission.PlotSizeMeters
+			report.ActualDistance += math.Hypot(dx, dy) + math.Abs(sample.Altitude-previous.Altitude)
+		}
+	}
+
+	for _, plot := range plots {
+		if visited[plot] {
+			report.VisitedPlots++
+			continue
+		}
+		report.MissedPlotCount++
+		if len(report.MissedPlots) < MaxListed {
+			report.MissedPlots = append(report.MissedPlots, plot)
+		}
+	}
+
+	if len(samples) > 0 {
+		first, last := samples[0], samples[len(samples)-1]
+		report.StartedAt, report.EndedAt = &first.Time, &last.Time
+		if first.Battery != nil && last.Battery != nil {
+			used := *first.Battery - *last.Battery
+			report.BatteryUsed = &used
+		}
+	}
+	return report
+}
+
+// PlannedPlots expands the waypoints of a mission path into every plot flown
+// over, in flight order, and the altitude held over each. Waypoints of a row
+// are joined by level flight and rows by a single step to the next row.
+func PlannedPlots(path []repository.Waypoint) ([]Plot, map[Plot]int) {
+	var plots []Plot
+	altitudes := map[Plot]int{}
+	visit := func(plot Plot, altitude int) {
+		if _, ok := altitudes[plot]; !ok {
+			plots = append(plots, plot)
+		}
+		altitudes[plot] = altitude
+	}
+	for i, waypoint := range path {
+		if i > 0 {
+			previous := path[i-1]
+			if previous.Y == waypoint.Y && previous.X != waypoint.X {
+				step := 1
+				if waypoint.X < previous.X {
+					step = -1
+				}
+				for x := previous.X + step; x != waypoint.X; x += step {
+					visit(Plot{x, waypoint.Y}, previous.Altitude)
+				}
+			}
+		}
+		visit(Plot{waypoint.X, waypoint.Y}, waypoint.Altitude)
+	}
+	return plots, altitudes
+}
@@ -0,0 +1,215 @@
+// Package telemetry reads the positions drones log during missions and
+// compares them against the planned flight.
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// Format is the encoding of a flight log.
+type Format int
+
+const (
+	NDJSON Format = iota
+	CSV
+)
+
+// ErrTooManySamples is returned when a log holds more samples than allowed.
+var ErrTooManySamples = errors.New("too many samples")
+
+// record is a sample as logged, before local meters are turned into plots.
+type record struct {
+	Time      *time.Time `json:"time"`
+	X         *float64   `json:"x"`
+	Y         *float64   `json:"y"`
+	LocalX    *float64   `json:"local_x"`
+	LocalY    *float64   `json:"local_y"`
+	Latitude  *float64   `json:"latitude"`
+	Longitude *float64   `json:"longitude"`
+	Altitude  *float64   `json:"altitude"`
+	Battery   *float64   `json:"battery"`
+}
+
+// Parse reads at most limit samples. Positions are plots (x, y), meters from
+// the centre of plot (1,1) (local_x, local_y), which are divided by
+// plotSizeMeters, or WGS84 degrees (latitude, longitude) placed by ref, which
+// is nil for estates that are not geo-referenced. Values must be finite and
+// fit a REAL. Errors name the offending line.
+func Parse(r io.Reader, format Format, plotSizeMeters float64, ref *geo.Reference, limit int) ([]repository.TelemetrySample, error) {
+	var samples []repository.TelemetrySample
+	add := func(line int, rec record) error {
+		if len(samples) == limit {
+			return fmt.Errorf("%w: at most %d per upload", ErrTooManySamples, limit)
+		}
+		sample, err := rec.sample(plotSizeMeters, ref)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+		return nil
+	}
+	var err error
+	if format == CSV {
+		err = parseCSV(r, add)
+	} else {
+		err = parseNDJSON(r, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+func parseNDJSON(r io.Reader, add func(line int, rec record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := add(line, rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseCSV(r io.Reader, add func(line int, rec record) error) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["time"]; !ok {
+		return errors.New("line 1: the header has no time column")
+	}
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		var rec record
+		for name, i := range columns {
+			value := strings.TrimSpace(fields[i])
+			if value == "" {
+				continue
+			}
+			if name == "time" {
+				t, err := time.Parse(time.RFC3339Nano, value)
+				if err != nil {
+					return fmt.Errorf("line %d: invalid time %q", line, value)
+				}
+				rec.Time = &t
+				continue
+			}
+			target := rec.field(name)
+			if target == nil {
+				continue // unknown columns are ignored
+			}
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid %s %q", line, name, value)
+			}
+			*target = &number
+		}
+		if err := add(line, rec); err != nil {
+			return err
+		}
+	}
+}
+
+func (rec *record) field(name string) **float64 {
+	switch name {
+	case "x":
+		return &rec.X
+	case "y":
+		return &rec.Y
+	case "local_x":
+		return &rec.LocalX
+	case "local_y":
+		return &rec.LocalY
+	case "latitude":
+		return &rec.Latitude
+	case "longitude":
+		return &rec.Longitude
+	case "altitude":
+		return &rec.Altitude
+	case "battery":
+		return &rec.Battery
+	}
+	return nil
+}
+
+func (rec record) sample(plotSizeMeters float64, ref *geo.Reference) (repository.TelemetrySample, error) {
+	if rec.Time == nil {
+		return repository.TelemetrySample{}, errors.New("time is required")
+	}
+	if rec.Altitude == nil {
+		return repository.TelemetrySample{}, errors.New("altitude is required")
+	}
+	sample := repository.TelemetrySample{Time: *rec.Time, Altitude: *rec.Altitude, Battery: rec.Battery}
+	switch {
+	case rec.X != nil && rec.Y != nil:
+		sample.X, sample.Y = *rec.X, *rec.Y
+	case rec.LocalX != nil && rec.LocalY != nil:
+		sample.X = *rec.LocalX/plotSizeMeters + 1
+		sample.Y = *rec.LocalY/plotSizeMeters + 1
+	case rec.Latitude != nil && rec.Longitude != nil:
+		if ref == nil {
+			return repository.TelemetrySample{}, errors.New("latitude and longitude need a geo-referenced estate")
+		}
+		if err := geo.CheckCoordinates(*rec.Latitude, *rec.Longitude); err != nil {
+			return repository.TelemetrySample{}, err
+		}
+		sample.X, sample.Y = ref.FromWGS84(*rec.Latitude, *rec.Longitude)
+	default:
+		return repository.TelemetrySample{}, errors.New("either x and y, local_x and local_y or latitude and longitude are required")
+	}
+	values := []struct {
+		name  string
+		value *float64
+	}{{"x", &sample.X}, {"y", &sample.Y}, {"altitude", &sample.Altitude}, {"battery", sample.Battery}}
+	for _, v := range values {
+		if v.value != nil && !storable(*v.value) {
+			return repository.TelemetrySample{}, fmt.Errorf("%s (%g) is not a finite number in range", v.name, *v.value)
+		}
+	}
+	return sample, nil
+}
+
+// storable reports whether a value fits the REAL columns samples are stored
+// in. NaN and infinities would also break the distances of mission reports.
+func storable(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && math.Abs(value) <= math.MaxFloat32
+}
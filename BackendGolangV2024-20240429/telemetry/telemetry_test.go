@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/geo"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNDJSON(t *testing.T) {
+	log := `{"time":"2024-05-01T08:00:00Z","x":1,"y":1,"altitude":2.5,"battery":99}
+
+{"time":"2024-05-01T08:00:01Z","local_x":15,"local_y":0,"altitude":11}
+`
+	samples, err := Parse(strings.NewReader(log), NDJSON, 10, nil, 10)
+	require.NoError(t, err)
+	battery := 99.0
+	require.Equal(t, []repository.TelemetrySample{
+		{Time: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), X: 1, Y: 1, Altitude: 2.5, Battery: &battery},
+		{Time: time.Date(2024, 5, 1, 8, 0, 1, 0, time.UTC), X: 2.5, Y: 1, Altitude: 11},
+	}, samples)
+}
+
+func TestParseCSV(t *testing.T) {
+	log := "altitude,time,x,y,heading\n" +
+		"2,2024-05-01T08:00:00Z,1,1,90\n" +
+		"11,2024-05-01T08:00:01Z,2,1,\n"
+	samples, err := Parse(strings.NewReader(log), CSV, 10, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	require.Equal(t, 11.0, samples[1].Altitude)
+	require.Equal(t, 2.0, samples[1].X)
+	require.Nil(t, samples[1].Battery)
+}
+
+func TestParseWGS84(t *testing.T) {
+	ref := &geo.Reference{Latitude: 1.5, Longitude: 101.25, Bearing: geo.DefaultBearing, PlotSizeMeters: 10}
+	latitude, longitude := ref.ToWGS84(3, 2)
+	log := fmt.Sprintf("time,latitude,longitude,altitude\n2024-05-01T08:00:00Z,%.10f,%.10f,4\n", latitude, longitude)
+
+	samples, err := Parse(strings.NewReader(log), CSV, 10, ref, 10)
+	require.NoError(t, err)
+	require.InDelta(t, 3, samples[0].X, 1e-6)
+	require.InDelta(t, 2, samples[0].Y, 1e-6)
+
+	_, err = Parse(strings.NewReader(log), CSV, 10, nil, 10)
+	require.EqualError(t, err, "line 2: latitude and longitude need a geo-referenced estate")
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("time,x,y,altitude\n2024-05-01T08:00:00Z,1,,3\n"), CSV, 10, nil, 10)
+	require.EqualError(t, err, "line 2: either x and y, local_x and local_y or latitude and longitude are required")
+
+	_, err = Parse(strings.NewReader("time,x,y,altitude\nyesterday,1,1,3\n"), CSV, 10, nil, 10)
+	require.EqualError(t, err, `line 2: invalid time "yesterday"`)
+
+	_, err = Parse(strings.NewReader(`{"x":1,"y":1,"altitude":3}`), NDJSON, 10, nil, 10)
+	require.EqualError(t, err, "line 1: time is required")
+
+	_, err = Parse(strings.NewReader("time,x,y,altitude\n2024-05-01T08:00:00Z,1,1,NaN\n"), CSV, 10, nil, 10)
+	require.EqualError(t, err, "line 2: altitude (NaN) is not a finite number in range")
+
+	_, err = Parse(strings.NewReader("time,x,y,altitude,battery\n2024-05-01T08:00:00Z,1,1,3,-Inf\n"), CSV, 10, nil, 10)
+	require.EqualError(t, err, "line 2: battery (-Inf) is not a finite number in range")
+
+	_, err = Parse(strings.NewReader(`{"time":"2024-05-01T08:00:00Z","x":1e300,"y":1,"altitude":3}`), NDJSON, 10, nil, 10)
+	require.EqualError(t, err, "line 1: x (1e+300) is not a finite number in range")
+
+	log := strings.Repeat(`{"time":"2024-05-01T08:00:00Z","x":1,"y":1,"altitude":3}`+"\n", 3)
+	_, err = Parse(strings.NewReader(log), NDJSON, 10, nil, 2)
+	require.True(t, errors.Is(err, ErrTooManySamples))
+}
+
+func TestPlannedPlots(t *testing.T) {
+	plots, altitudes := PlannedPlots([]repository.Waypoint{
+		{X: 1, Y: 1, Altitude: 1},
+		{X: 3, Y: 1, Altitude: 1},
+		{X: 4, Y: 1, Altitude: 11},
+		{X: 4, Y: 2, Altitude: 1},
+		{X: 2, Y: 2, Altitude: 1},
+	})
+	require.Equal(t, []Plot{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {4, 2}, {3, 2}, {2, 2}}, plots)
+	require.Equal(t, 11, altitudes[Plot{4, 1}])
+	require.Equal(t, 1, altitudes[Plot{3, 2}])
+}
+
+func TestCompare(t *testing.T) {
+	mission := repository.Mission{
+		Distance:       22,
+		PlotSizeMeters: 10,
+		Path: []repository.Waypoint{
+			{X: 1, Y: 1, Altitude: 1},
+			{X: 2, Y: 1, Altitude: 11},
+			{X: 3, Y: 1, Altitude: 1},
+		},
+	}
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	full, empty := 100.0, 80.0
+	report := Compare(mission, []repository.TelemetrySample{
+		{Time: start, X: 1, Y: 1, Altitude: 1, Battery: &full},
+		{Time: start.Add(time.Second), X: 1.8, Y: 1, Altitude: 9},
+		{Time: start.Add(2 * time.Second), X: 4, Y: 1, Altitude: 9, Battery: &empty},
+	})
+
+	require.Equal(t, 3, report.Samples)
+	require.Equal(t, 3, report.PlannedPlots)
+	require.Equal(t, 2, report.VisitedPlots)
+	require.Equal(t, []Plot{{3, 1}}, report.MissedPlots)
+	require.Equal(t, 1, report.AltitudeViolationCount)
+	require.Equal(t, AltitudeViolation{Time: start.Add(time.Second), Plot: Plot{2, 1}, Altitude: 9, RequiredAltitude: 11},
+		report.AltitudeViolations[0])
+	require.Equal(t, 1, report.OutsideSamples)
+	require.InDelta(t, 8+8+22, report.ActualDistance, 1e-9)
+	require.Equal(t, 20.0, *report.BatteryUsed)
+	require.Equal(t, start.Add(2*time.Second), *report.EndedAt)
+}
@@ -0,0 +1,221 @@
+// Package terrain reads ground elevation grids and samples them at the plots
+// of an estate.
+package terrain
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// ErrTooManyCells is returned when a grid holds more cells than allowed.
+var ErrTooManyCells = errors.New("too many cells")
+
+// defaultNoData is the NODATA_value of grids that do not set one.
+const defaultNoData = -9999
+
+// ParseASCIIGrid reads an ESRI ASCII grid of at most limit cells. Its
+// coordinates are taken as estate-local meters from the centre of plot (1,1).
+// Errors name the offending line.
+func ParseASCIIGrid(r io.Reader, limit int) (repository.Terrain, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+
+	header := map[string]float64{}
+	var fields []string
+	for scanner.Scan() {
+		line++
+		fields = strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		key := strings.ToLower(fields[0])
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			break // the first row of elevations
+		}
+		if len(fields) != 2 {
+			return repository.Terrain{}, fmt.Errorf("line %d: expected a key and a value", line)
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return repository.Terrain{}, fmt.Errorf("line %d: invalid %s %q", line, key, fields[1])
+		}
+		header[key] = value
+		fields = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return repository.Terrain{}, err
+	}
+
+	terrain, noData, err := gridHeader(header, limit)
+	if err != nil {
+		return repository.Terrain{}, err
+	}
+	terrain.Elevations = make([]float64, 0, terrain.Columns*terrain.Rows)
+	for {
+		for _, field := range fields {
+			if len(terrain.Elevations) == cap(terrain.Elevations) {
+				return repository.Terrain{}, fmt.Errorf("line %d: more than %d values", line, cap(terrain.Elevations))
+			}
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				return repository.Terrain{}, fmt.Errorf("line %d: invalid elevation %q", line, field)
+			}
+			if value == noData {
+				value = math.NaN()
+			}
+			terrain.Elevations = append(terrain.Elevations, value)
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line++
+		fields = strings.Fields(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return repository.Terrain{}, err
+	}
+	if len(terrain.Elevations) != cap(terrain.Elevations) {
+		return repository.Terrain{}, fmt.Errorf("expected %d values, got %d", cap(terrain.Elevations), len(terrain.Elevations))
+	}
+	return terrain, nil
+}
+
+// gridHeader checks the header of a grid. Grids placed by their lower left
+// cell centre (xllcenter, yllcenter) are moved to its corner.
+func gridHeader(header map[string]float64, limit int) (repository.Terrain, float64, error) {
+	var errs []error
+	required := func(key string) float64 {
+		value, ok := header[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("the header has no %s", key))
+		}
+		return value
+	}
+	columns, rows, cellSize := required("ncols"), required("nrows"), required("cellsize")
+	if len(errs) > 0 {
+		return repository.Terrain{}, 0, errors.Join(errs...)
+	}
+	if columns < 1 || rows < 1 || columns != math.Trunc(columns) || rows != math.Trunc(rows) {
+		return repository.Terrain{}, 0, errors.New("ncols and nrows must be positive whole numbers")
+	}
+	if columns*rows > float64(limit) {
+		return repository.Terrain{}, 0, fmt.Errorf("%w: at most %d per grid", ErrTooManyCells, limit)
+	}
+	if !(cellSize > 0) {
+		return repository.Terrain{}, 0, errors.New("cellsize must be positive")
+	}
+
+	terrain := repository.Terrain{Columns: int(columns), Rows: int(rows), CellSize: cellSize}
+	x, cornerX := header["xllcorner"]
+	y, cornerY := header["yllcorner"]
+	if !cornerX {
+		x = required("xllcenter") - cellSize/2
+	}
+	if !cornerY {
+		y = required("yllcenter") - cellSize/2
+	}
+	if len(errs) > 0 {
+		return repository.Terrain{}, 0, errors.Join(errs...)
+	}
+	terrain.XLowerLeft, terrain.YLowerLeft = x, y
+
+	noData, ok := header["nodata_value"]
+	if !ok {
+		noData = defaultNoData
+	}
+	return terrain, noData, nil
+}
+
+// Model is the ground under the plots of an estate. The zero Model is flat.
+type Model struct {
+	terrain        *repository.Terrain
+	plotSizeMeters float64
+	// xOffset and yOffset are how many plots plot (1,1) of the model lies
+	// from plot (1,1) of the estate.
+	xOffset, yOffset int
+	// takeOff is the elevation of plot (1,1), where the drone takes off.
+	takeOff float64
+}
+
+// NewModel samples terrain at plot centres plotSizeMeters apart.
+func NewModel(terrain repository.Terrain, plotSizeMeters float64) Model {
+	m := Model{terrain: &terrain, plotSizeMeters: plotSizeMeters}
+	m.takeOff, _ = m.elevation(1, 1)
+	return m
+}
+
+// Within returns the ground of the plots from plot (x, y) of the estate on,
+// numbered from (1,1) there, where the drone then takes off.
+func (m Model) Within(x, y int) Model {
+	if m.terrain == nil {
+		return m
+	}
+	m.xOffset += x - 1
+	m.yOffset += y - 1
+	m.takeOff, _ = m.elevation(1, 1)
+	return m
+}
+
+// Flat reports whether m has no terrain.
+func (m Model) Flat() bool {
+	return m.terrain == nil
+}
+
+// UploadedAt returns when the terrain was uploaded, nil for flat models.
+func (m Model) UploadedAt() *time.Time {
+	if m.terrain == nil {
+		return nil
+	}
+	return &m.terrain.UploadedAt
+}
+
+// Ground returns the elevation of a plot relative to plot (1,1), rounded to
+// whole meters. Plots the grid has no data for are level with plot (1,1).
+func (m Model) Ground(x, y int) int {
+	if m.terrain == nil {
+		return 0
+	}
+	elevation, ok := m.elevation(x, y)
+	if !ok {
+		return 0
+	}
+	return int(math.Round(elevation - m.takeOff))
+}
+
+// Check reports the first plot of an estate the grid has no data for.
+func (m Model) Check(length, width int) error {
+	if m.terrain == nil {
+		return nil
+	}
+	for y := 1; y <= width; y++ {
+		for x := 1; x <= length; x++ {
+			if _, ok := m.elevation(x, y); !ok {
+				return fmt.Errorf("the grid has no elevation for plot (%d,%d)", x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// elevation returns the value of the cell holding the centre of a plot.
+func (m Model) elevation(x, y int) (float64, bool) {
+	t := m.terrain
+	localX := float64(x+m.xOffset-1) * m.plotSizeMeters
+	localY := float64(y+m.yOffset-1) * m.plotSizeMeters
+	column := int(math.Floor((localX - t.XLowerLeft) / t.CellSize))
+	row := t.Rows - 1 - int(math.Floor((localY-t.YLowerLeft)/t.CellSize))
+	if column < 0 || column >= t.Columns || row < 0 || row >= t.Rows {
+		return 0, false
+	}
+	value := t.Elevations[row*t.Columns+column]
+	return value, !math.IsNaN(value)
+}
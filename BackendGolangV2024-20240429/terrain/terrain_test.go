@@ -0,0 +1,80 @@
+package terrain
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// grid covers a 3x2 estate of 10m plots with 10m cells centred on the plots.
+const grid = `ncols 3
+nrows 2
+xllcenter 0
+yllcenter 0
+cellsize 10
+NODATA_value -1
+105 106 -1
+100 102 104
+`
+
+func TestParseASCIIGrid(t *testing.T) {
+	terrain, err := ParseASCIIGrid(strings.NewReader(grid), 100)
+	require.NoError(t, err)
+	require.Equal(t, 3, terrain.Columns)
+	require.Equal(t, 2, terrain.Rows)
+	require.Equal(t, -5.0, terrain.XLowerLeft)
+	require.Equal(t, -5.0, terrain.YLowerLeft)
+	require.Len(t, terrain.Elevations, 6)
+	require.True(t, math.IsNaN(terrain.Elevations[2]))
+	require.Equal(t, 104.0, terrain.Elevations[5])
+}
+
+func TestParseASCIIGridErrors(t *testing.T) {
+	_, err := ParseASCIIGrid(strings.NewReader("ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\n1 2 3\n"), 100)
+	require.EqualError(t, err, "the header has no cellsize")
+
+	_, err = ParseASCIIGrid(strings.NewReader(strings.Replace(grid, "104", "high", 1)), 100)
+	require.EqualError(t, err, `line 8: invalid elevation "high"`)
+
+	_, err = ParseASCIIGrid(strings.NewReader(strings.TrimSuffix(grid, " 104\n")), 100)
+	require.EqualError(t, err, "expected 6 values, got 5")
+
+	_, err = ParseASCIIGrid(strings.NewReader(grid), 5)
+	require.True(t, errors.Is(err, ErrTooManyCells))
+}
+
+func TestModel(t *testing.T) {
+	terrain, err := ParseASCIIGrid(strings.NewReader(grid), 100)
+	require.NoError(t, err)
+	model := NewModel(terrain, 10)
+
+	require.False(t, model.Flat())
+	require.Equal(t, 0, model.Ground(1, 1))
+	require.Equal(t, 4, model.Ground(3, 1))
+	require.Equal(t, 6, model.Ground(2, 2))
+	require.Equal(t, 0, model.Ground(3, 2), "no data")
+	require.Equal(t, 0, model.Ground(4, 1), "outside the grid")
+	require.EqualError(t, model.Check(3, 2), "the grid has no elevation for plot (3,2)")
+	require.NoError(t, model.Check(2, 2))
+
+	require.True(t, Model{}.Flat())
+	require.Equal(t, 0, Model{}.Ground(2, 2))
+	require.NoError(t, Model{}.Check(3, 2))
+}
+
+func TestModelWithin(t *testing.T) {
+	terrain, err := ParseASCIIGrid(strings.NewReader(grid), 100)
+	require.NoError(t, err)
+	model := NewModel(terrain, 10).Within(2, 1)
+
+	require.Equal(t, 0, model.Ground(1, 1))
+	require.Equal(t, 2, model.Ground(2, 1))
+	require.Equal(t, 4, model.Ground(1, 2))
+	require.EqualError(t, model.Check(2, 2), "the grid has no elevation for plot (2,2)")
+	require.Equal(t, -1, model.Within(1, 2).Ground(0, 1), "plot (1,2) of the estate below plot (2,2)")
+
+	require.True(t, Model{}.Within(2, 2).Flat())
+}
@@ -0,0 +1,783 @@
+// This file hammers the API with concurrent and repeated writes to check that
+// validation and insert run atomically and retries are idempotent.
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+const hammerWorkers = 20
+
+func TestConcurrentTreesOnSamePlot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 10, 10)
+
+	statuses := hammer(hammerWorkers, func(int) int {
+		status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 3, "y": 3, "height": 10})
+		return status
+	})
+
+	require.Equal(t, 1, statuses[http.StatusOK], statuses)
+	require.Equal(t, hammerWorkers-1, statuses[http.StatusBadRequest], statuses)
+	require.Equal(t, 1, treeCount(t, estateId))
+}
+
+func TestConcurrentResizeAndInsert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	for i := 0; i < hammerWorkers; i++ {
+		estateId := createEstate(t, 10, 10)
+
+		statuses := hammer(2, func(worker int) int {
+			if worker == 0 {
+				status, _ := send(t, "PATCH", "/estate/"+estateId, map[string]int{"length": 5, "width": 5}, http.Header{"If-Match": {`"1"`}})
+				return status
+			}
+			status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 8, "y": 8, "height": 10})
+			return status
+		})
+
+		// Either the tree was planted and the resize refused, or the estate
+		// shrank first and the tree fell outside it.
+		require.Equal(t, 1, statuses[http.StatusOK], statuses)
+		require.Equal(t, 1, statuses[http.StatusConflict]+statuses[http.StatusBadRequest], statuses)
+	}
+}
+
+func TestConcurrentBulkImports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 10, 10)
+
+	statuses := hammer(hammerWorkers, func(worker int) int {
+		// Every batch plants its own row plus the shared plot (1,1).
+		trees := []map[string]int{{"x": 1, "y": 1, "height": 5}}
+		for x := 2; x <= 10; x++ {
+			trees = append(trees, map[string]int{"x": x, "y": worker%9 + 2, "height": 5})
+		}
+		status, _ := send(t, "POST", "/estate/"+estateId+"/trees", map[string]any{"trees": trees})
+		return status
+	})
+
+	require.Equal(t, 1, statuses[http.StatusOK], statuses)
+	require.Equal(t, 10, treeCount(t, estateId))
+}
+
+// hammer runs fn on n goroutines at once and counts the returned statuses.
+func hammer(n int, fn func(worker int) int) map[int]int {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		start    = make(chan struct{})
+		statuses = map[int]int{}
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			<-start
+			status := fn(worker)
+			mu.Lock()
+			statuses[status]++
+			mu.Unlock()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+// send is safe to call from the hammer goroutines: failures are reported with
+// t.Error and a zero status instead of stopping the test.
+func send(t *testing.T, method, path string, body any, headers ...http.Header) (int, map[string]any) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Error(err)
+		return 0, nil
+	}
+	request, err := http.NewRequest(method, ApiUrl+path, bytes.NewReader(payload))
+	if err != nil {
+		t.Error(err)
+		return 0, nil
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for _, header := range headers {
+		for name, values := range header {
+			request.Header[name] = values
+		}
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Error(err)
+		return 0, nil
+	}
+	defer response.Body.Close()
+
+	var result map[string]any
+	if response.StatusCode == http.StatusNoContent {
+		return response.StatusCode, nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Errorf("%s %s: decoding response: %v", method, path, err)
+	}
+	return response.StatusCode, result
+}
+
+// sendFile uploads content as the file form field of a multipart request.
+func sendFile(t *testing.T, method, path, name, content string) (int, map[string]any) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", name)
+	require.NoError(t, err)
+	_, err = file.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	request, err := http.NewRequest(method, ApiUrl+path, &body)
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	return response.StatusCode, result
+}
+
+func createEstate(t *testing.T, length, width int) string {
+	status, result := send(t, "POST", "/estate", map[string]int{"length": length, "width": width})
+	require.Equal(t, http.StatusOK, status, result)
+	return result["id"].(string)
+}
+
+func treeCount(t *testing.T, estateId string) int {
+	response, err := http.Get(fmt.Sprintf("%s/estate/%s/stats", ApiUrl, estateId))
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.Equal(t, http.StatusOK, response.StatusCode, result)
+	return int(result["count"].(float64))
+}
+
+func TestConcurrentTreeUpdates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 10, 10)
+	status, tree := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 1, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status, tree)
+	path := "/estate/" + estateId + "/tree/" + tree["id"].(string)
+
+	// Every agronomist read version 1; only the first write may win.
+	statuses := hammer(hammerWorkers, func(worker int) int {
+		status, _ := send(t, "PATCH", path, map[string]int{"height": worker + 1}, http.Header{"If-Match": {`"1"`}})
+		return status
+	})
+	require.Equal(t, 1, statuses[http.StatusOK], statuses)
+	require.Equal(t, hammerWorkers-1, statuses[http.StatusPreconditionFailed], statuses)
+
+	status, _ = send(t, "DELETE", path, nil)
+	require.Equal(t, http.StatusPreconditionRequired, status)
+	status, _ = send(t, "DELETE", path, nil, http.Header{"If-Match": {`"2"`}})
+	require.Equal(t, http.StatusNoContent, status)
+}
+
+func TestIdempotentRetries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	key := uuid.NewString()
+	post := func(body string) (int, map[string]any) {
+		request, err := http.NewRequest("POST", ApiUrl+"/estate", strings.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Idempotency-Key", key)
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+		return response.StatusCode, result
+	}
+
+	status, first := post(`{"length":10,"width":10}`)
+	require.Equal(t, http.StatusOK, status, first)
+	status, retry := post(`{"length":10,"width":10}`)
+	require.Equal(t, http.StatusOK, status, retry)
+	require.Equal(t, first["id"], retry["id"])
+
+	status, _ = post(`{"length":5,"width":5}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+}
+
+func TestAuditLog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 10, 10)
+	status, tree := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 1, "y": 1, "height": 10},
+		http.Header{"X-Actor": {"surveyor"}})
+	require.Equal(t, http.StatusOK, status, tree)
+	status, _ = send(t, "PATCH", "/estate/"+estateId+"/tree/"+tree["id"].(string), map[string]int{"height": 12},
+		http.Header{"If-Match": {`"1"`}, "X-Actor": {"agronomist"}})
+	require.Equal(t, http.StatusOK, status)
+
+	status, page := send(t, "GET", "/estate/"+estateId+"/audit?limit=2", nil)
+	require.Equal(t, http.StatusOK, status, page)
+	entries := page["entries"].([]any)
+	require.Len(t, entries, 2)
+	update := entries[0].(map[string]any)
+	require.Equal(t, "update", update["action"])
+	require.Equal(t, "agronomist", update["actor"])
+	require.Equal(t, 10.0, update["before"].(map[string]any)["height"])
+	require.Equal(t, 12.0, update["after"].(map[string]any)["height"])
+	require.Equal(t, "surveyor", entries[1].(map[string]any)["actor"])
+
+	status, page = send(t, "GET", "/estate/"+estateId+"/audit?cursor="+page["next_cursor"].(string), nil)
+	require.Equal(t, http.StatusOK, status, page)
+	entries = page["entries"].([]any)
+	require.Len(t, entries, 1)
+	require.Equal(t, "estate", entries[0].(map[string]any)["entity"])
+	require.Nil(t, page["next_cursor"])
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	admin := http.Header{"Authorization": {"Bearer local-admin-token"}}
+	estateId := createEstate(t, 10, 10)
+	for _, plot := range []int{1, 2} {
+		status, tree := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": plot, "y": 1, "height": 10})
+		require.Equal(t, http.StatusOK, status, tree)
+	}
+
+	status, _ := send(t, "DELETE", "/estate/"+estateId, nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = send(t, "GET", "/estate/"+estateId, nil)
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = send(t, "GET", "/estate/"+estateId+"?include_deleted=true", nil)
+	require.Equal(t, http.StatusForbidden, status)
+	status, estate := send(t, "GET", "/estate/"+estateId+"?include_deleted=true", nil, admin)
+	require.Equal(t, http.StatusOK, status, estate)
+	require.NotNil(t, estate["deleted_at"])
+
+	status, _ = send(t, "POST", "/estate/"+estateId+"/restore", nil)
+	require.Equal(t, http.StatusForbidden, status)
+	status, estate = send(t, "POST", "/estate/"+estateId+":restore", nil, admin)
+	require.Equal(t, http.StatusOK, status, estate)
+	require.Nil(t, estate["deleted_at"])
+	require.Equal(t, 2, treeCount(t, estateId))
+	status, _ = send(t, "POST", "/estate/"+estateId+"/restore", nil, admin)
+	require.Equal(t, http.StatusConflict, status)
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	admin := http.Header{"Authorization": {"Bearer local-admin-token"}}
+	status, _ := send(t, "POST", "/webhooks", map[string]any{"url": "https://erp.example.com/hooks"})
+	require.Equal(t, http.StatusForbidden, status)
+
+	status, created := send(t, "POST", "/webhooks", map[string]any{
+		"url":         "https://erp.example.com/hooks",
+		"event_types": []string{"tree.created"},
+	}, admin)
+	require.Equal(t, http.StatusOK, status, created)
+	require.Len(t, created["secret"], 64)
+
+	path := "/webhooks/" + created["id"].(string)
+	status, _ = send(t, "DELETE", path, nil, admin)
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = send(t, "DELETE", path, nil, admin)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestEstateEventStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 5, 5)
+	response, err := http.Get(fmt.Sprintf("%s/estate/%s/events", ApiUrl, estateId))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 1, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = send(t, "DELETE", "/estate/"+estateId, nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// The stream ends after estate.deleted, so the whole body can be read.
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "event: tree.created\n")
+	require.Contains(t, string(body), "event: estate.deleted\n")
+	require.Contains(t, string(body), "\"count\":1")
+}
+
+func TestEventStreamOfOverlappingTransactions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	db, err := sql.Open("postgres", DatabaseUrl)
+	require.NoError(t, err)
+	defer db.Close()
+	estateId := createEstate(t, 5, 5)
+	response, err := http.Get(fmt.Sprintf("%s/estate/%s/events", ApiUrl, estateId))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	// A long transaction publishes an event first and commits last.
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO outbox_event (estate_id, type, data) VALUES ($1, 'test.slow', '{}')`, estateId)
+	require.NoError(t, err)
+	status, tree := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 1, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status, tree)
+	// Give the stream time to read the tree while the event before it is
+	// not committed yet.
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, tx.Commit())
+
+	status, _ = send(t, "DELETE", "/estate/"+estateId, nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	slow := strings.Index(string(body), "event: test.slow\n")
+	created := strings.Index(string(body), "event: tree.created\n")
+	require.NotEqual(t, -1, slow, string(body))
+	require.Less(t, slow, created, string(body))
+}
+
+func TestEventStreamOfEmptyEstate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 5, 5)
+	stream := func(lastEventId string) *http.Response {
+		request, err := http.NewRequest("GET", fmt.Sprintf("%s/estate/%s/events", ApiUrl, estateId), nil)
+		require.NoError(t, err)
+		if lastEventId != "" {
+			request.Header.Set("Last-Event-ID", lastEventId)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() { response.Body.Close() })
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		return response
+	}
+	first := stream("")
+
+	status, tree := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 1, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status, tree)
+	status, _ = send(t, "DELETE", "/estate/"+estateId+"/tree/"+tree["id"].(string), nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+	// Reconnecting once the last tree is gone resumes the stream.
+	resumed := stream("0")
+
+	status, _ = send(t, "DELETE", "/estate/"+estateId, nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+	for _, response := range []*http.Response{first, resumed} {
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), "event: tree.deleted\n")
+		require.Contains(t, string(body), "event: estate.deleted\n")
+		require.Contains(t, string(body), "\"count\":0")
+	}
+}
+
+func TestMissionLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 5, 1)
+	status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 2, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status)
+
+	status, mission := send(t, "POST", "/estate/"+estateId+"/missions", map[string]any{})
+	require.Equal(t, http.StatusOK, status, mission)
+	require.Equal(t, "planned", mission["status"])
+	missionPath := "/estate/" + estateId + "/missions/" + mission["id"].(string)
+
+	// Changing trees afterwards leaves the snapshot alone.
+	status, _ = send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 4, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status)
+	status, got := send(t, "GET", missionPath, nil)
+	require.Equal(t, http.StatusOK, status, got)
+	require.Equal(t, mission["path"], got["path"])
+	require.Equal(t, mission["distance"], got["distance"])
+
+	status, _ = send(t, "POST", missionPath+"/status", map[string]string{"status": "completed"})
+	require.Equal(t, http.StatusConflict, status)
+	for _, next := range []string{"in_flight", "completed"} {
+		status, got = send(t, "POST", missionPath+"/status", map[string]string{"status": next})
+		require.Equal(t, http.StatusOK, status, got)
+		require.Equal(t, next, got["status"])
+	}
+	require.NotNil(t, got["started_at"])
+	require.NotNil(t, got["completed_at"])
+	status, _ = send(t, "POST", missionPath+"/status", map[string]string{"status": "aborted"})
+	require.Equal(t, http.StatusConflict, status)
+
+	status, page := send(t, "GET", "/estate/"+estateId+"/missions?status=completed", nil)
+	require.Equal(t, http.StatusOK, status, page)
+	require.Len(t, page["missions"], 1)
+}
+
+func TestMissionTelemetryReport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 3, 1)
+	status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 2, "y": 1, "height": 10})
+	require.Equal(t, http.StatusOK, status)
+	status, mission := send(t, "POST", "/estate/"+estateId+"/missions", map[string]any{})
+	require.Equal(t, http.StatusOK, status, mission)
+	missionPath := "/estate/" + estateId + "/missions/" + mission["id"].(string)
+
+	log := "time,x,y,altitude,battery\n" +
+		"2024-05-01T08:00:00Z,1,1,1,100\n" +
+		"2024-05-01T08:00:05Z,2,1,5,95\n"
+	uploadLog := func() (int, map[string]any) {
+		return sendFile(t, "POST", missionPath+"/telemetry", "flight.csv", log)
+	}
+
+	status, _ = uploadLog()
+	require.Equal(t, http.StatusConflict, status, "planned missions have no telemetry")
+	status, _ = send(t, "POST", missionPath+"/status", map[string]string{"status": "in_flight"})
+	require.Equal(t, http.StatusOK, status)
+	status, result := uploadLog()
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, float64(2), result["stored"])
+	status, result = uploadLog()
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, float64(0), result["stored"], "re-uploads are skipped")
+
+	status, report := send(t, "GET", missionPath+"/report", nil)
+	require.Equal(t, http.StatusOK, status, report)
+	require.Equal(t, float64(1), report["missed_plot_count"])
+	require.Equal(t, float64(1), report["altitude_violation_count"])
+	require.Equal(t, float64(5), report["battery_used"])
+}
+
+func TestMissionExport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 3, 1)
+	status, mission := send(t, "POST", "/estate/"+estateId+"/missions", map[string]any{})
+	require.Equal(t, http.StatusOK, status, mission)
+	exportPath := "/estate/" + estateId + "/missions/" + mission["id"].(string) + "/export"
+
+	response, err := http.Get(ApiUrl + exportPath + "?format=gpx&latitude=1.5&longitude=101.25")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "application/gpx+xml", response.Header.Get("Content-Type"))
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `<rtept lat="1.50000000" lon="101.25000000">`)
+
+	status, _ = send(t, "GET", exportPath+"?format=gpx&latitude=100&longitude=101.25", nil)
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestGeoReferencedEstate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	status, result := send(t, "POST", "/estate", map[string]any{
+		"length": 5, "width": 5,
+		"geo_reference": map[string]float64{"latitude": 1.5, "longitude": 101.25, "bearing": 0},
+	})
+	require.Equal(t, http.StatusOK, status, result)
+	estateId := result["id"].(string)
+
+	status, estate := send(t, "GET", "/estate/"+estateId, nil)
+	require.Equal(t, http.StatusOK, status, estate)
+	require.Equal(t, map[string]any{
+		"latitude": 1.5, "longitude": 101.25, "bearing": float64(0), "plot_size_meters": float64(10),
+	}, estate["geo_reference"])
+
+	status, position := send(t, "GET", "/estate/"+estateId+"/geo/to-wgs84?x=3&y=1", nil)
+	require.Equal(t, http.StatusOK, status, position)
+	require.Greater(t, position["latitude"], 1.5, "the x axis points north")
+
+	status, result = send(t, "POST", "/estate/"+estateId+"/tree", map[string]any{
+		"latitude": position["latitude"], "longitude": position["longitude"], "height": 10,
+	})
+	require.Equal(t, http.StatusOK, status, result)
+	status, tree := send(t, "GET", "/estate/"+estateId+"/tree/"+result["id"].(string), nil)
+	require.Equal(t, http.StatusOK, status, tree)
+	require.Equal(t, float64(3), tree["x"])
+	require.Equal(t, float64(1), tree["y"])
+
+	path := fmt.Sprintf("/estate/%s/geo/to-plot?latitude=%v&longitude=%v", estateId, position["latitude"], position["longitude"])
+	status, plot := send(t, "GET", path, nil)
+	require.Equal(t, http.StatusOK, status, plot)
+	require.Equal(t, map[string]any{"x": float64(3), "y": float64(1)}, plot["plot"])
+
+	status, mission := send(t, "POST", "/estate/"+estateId+"/missions", map[string]any{})
+	require.Equal(t, http.StatusOK, status, mission)
+	response, err := http.Get(ApiUrl + "/estate/" + estateId + "/missions/" + mission["id"].(string) + "/export?format=csv")
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	status, _ = send(t, "GET", "/estate/"+createEstate(t, 1, 1)+"/geo/to-wgs84?x=1&y=1", nil)
+	require.Equal(t, http.StatusConflict, status)
+}
+
+func TestEstateTerrain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 3, 1)
+	status, plan := send(t, "GET", "/estate/"+estateId+"/drone-plan", nil)
+	require.Equal(t, http.StatusOK, status, plan)
+	require.Equal(t, float64(22), plan["distance"])
+
+	grid := "ncols 3\nnrows 1\nxllcorner -5\nyllcorner -5\ncellsize 10\n100 105 105\n"
+	status, result := sendFile(t, "PUT", "/estate/"+estateId+"/terrain", "estate.asc", grid)
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, float64(105), result["max_elevation"])
+
+	status, plan = send(t, "GET", "/estate/"+estateId+"/drone-plan", nil)
+	require.Equal(t, http.StatusOK, status, plan)
+	require.Equal(t, float64(27), plan["distance"], "the drone climbs onto the hill")
+
+	status, result = sendFile(t, "PUT", "/estate/"+createEstate(t, 4, 1)+"/terrain", "estate.asc", grid)
+	require.Equal(t, http.StatusBadRequest, status, result)
+
+	status, _ = send(t, "DELETE", "/estate/"+estateId+"/terrain", nil)
+	require.Equal(t, http.StatusNoContent, status)
+	status, plan = send(t, "GET", "/estate/"+estateId+"/drone-plan", nil)
+	require.Equal(t, http.StatusOK, status, plan)
+	require.Equal(t, float64(22), plan["distance"])
+}
+
+func TestPointCloudImport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 3, 1)
+	status, _ := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": 2, "y": 1, "height": 5})
+	require.Equal(t, http.StatusOK, status)
+
+	cloud := "x,y,z\n0,0,100\n1,1,112\n10,0,100\n10,1,108\n"
+	path := "/estate/" + estateId + "/trees/point-cloud"
+	status, preview := sendFile(t, "POST", path+"?dry_run=true", "cloud.csv", cloud)
+	require.Equal(t, http.StatusOK, status, preview)
+	require.Len(t, preview["created"], 1)
+	require.Len(t, preview["updated"], 1)
+	require.Equal(t, 1, treeCount(t, estateId), "dry runs change nothing")
+
+	status, result := sendFile(t, "POST", path, "cloud.csv", cloud)
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, 2, treeCount(t, estateId))
+	status, stats := send(t, "GET", "/estate/"+estateId+"/stats", nil)
+	require.Equal(t, http.StatusOK, status, stats)
+	require.Equal(t, float64(12), stats["max"])
+}
+
+func TestSurveyImport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	status, result := send(t, "POST", "/estate", map[string]any{
+		"length": 3, "width": 1,
+		"geo_reference": map[string]float64{"latitude": 1.5, "longitude": 101.25},
+	})
+	require.Equal(t, http.StatusOK, status, result)
+	estateId := result["id"].(string)
+
+	point := func(x int, height int) string {
+		status, position := send(t, "GET", fmt.Sprintf("/estate/%s/geo/to-wgs84?x=%d&y=1", estateId, x), nil)
+		require.Equal(t, http.StatusOK, status, position)
+		return fmt.Sprintf(`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [%v, %v]}, "properties": {"HEIGHT": %d}}`,
+			position["longitude"], position["latitude"], height)
+	}
+	collection := `{"type": "FeatureCollection", "features": [` +
+		point(1, 10) + "," + point(2, 12) + "," + point(2, 13) + "," + point(7, 10) + `]}`
+
+	status, result = sendFile(t, "POST", "/estate/"+estateId+"/trees/survey", "trees.geojson", collection)
+	require.Equal(t, http.StatusOK, status, result)
+	require.Len(t, result["created"], 1)
+	require.Len(t, result["collisions"], 2)
+	require.Len(t, result["outside"], 1)
+	require.Equal(t, 1, treeCount(t, estateId))
+
+	status, result = sendFile(t, "POST", "/estate/"+createEstate(t, 3, 1)+"/trees/survey", "trees.geojson", collection)
+	require.Equal(t, http.StatusConflict, status, result)
+}
+
+func TestTreeSpecies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	admin := http.Header{"Authorization": {"Bearer local-admin-token"}}
+	status, _ := send(t, "PUT", "/species/Cocos%20nucifera", map[string]int{"max_height": 25})
+	require.Equal(t, http.StatusForbidden, status)
+	status, species := send(t, "PUT", "/species/Cocos%20nucifera", map[string]int{"max_height": 25}, admin)
+	require.Equal(t, http.StatusOK, status, species)
+
+	estateId := createEstate(t, 3, 1)
+	tree := func(x, height int, species, health string) (int, map[string]any) {
+		return send(t, "POST", "/estate/"+estateId+"/tree", map[string]any{
+			"x": x, "y": 1, "height": height, "species": species, "health": health,
+			"planted_on": "2019-03-01", "attributes": map[string]string{"clone": "DxP"},
+		})
+	}
+	status, result := tree(1, 28, "Cocos nucifera", "healthy")
+	require.Equal(t, http.StatusBadRequest, status, result)
+	status, result = tree(1, 28, "Elaeis guineensis", "healthy")
+	require.Equal(t, http.StatusOK, status, result)
+	status, result = tree(2, 20, "Cocos nucifera", "diseased")
+	require.Equal(t, http.StatusOK, status, result)
+	coconut := result["id"].(string)
+
+	status, result = send(t, "GET", "/estate/"+estateId+"/tree/"+coconut, nil)
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, "2019-03-01", result["planted_on"])
+	require.Equal(t, map[string]any{"clone": "DxP"}, result["attributes"])
+
+	status, result = send(t, "PATCH", "/estate/"+estateId+"/tree/"+coconut, map[string]int{"height": 26}, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusBadRequest, status, result)
+	status, result = send(t, "PATCH", "/estate/"+estateId+"/tree/"+coconut, map[string]string{"health": "dead"}, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, "Cocos nucifera", result["species"])
+
+	status, stats := send(t, "GET", "/estate/"+estateId+"/stats?group_by=species,health", nil)
+	require.Equal(t, http.StatusOK, status, stats)
+	require.Len(t, stats["groups"], 2)
+}
+
+func TestHarvestYield(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 3, 2)
+	plant := func(x, y, height int) string {
+		status, result := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": x, "y": y, "height": height})
+		require.Equal(t, http.StatusOK, status, result)
+		return result["id"].(string)
+	}
+	short, tall := plant(1, 1, 8), plant(2, 2, 14)
+	harvest := func(treeId, date string, bunches int, weight float64) {
+		status, result := send(t, "POST", "/estate/"+estateId+"/tree/"+treeId+"/harvests", map[string]any{
+			"harvested_on": date, "bunches": bunches, "weight_kg": weight, "harvester": "Adi",
+		})
+		require.Equal(t, http.StatusOK, status, result)
+	}
+	harvest(short, "2024-03-04", 1, 15)
+	harvest(short, "2024-04-02", 1, 17)
+	harvest(tall, "2024-04-09", 2, 46)
+
+	status, result := send(t, "POST", "/estate/"+estateId+"/tree/"+short+"/harvests", map[string]any{
+		"harvested_on": "2024-04-02", "bunches": -1, "weight_kg": 10,
+	})
+	require.Equal(t, http.StatusBadRequest, status, result)
+
+	status, report := send(t, "GET", "/estate/"+estateId+"/yield?group_by=period,row&from=2024-04-01", nil)
+	require.Equal(t, http.StatusOK, status, report)
+	require.Equal(t, float64(63), report["total"].(map[string]any)["weight_kg"])
+	require.Len(t, report["groups"], 2)
+
+	status, stats := send(t, "GET", "/estate/"+estateId+"/stats?include_yield=true", nil)
+	require.Equal(t, http.StatusOK, status, stats)
+	yield := stats["yield"].(map[string]any)
+	require.Equal(t, float64(2), yield["harvested_trees"])
+	require.InDelta(t, 1, yield["correlation"], 1e-9)
+}
+
+func TestEstateBlocks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+	estateId := createEstate(t, 4, 3)
+	for _, tree := range [][3]int{{1, 1, 10}, {2, 1, 20}, {3, 2, 5}} {
+		status, result := send(t, "POST", "/estate/"+estateId+"/tree", map[string]int{"x": tree[0], "y": tree[1], "height": tree[2]})
+		require.Equal(t, http.StatusOK, status, result)
+	}
+
+	status, division := send(t, "POST", "/estate/"+estateId+"/divisions", map[string]string{"name": "North"})
+	require.Equal(t, http.StatusOK, status, division)
+	status, west := send(t, "POST", "/estate/"+estateId+"/blocks", map[string]any{
+		"name": "West", "division_id": division["id"], "x_min": 1, "y_min": 1, "x_max": 2, "y_max": 3,
+		"mask": []string{"##", "#.", "#."},
+	})
+	require.Equal(t, http.StatusOK, status, west)
+	require.Equal(t, float64(4), west["plots"])
+
+	// The masked-out plot (2,2) is free, (2,1) is not.
+	status, result := send(t, "POST", "/estate/"+estateId+"/blocks", map[string]any{
+		"name": "Middle", "x_min": 2, "y_min": 1, "x_max": 3, "y_max": 2,
+	})
+	require.Equal(t, http.StatusBadRequest, status, result)
+	status, east := send(t, "POST", "/estate/"+estateId+"/blocks", map[string]any{
+		"name": "East", "x_min": 2, "y_min": 2, "x_max": 4, "y_max": 3, "mask": []string{"###", ".##"},
+	})
+	require.Equal(t, http.StatusOK, status, east)
+
+	status, stats := send(t, "GET", "/estate/"+estateId+"/blocks/"+west["id"].(string)+"/stats", nil)
+	require.Equal(t, http.StatusOK, status, stats)
+	require.Equal(t, float64(2), stats["count"])
+	require.Equal(t, float64(20), stats["max"])
+	status, empty := send(t, "POST", "/estate/"+estateId+"/blocks", map[string]any{
+		"name": "Corner", "x_min": 4, "y_min": 1, "x_max": 4, "y_max": 1,
+	})
+	require.Equal(t, http.StatusOK, status, empty)
+	status, stats = send(t, "GET", "/estate/"+estateId+"/blocks/"+empty["id"].(string)+"/stats", nil)
+	require.Equal(t, http.StatusOK, status, stats)
+	require.Equal(t, map[string]any{"count": float64(0), "max": float64(0), "min": float64(0), "median": float64(0)}, stats)
+
+	status, plan := send(t, "GET", "/estate/"+estateId+"/drone-plan?block_id="+west["id"].(string), nil)
+	require.Equal(t, http.StatusOK, status, plan)
+	require.Equal(t, float64(92), plan["distance"])
+
+	// Blocks keep the estate from shrinking onto them.
+	status, result = send(t, "PATCH", "/estate/"+estateId, map[string]int{"length": 3, "width": 3},
+		http.Header{"If-Match": {"*"}})
+	require.Equal(t, http.StatusConflict, status, result)
+
+	status, result = send(t, "DELETE", "/estate/"+estateId+"/divisions/"+division["id"].(string), nil)
+	require.Equal(t, http.StatusConflict, status, result)
+	// Redefined without a division, West leaves North empty.
+	status, result = send(t, "PUT", "/estate/"+estateId+"/blocks/"+west["id"].(string), map[string]any{
+		"name": "West", "x_min": 1, "y_min": 1, "x_max": 1, "y_max": 3,
+	}, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusOK, status, result)
+	require.Equal(t, float64(2), result["version"])
+	status, _ = send(t, "DELETE", "/estate/"+estateId+"/divisions/"+division["id"].(string), nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = send(t, "DELETE", "/estate/"+estateId+"/blocks/"+east["id"].(string), nil, http.Header{"If-Match": {`"1"`}})
+	require.Equal(t, http.StatusNoContent, status)
+}
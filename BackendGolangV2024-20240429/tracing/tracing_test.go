@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "zipkin"})
+	require.Error(t, err)
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, Writer: &buf})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/estate/:id/stats", func(c echo.Context) error {
+		_, span := Tracer("test").Start(c.Request().Context(), "child")
+		span.End()
+		return c.NoContent(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("traceparent"), traceID)
+
+	var names []string
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+		}
+		require.NoError(t, decoder.Decode(&span))
+		require.Equal(t, traceID, span.SpanContext.TraceID)
+		names = append(names, span.Name)
+	}
+	require.Equal(t, []string{"child", "GET /estate/:id/stats"}, names)
+}
@@ -0,0 +1,244 @@
+// Package webhook delivers the events of the outbox to subscribed endpoints as
+// signed JSON requests, retrying failures with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// Headers of a webhook request. The signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	HeaderEventId   = "X-Webhook-Id"
+	HeaderEventType = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxErrorBody caps how much of a failed response is kept as last error.
+const maxErrorBody = 512
+
+type Options struct {
+	Repository repository.RepositoryInterface
+	// Logger defaults to slog.Default() when nil.
+	Logger *slog.Logger
+	// Client defaults to an http.Client with Timeout.
+	Client *http.Client
+	// PollInterval is how often due deliveries are looked for.
+	PollInterval time.Duration
+	// BatchSize is how many deliveries are sent at once.
+	BatchSize int
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is abandoned.
+	MaxAttempts int
+	// InitialBackoff is the delay after the first failure; it doubles with
+	// every further failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// EventRetention is how long events and their deliveries are kept.
+	EventRetention time.Duration
+}
+
+// DefaultOptions are used for every zero field of Options.
+var DefaultOptions = Options{
+	PollInterval:   time.Second,
+	BatchSize:      50,
+	Timeout:        10 * time.Second,
+	MaxAttempts:    12,
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     time.Hour,
+	EventRetention: 7 * 24 * time.Hour,
+}
+
+// pruneInterval is how often events older than EventRetention are removed.
+const pruneInterval = time.Hour
+
+type Dispatcher struct {
+	opts   Options
+	logger *slog.Logger
+	client *http.Client
+}
+
+func NewDispatcher(opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultOptions.PollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOptions.BatchSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultOptions.InitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultOptions.MaxBackoff
+	}
+	if opts.EventRetention <= 0 {
+		opts.EventRetention = DefaultOptions.EventRetention
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &Dispatcher{opts: opts, logger: logger, client: client}
+}
+
+// Run delivers due webhooks until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(d.opts.PollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prune.C:
+			d.prune(ctx)
+		case <-poll.C:
+			// Keep going while full batches suggest a backlog.
+			for d.Dispatch(ctx) == d.opts.BatchSize {
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Dispatch sends one batch of due deliveries and reports how many it claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) int {
+	// The lease outlives the attempt, so no other dispatcher picks the
+	// delivery up while it is in flight.
+	deliveries, err := d.opts.Repository.ClaimWebhookDeliveries(ctx, d.opts.BatchSize, 2*d.opts.Timeout)
+	if err != nil {
+		d.logger.WarnContext(ctx, "claiming webhook deliveries", "error", err)
+		return 0
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery repository.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery repository.WebhookDelivery) {
+	err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.opts.Repository.CompleteWebhookDelivery(ctx, delivery.Id); err != nil {
+			d.logger.WarnContext(ctx, "recording webhook delivery", "delivery_id", delivery.Id, "error", err)
+		}
+		return
+	}
+
+	log := d.logger.With("delivery_id", delivery.Id, "event_id", delivery.Event.Id, "attempt", delivery.Attempts, "error", err)
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		log.WarnContext(ctx, "abandoning webhook delivery")
+		err = d.opts.Repository.AbandonWebhookDelivery(ctx, delivery.Id, err.Error())
+	} else {
+		next := time.Now().Add(Backoff(delivery.Attempts, d.opts.InitialBackoff, d.opts.MaxBackoff))
+		log.InfoContext(ctx, "webhook delivery failed, retrying", "next_attempt_at", next)
+		err = d.opts.Repository.RescheduleWebhookDelivery(ctx, delivery.Id, next, err.Error())
+	}
+	if err != nil {
+		d.logger.WarnContext(ctx, "recording webhook delivery", "delivery_id", delivery.Id, "error", err)
+	}
+}
+
+// send posts the event once; anything but a 2xx answer is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery repository.WebhookDelivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventId, strconv.FormatInt(delivery.Event.Id, 10))
+	req.Header.Set(HeaderEventType, delivery.Event.Type)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(excerpt))
+	}
+	return nil
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	deleted, err := d.opts.Repository.DeleteEventsBefore(ctx, time.Now().Add(-d.opts.EventRetention))
+	if err != nil {
+		d.logger.WarnContext(ctx, "pruning events", "error", err)
+		return
+	}
+	d.logger.DebugContext(ctx, "pruned events", "deleted", deleted)
+}
+
+// Backoff returns the delay after the given failed attempt (starting at 1):
+// initial doubled for every earlier failure, capped at max.
+func Backoff(attempt int, initial, max time.Duration) time.Duration {
+	if attempt > 32 {
+		return max
+	}
+	delay := initial << (attempt - 1)
+	if delay <= 0 || delay > max {
+		return max
+	}
+	return delay
+}
+
+// Sign returns the signature header of a request body sent at timestamp, a
+// decimal Unix time.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches the body and timestamp. Receivers
+// should also reject old timestamps to prevent replays.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the requests it gets and answers them with status.
+func receiver(t *testing.T, status int) (*httptest.Server, <-chan received) {
+	requests := make(chan received, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- received{r.Header, body}
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func delivery(url string, attempts int) repository.WebhookDelivery {
+	return repository.WebhookDelivery{
+		Id:       7,
+		Url:      url,
+		Secret:   "s3cret",
+		Attempts: attempts,
+		Event: repository.Event{
+			Id:         42,
+			Type:       repository.EventTreeCreated,
+			EstateId:   uuid.New(),
+			Data:       json.RawMessage(`{"x":1,"y":2,"height":10}`),
+			OccurredAt: time.Now().UTC().Truncate(time.Second),
+		},
+	}
+}
+
+func TestDispatchDeliversSignedEvents(t *testing.T) {
+	server, requests := receiver(t, http.StatusNoContent)
+	repo := repository.NewMockRepositoryInterface(gomock.NewController(t))
+	d := NewDispatcher(Options{Repository: repo, BatchSize: 10})
+
+	sent := delivery(server.URL, 1)
+	repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), 10, 2*DefaultOptions.Timeout).
+		Return([]repository.WebhookDelivery{sent}, nil)
+	repo.EXPECT().CompleteWebhookDelivery(gomock.Any(), int64(7)).Return(nil)
+	require.Equal(t, 1, d.Dispatch(context.Background()))
+
+	got := <-requests
+	require.Equal(t, "42", got.header.Get(HeaderEventId))
+	require.Equal(t, "tree.created", got.header.Get(HeaderEventType))
+	require.True(t, Verify("s3cret", got.header.Get(HeaderTimestamp), got.body, got.header.Get(HeaderSignature)))
+	require.False(t, Verify("other", got.header.Get(HeaderTimestamp), got.body, got.header.Get(HeaderSignature)))
+
+	var event repository.Event
+	require.NoError(t, json.Unmarshal(got.body, &event))
+	require.Equal(t, sent.Event.EstateId, event.EstateId)
+	require.JSONEq(t, `{"x":1,"y":2,"height":10}`, string(event.Data))
+}
+
+func TestDispatchRetriesWithBackoff(t *testing.T) {
+	server, requests := receiver(t, http.StatusServiceUnavailable)
+	repo := repository.NewMockRepositoryInterface(gomock.NewController(t))
+	d := NewDispatcher(Options{Repository: repo, InitialBackoff: time.Minute, MaxAttempts: 3})
+
+	start := time.Now()
+	repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]repository.WebhookDelivery{delivery(server.URL, 2)}, nil)
+	repo.EXPECT().RescheduleWebhookDelivery(gomock.Any(), int64(7), gomock.Any(), "status 503: Service Unavailable").
+		DoAndReturn(func(_ context.Context, _ int64, next time.Time, _ string) error {
+			// The second failure waits twice the initial backoff.
+			require.WithinDuration(t, start.Add(2*time.Minute), next, 5*time.Second)
+			return nil
+		})
+	d.Dispatch(context.Background())
+	<-requests
+
+	repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]repository.WebhookDelivery{delivery(server.URL, 3)}, nil)
+	repo.EXPECT().AbandonWebhookDelivery(gomock.Any(), int64(7), "status 503: Service Unavailable").Return(nil)
+	d.Dispatch(context.Background())
+	<-requests
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 10*time.Second, Backoff(1, 10*time.Second, time.Hour))
+	require.Equal(t, 80*time.Second, Backoff(4, 10*time.Second, time.Hour))
+	require.Equal(t, time.Hour, Backoff(12, 10*time.Second, time.Hour))
+	require.Equal(t, time.Hour, Backoff(100, 10*time.Second, time.Hour))
+}